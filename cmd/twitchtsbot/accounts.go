@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/admin"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+var accountsCsvHeader = []string{"ts", "channel", "platform"}
+
+// runAccounts manages the account pairs which are stored in the config file.
+func runAccounts(args []string) int {
+	if len(args) == 0 {
+		printAccountsUsage()
+		return 2
+	}
+	flags := flag.NewFlagSet("accounts "+args[0], flag.ExitOnError)
+	platform := flags.String("platform", "", "Set the streaming platform of the account pair.")
+	targetName := flags.String("target", "", "Set the TeamSpeak target of the account pairs.")
+	_ = flags.Parse(args[1:])
+	loadConfig()
+	accounts, err := readAccounts(*targetName)
+	if err != nil {
+		logrus.WithError(err).Errorln("Could not load account pairs.")
+		return 1
+	}
+	switch args[0] {
+	case "list":
+		printAccounts(os.Stdout, accounts)
+		return 0
+	case "add":
+		if flags.NArg() != 2 {
+			printAccountsUsage()
+			return 2
+		}
+		entry := &accountEntry{TsIdentifier: flags.Arg(0), TwitchUsername: flags.Arg(1), Platform: *platform}
+		if accounts, err = addAccount(accounts, entry); err != nil {
+			logrus.WithField("account", entry.TsIdentifier).Errorln("Account pair already exists.")
+			return 1
+		}
+	case "remove":
+		if flags.NArg() < 1 || flags.NArg() > 2 {
+			printAccountsUsage()
+			return 2
+		}
+		var channel *twitch.Channel
+		if flags.NArg() == 2 {
+			entryChannel := (&accountEntry{TwitchUsername: flags.Arg(1), Platform: *platform}).channel()
+			channel = &entryChannel
+		}
+		if accounts, err = removeAccount(accounts, flags.Arg(0), channel); err != nil {
+			logrus.WithField("account", flags.Arg(0)).Errorln("Account pair does not exist.")
+			return 1
+		}
+	case "import":
+		if flags.NArg() != 1 {
+			printAccountsUsage()
+			return 2
+		}
+		imported, err := importAccounts(flags.Arg(0))
+		if err != nil {
+			logrus.WithError(err).Errorln("Could not import account pairs.")
+			return 1
+		}
+		accounts = mergeAccounts(accounts, imported)
+	case "export":
+		output := io.Writer(os.Stdout)
+		if flags.NArg() == 1 {
+			file, err := os.Create(flags.Arg(0))
+			if err != nil {
+				logrus.WithError(err).Errorln("Could not create export file.")
+				return 1
+			}
+			defer file.Close()
+			output = file
+		}
+		if err := exportAccounts(output, accounts); err != nil {
+			logrus.WithError(err).Errorln("Could not export account pairs.")
+			return 1
+		}
+		return 0
+	default:
+		printAccountsUsage()
+		return 2
+	}
+	if err := writeAccountsConfig(*targetName, accounts); err != nil {
+		logrus.WithError(err).Errorln("Could not write account pairs.")
+		return 1
+	}
+	logrus.WithField("pairAmount", len(accounts)).Infoln("Updated account pairs.")
+	return 0
+}
+
+func printAccountsUsage() {
+	_, _ = fmt.Fprintf(flag.CommandLine.Output(), `Usage:
+  accounts list [-target <name>]
+  accounts add [-target <name>] [-platform <platform>] <ts> <channel>
+  accounts remove [-target <name>] [-platform <platform>] <ts> [<channel>]
+  accounts import [-target <name>] <file.csv>
+  accounts export [-target <name>] [<file.csv>]
+`)
+}
+
+// readAccounts returns the account pairs of the named target or the top level account pairs if no target is given.
+func readAccounts(targetName string) ([]*accountEntry, error) {
+	if targetName == "" {
+		var accounts []*accountEntry
+		if err := viper.UnmarshalKey("accounts", &accounts); err != nil {
+			return nil, err
+		}
+		return accounts, nil
+	}
+	targets, err := loadTargets()
+	if err != nil {
+		return nil, err
+	}
+	for _, target := range targets {
+		if target.Name == targetName {
+			return target.Accounts, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown teamspeak target: %s", targetName)
+}
+
+func printAccounts(output io.Writer, accounts []*accountEntry) {
+	writer := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "TEAMSPEAK\tPLATFORM\tCHANNEL")
+	for _, account := range accounts {
+		channel := account.channel()
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\n", account.TsIdentifier, channel.Platform, channel.Login)
+	}
+	_ = writer.Flush()
+}
+
+// importAccounts reads account pairs from a CSV file with the columns ts, channel and an optional platform.
+func importAccounts(path string) ([]*accountEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]*accountEntry, 0, len(records))
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(record[0], accountsCsvHeader[0]) {
+			continue
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: expected at least 2 columns", i+1)
+		}
+		entry := &accountEntry{TsIdentifier: record[0], TwitchUsername: record[1]}
+		if len(record) > 2 {
+			entry.Platform = record[2]
+		}
+		accounts = append(accounts, entry)
+	}
+	return accounts, nil
+}
+
+func exportAccounts(output io.Writer, accounts []*accountEntry) error {
+	writer := csv.NewWriter(output)
+	if err := writer.Write(accountsCsvHeader); err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		channel := account.channel()
+		if err := writer.Write([]string{account.TsIdentifier, channel.Login, channel.Platform}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// addAccount appends the account pair and returns an error if it already exists.
+func addAccount(accounts []*accountEntry, entry *accountEntry) ([]*accountEntry, error) {
+	for _, account := range accounts {
+		if account.TsIdentifier == entry.TsIdentifier && account.channel() == entry.channel() {
+			return accounts, fmt.Errorf("%w: account pair already exists", admin.ErrInvalidAccount)
+		}
+	}
+	return append(accounts, entry), nil
+}
+
+// removeAccount removes the account pairs of the TeamSpeak identity. If the channel is set, only the pair with this
+// channel is removed. An error is returned if no pair has been removed.
+func removeAccount(accounts []*accountEntry, tsIdentifier string, channel *twitch.Channel) ([]*accountEntry, error) {
+	remaining := make([]*accountEntry, 0, len(accounts))
+	for _, account := range accounts {
+		if account.TsIdentifier == tsIdentifier && (channel == nil || account.channel() == *channel) {
+			continue
+		}
+		remaining = append(remaining, account)
+	}
+	if len(remaining) == len(accounts) {
+		return accounts, fmt.Errorf("%w: account pair does not exist", admin.ErrInvalidAccount)
+	}
+	return remaining, nil
+}
+
+// configAccountManager implements admin.AccountManager by changing the account pairs of the config.
+type configAccountManager struct {
+	*sync.Mutex
+}
+
+func (manager *configAccountManager) Accounts() ([]admin.Account, error) {
+	manager.Lock()
+	defer manager.Unlock()
+	targets, err := loadTargets()
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]admin.Account, 0)
+	for _, target := range targets {
+		for _, account := range target.Accounts {
+			channel := account.channel()
+			accounts = append(accounts, admin.Account{
+				Target:       target.Name,
+				TsIdentifier: account.TsIdentifier,
+				Platform:     channel.Platform,
+				Login:        channel.Login,
+			})
+		}
+	}
+	return accounts, nil
+}
+
+func (manager *configAccountManager) AddAccount(account admin.Account) error {
+	return manager.change(account.Target, func(accounts []*accountEntry) ([]*accountEntry, error) {
+		return addAccount(accounts, &accountEntry{
+			TsIdentifier:   account.TsIdentifier,
+			TwitchUsername: account.Login,
+			Platform:       account.Platform,
+		})
+	})
+}
+
+func (manager *configAccountManager) RemoveAccount(account admin.Account) error {
+	channel := (&accountEntry{TwitchUsername: account.Login, Platform: account.Platform}).channel()
+	return manager.change(account.Target, func(accounts []*accountEntry) ([]*accountEntry, error) {
+		return removeAccount(accounts, account.TsIdentifier, &channel)
+	})
+}
+
+func (manager *configAccountManager) change(targetName string,
+	change func(accounts []*accountEntry) ([]*accountEntry, error)) error {
+	manager.Lock()
+	defer manager.Unlock()
+	accounts, err := readAccounts(targetName)
+	if err != nil {
+		return fmt.Errorf("%w: %s", admin.ErrInvalidAccount, err)
+	}
+	if accounts, err = change(accounts); err != nil {
+		return err
+	}
+	if err := writeAccountsConfig(targetName, accounts); err != nil {
+		return err
+	}
+	logrus.WithFields(logrus.Fields{"target": targetName, "pairAmount": len(accounts)}).
+		Infoln("Updated account pairs. The change is applied after a restart.")
+	return nil
+}
+
+// mergeAccounts appends all imported account pairs which are not already part of the existing ones.
+func mergeAccounts(accounts, imported []*accountEntry) []*accountEntry {
+	for _, entry := range imported {
+		exists := false
+		for _, account := range accounts {
+			exists = exists || account.TsIdentifier == entry.TsIdentifier && account.channel() == entry.channel()
+		}
+		if !exists {
+			accounts = append(accounts, entry)
+		}
+	}
+	return accounts
+}
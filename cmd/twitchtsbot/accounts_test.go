@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportAccounts(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		csv      string
+		expected []*accountEntry
+		err      bool
+	}{
+		{
+			name: "header",
+			csv:  "ts,channel,platform\nuid1,streamer1,twitch\n",
+			expected: []*accountEntry{
+				{TsIdentifier: "uid1", TwitchUsername: "streamer1", Platform: "twitch"},
+			},
+		},
+		{
+			name: "upper case header",
+			csv:  "TS,Channel\nuid1,streamer1\n",
+			expected: []*accountEntry{
+				{TsIdentifier: "uid1", TwitchUsername: "streamer1"},
+			},
+		},
+		{
+			name: "no header",
+			csv:  "uid1,streamer1,youtube\nuid2,streamer2\n",
+			expected: []*accountEntry{
+				{TsIdentifier: "uid1", TwitchUsername: "streamer1", Platform: "youtube"},
+				{TsIdentifier: "uid2", TwitchUsername: "streamer2"},
+			},
+		},
+		{
+			name:     "empty",
+			csv:      "",
+			expected: []*accountEntry{},
+		},
+		{
+			name: "missing channel",
+			csv:  "ts,channel\nuid1\n",
+			err:  true,
+		},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "twitchtsbot")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				_ = os.RemoveAll(dir)
+			})
+			path := filepath.Join(dir, "accounts.csv")
+			if err := ioutil.WriteFile(path, []byte(test.csv), 0600); err != nil {
+				t.Fatal(err)
+			}
+			accounts, err := importAccounts(path)
+			if test.err {
+				assert.Error(t, err, "expected the import to fail")
+				return
+			}
+			assert.NoError(t, err, "could not import account pairs")
+			assert.Equal(t, test.expected, accounts, "imported account pairs are invalid")
+		})
+	}
+}
+
+func TestImportAccounts_MissingFile(t *testing.T) {
+	accounts, err := importAccounts(filepath.Join(os.TempDir(), "twitchtsbot-missing.csv"))
+	assert.Error(t, err, "expected the import of a missing file to fail")
+	assert.Nil(t, accounts, "expected no account pairs")
+}
+
+func TestExportAccounts(t *testing.T) {
+	output := &bytes.Buffer{}
+	err := exportAccounts(output, []*accountEntry{
+		{TsIdentifier: "uid1", TwitchUsername: "streamer1"},
+		{TsIdentifier: "uid2", TwitchUsername: "streamer2", Platform: "YouTube"},
+	})
+	assert.NoError(t, err, "could not export account pairs")
+	assert.Equal(t, "ts,channel,platform\nuid1,streamer1,twitch\nuid2,streamer2,youtube\n", output.String(),
+		"exported account pairs are invalid")
+}
+
+func TestMergeAccounts(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		accounts []*accountEntry
+		imported []*accountEntry
+		expected []*accountEntry
+	}{
+		{
+			name:     "new account pair",
+			accounts: []*accountEntry{{TsIdentifier: "uid1", TwitchUsername: "streamer1"}},
+			imported: []*accountEntry{{TsIdentifier: "uid2", TwitchUsername: "streamer2"}},
+			expected: []*accountEntry{
+				{TsIdentifier: "uid1", TwitchUsername: "streamer1"},
+				{TsIdentifier: "uid2", TwitchUsername: "streamer2"},
+			},
+		},
+		{
+			name:     "existing account pair",
+			accounts: []*accountEntry{{TsIdentifier: "uid1", TwitchUsername: "streamer1"}},
+			imported: []*accountEntry{{TsIdentifier: "uid1", TwitchUsername: "streamer1"}},
+			expected: []*accountEntry{{TsIdentifier: "uid1", TwitchUsername: "streamer1"}},
+		},
+		{
+			name: "duplicate imported account pair",
+			imported: []*accountEntry{
+				{TsIdentifier: "uid1", TwitchUsername: "streamer1"},
+				{TsIdentifier: "uid1", TwitchUsername: "streamer1"},
+			},
+			expected: []*accountEntry{{TsIdentifier: "uid1", TwitchUsername: "streamer1"}},
+		},
+		{
+			name:     "default platform",
+			accounts: []*accountEntry{{TsIdentifier: "uid1", TwitchUsername: "streamer1"}},
+			imported: []*accountEntry{{TsIdentifier: "uid1", TwitchUsername: "streamer1", Platform: "Twitch"}},
+			expected: []*accountEntry{{TsIdentifier: "uid1", TwitchUsername: "streamer1"}},
+		},
+		{
+			name:     "other platform",
+			accounts: []*accountEntry{{TsIdentifier: "uid1", TwitchUsername: "streamer1"}},
+			imported: []*accountEntry{{TsIdentifier: "uid1", TwitchUsername: "streamer1", Platform: "youtube"}},
+			expected: []*accountEntry{
+				{TsIdentifier: "uid1", TwitchUsername: "streamer1"},
+				{TsIdentifier: "uid1", TwitchUsername: "streamer1", Platform: "youtube"},
+			},
+		},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, mergeAccounts(test.accounts, test.imported), "merged account pairs are invalid")
+		})
+	}
+}
+
+func TestRunAccounts_Import(t *testing.T) {
+	dir, err := setupTestConfig(t, "accounts:\n- ts: 'uid1'\n  twitch: 'streamer1'\n", map[string]string{
+		"accounts.csv": "ts,channel,platform\nuid1,streamer1,\nuid2,streamer2,youtube\n",
+	}, nil)
+	if !assert.NoError(t, err, "could not read config") {
+		return
+	}
+	assert.Equal(t, 0, runAccounts([]string{"import", filepath.Join(dir, "accounts.csv")}),
+		"unexpected exit code of the import")
+	fileConfig := viper.New()
+	fileConfig.SetConfigFile(*configPath)
+	if !assert.NoError(t, fileConfig.ReadInConfig(), "could not read written config") {
+		return
+	}
+	var accounts []*accountEntry
+	assert.NoError(t, fileConfig.UnmarshalKey("accounts", &accounts), "could not read written account pairs")
+	assert.Equal(t, []*accountEntry{
+		{TsIdentifier: "uid1", TwitchUsername: "streamer1"},
+		{TsIdentifier: "uid2", TwitchUsername: "streamer2", Platform: "youtube"},
+	}, accounts, "written account pairs are invalid")
+}
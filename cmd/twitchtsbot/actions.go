@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/teamspeak"
+	"github.com/spf13/viper"
+	"path/filepath"
+)
+
+const (
+	actionServerGroup  = "servergroup"
+	actionChannelGroup = "channelgroup"
+	actionDescription  = "description"
+	actionIcon         = "icon"
+	actionMove         = "move"
+	actionMessage      = "message"
+)
+
+// actionConfig contains the parameters of a single presence action. Which of them are used depends on its type.
+type actionConfig struct {
+	Type string `mapstructure:"type"`
+	// ServerGroupID defaults to the server group id of the target.
+	ServerGroupID         int    `mapstructure:"servergroupid"`
+	ChannelID             int    `mapstructure:"channelid"`
+	ChannelGroupID        int    `mapstructure:"channelgroupid"`
+	DefaultChannelGroupID int    `mapstructure:"defaultchannelgroupid"`
+	IconID                int    `mapstructure:"iconid"`
+	Format                string `mapstructure:"format"`
+	Live                  string `mapstructure:"live"`
+	Offline               string `mapstructure:"offline"`
+}
+
+// actionConfigs returns the configured presence actions of the target. If none are configured, live streamers are
+// added to the server group of the target.
+func (target *teamspeakTarget) actionConfigs() []*actionConfig {
+	if len(target.Actions) == 0 {
+		return []*actionConfig{{Type: actionServerGroup, ServerGroupID: target.ServerGroupID}}
+	}
+	for _, action := range target.Actions {
+		if action.Type == actionServerGroup && action.ServerGroupID == 0 {
+			action.ServerGroupID = target.ServerGroupID
+		}
+	}
+	return target.Actions
+}
+
+// newActions creates the presence actions of the target. Server group changes are applied by the queue, while all
+// other actions use the client directly.
+func (target *teamspeakTarget) newActions(queue *teamspeak.Queue, client teamspeak.Client) []teamspeak.Action {
+	configs := target.actionConfigs()
+	actions := make([]teamspeak.Action, 0, len(configs))
+	for _, config := range configs {
+		switch config.Type {
+		case actionServerGroup:
+			actions = append(actions, teamspeak.NewServerGroupAction(queue, config.ServerGroupID))
+		case actionChannelGroup:
+			actions = append(actions, &teamspeak.ChannelGroupAction{
+				Client:                client,
+				ChannelId:             config.ChannelID,
+				ChannelGroupId:        config.ChannelGroupID,
+				DefaultChannelGroupId: config.DefaultChannelGroupID,
+				Audit:                 target.audit,
+			})
+		case actionDescription:
+			actions = append(actions, target.newDescriptionTagger(client, config))
+		case actionIcon:
+			actions = append(actions, &teamspeak.IconAction{Client: client, IconId: config.IconID})
+		case actionMove:
+			actions = append(actions, teamspeak.NewMoveAction(client, config.ChannelID))
+		case actionMessage:
+			actions = append(actions, &teamspeak.MessageAction{Client: client, Live: config.Live, Offline: config.Offline})
+		default:
+			target.log().WithField("action", config.Type).Fatalln("Unknown presence action.")
+		}
+	}
+	return actions
+}
+
+// newDescriptionTagger creates the tagger of the client descriptions of the target. The original descriptions are
+// persisted in a file named after the target in the state directory of the descriptions. Failed changes are retried
+// with the delays of the queue.
+func (target *teamspeakTarget) newDescriptionTagger(client teamspeak.Client,
+	config *actionConfig) *teamspeak.DescriptionTagger {
+	stateDir, err := stateDirectory("description.statedir")
+	if err != nil {
+		target.log().WithError(err).WithField("stateDir", stateDir).
+			Fatalln("Could not create description state directory.")
+	}
+	stateFile := filepath.Join(stateDir, "description-"+target.Name+".json")
+	tagger, err := teamspeak.NewDescriptionTagger(client, stateFile)
+	if err != nil {
+		target.log().WithError(err).WithField("stateFile", stateFile).Fatalln("Could not load original client descriptions.")
+	}
+	if config.Format != "" {
+		tagger.Format = config.Format
+	}
+	tagger.Audit = target.audit
+	tagger.BaseDelay = viper.GetDuration("queue.basedelay")
+	tagger.MaxDelay = viper.GetDuration("queue.maxdelay")
+	return tagger
+}
+
+// checkActions adds a problem for every presence action of the target with missing or invalid parameters.
+func (check *configCheck) checkActions(target *teamspeakTarget) {
+	descriptions := 0
+	for i, action := range target.actionConfigs() {
+		subject := target.subject(fmt.Sprintf("actions[%d]", i))
+		switch action.Type {
+		case actionServerGroup:
+			if action.ServerGroupID <= 0 {
+				if len(target.Actions) == 0 {
+					subject = target.subject("servergroupid")
+				}
+				check.addProblem(subject, "server group id %d is invalid", action.ServerGroupID)
+			}
+		case actionChannelGroup:
+			if action.ChannelID <= 0 || action.ChannelGroupID <= 0 || action.DefaultChannelGroupID <= 0 {
+				check.addProblem(subject, "channelid, channelgroupid and defaultchannelgroupid have to be set")
+			}
+		case actionDescription:
+			descriptions++
+			if descriptions > 1 {
+				check.addProblem(subject, "only a single description action is allowed per target")
+			}
+		case actionIcon:
+			if action.IconID == 0 {
+				check.addProblem(subject, "iconid is not set")
+			}
+		case actionMove:
+			if action.ChannelID <= 0 {
+				check.addProblem(subject, "channelid is not set")
+			}
+		case actionMessage:
+			if action.Live == "" && action.Offline == "" {
+				check.addProblem(subject, "neither a live nor an offline message is set")
+			}
+		default:
+			check.addProblem(subject, "unknown presence action %q", action.Type)
+		}
+	}
+}
+
+// serverGroupIds returns the ids of all server groups which are changed by the presence actions of the target.
+func (target *teamspeakTarget) serverGroupIds() []int {
+	serverGroupIds := make([]int, 0)
+	for _, action := range target.actionConfigs() {
+		if action.Type == actionServerGroup {
+			serverGroupIds = append(serverGroupIds, action.ServerGroupID)
+		}
+	}
+	return serverGroupIds
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/audit"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// openAuditStore returns the audit log of the TeamSpeak changes or nil if it is disabled.
+func openAuditStore() *audit.Store {
+	file := viper.GetString("audit.file")
+	if file == "" {
+		return nil
+	}
+	return audit.NewStore(file)
+}
+
+// setAudit records the TeamSpeak changes of the target in the given audit log. Changes are not recorded in the dry run
+// mode as they are not applied.
+func (target *teamspeakTarget) setAudit(store *audit.Store) {
+	target.audit = nil
+	if store != nil && !*dryRun && !viper.GetBool("dryrun") {
+		target.audit = &audit.Recorder{Store: store, Target: target.Name}
+	}
+}
+
+// runAudit prints the entries of the audit log which match the given filters.
+func runAudit(args []string) int {
+	flags := flag.NewFlagSet("audit", flag.ExitOnError)
+	since := flags.Duration("since", 0, "Only print the changes of the given duration such as 168h.")
+	target := flags.String("target", "", "Only print the changes of the given TeamSpeak target.")
+	clientDbId := flags.Int("ts", 0, "Only print the changes of the given TeamSpeak database id.")
+	login := flags.String("login", "", "Only print the changes of the given streaming channel.")
+	action := flags.String("action", "", "Only print the given action such as servergroup_added.")
+	trigger := flags.String("trigger", "", "Only print the changes with the given trigger such as transition.")
+	outcome := flags.String("outcome", "", "Only print the changes with the given outcome such as failure.")
+	limit := flags.Int("limit", 100, "Set the maximum amount of printed changes. 0 prints all changes.")
+	printJSON := flags.Bool("json", false, "Print the changes as JSON lines.")
+	_ = flags.Parse(args)
+	loadConfig()
+	store := openAuditStore()
+	if store == nil {
+		logrus.Errorln("The audit log is not enabled. Set audit.file to record it.")
+		return 1
+	}
+	filter := audit.Filter{
+		Target:     *target,
+		ClientDbId: *clientDbId,
+		Login:      *login,
+		Action:     *action,
+		Trigger:    *trigger,
+		Outcome:    *outcome,
+		Limit:      *limit,
+	}
+	if *since > 0 {
+		filter.Since = time.Now().Add(-*since)
+	}
+	entries, err := store.Query(filter)
+	if err != nil {
+		logrus.WithError(err).WithField("file", store.File).Errorln("Could not read audit log.")
+		return 1
+	}
+	if *printJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, entry := range entries {
+			_ = encoder.Encode(entry)
+		}
+		return 0
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "TIME\tTARGET\tTS\tCHANNEL\tACTION\tGROUP\tTRIGGER\tOUTCOME\tREASON")
+	for _, entry := range entries {
+		channel := entry.Login
+		if entry.Platform != "" {
+			channel = entry.Platform + "/" + entry.Login
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n", entry.Time.Local().Format(time.RFC3339),
+			entry.Target, entry.ClientDbId, channel, entry.Action, auditGroup(&entry), entry.Trigger, entry.Outcome,
+			entry.Reason)
+	}
+	_ = writer.Flush()
+	return 0
+}
+
+// auditGroup returns the changed server group or channel group of the entry.
+func auditGroup(entry *audit.Entry) string {
+	if entry.ServerGroupId != 0 {
+		return strconv.Itoa(entry.ServerGroupId)
+	} else if entry.ChannelGroupId != 0 {
+		return fmt.Sprintf("%d (channel %d)", entry.ChannelGroupId, entry.ChannelId)
+	}
+	return ""
+}
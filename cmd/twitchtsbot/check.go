@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/youtube"
+	"github.com/spf13/viper"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	memberAddPowerPermission    = "i_group_member_add_power"
+	memberRemovePowerPermission = "i_group_member_remove_power"
+)
+
+type checkProblem struct {
+	subject string
+	problem string
+}
+
+// configCheck collects all configuration problems which would otherwise only show up at runtime.
+type configCheck struct {
+	problems []checkProblem
+}
+
+func (check *configCheck) addProblem(subject, format string, args ...interface{}) {
+	check.problems = append(check.problems, checkProblem{subject: subject, problem: fmt.Sprintf(format, args...)})
+}
+
+// runCheck validates the configuration as well as all configured TeamSpeak and Twitch resources and returns the exit
+// code of the check subcommand.
+func runCheck(_ []string) int {
+	return checkConfig().print()
+}
+
+// checkConfig reads the config file and collects the problems of the configuration and of all configured TeamSpeak and
+// Twitch resources.
+func checkConfig() *configCheck {
+	check := &configCheck{}
+	if err := readConfig(); err != nil {
+		check.addProblem("config", "could not load config file: %s", err)
+		return check
+	}
+	targets, err := loadTargets()
+	if err != nil {
+		check.addProblem("targets", "could not load teamspeak targets: %s", err)
+		return check
+	}
+	check.checkConfigValues()
+	check.checkYouTubeQuota(targets)
+	logins := make([]string, 0)
+	for _, target := range targets {
+		check.checkTargetValues(target)
+		check.checkTeamspeak(target)
+		for _, account := range target.Accounts {
+			if account.channel().Platform == twitch.PlatformTwitch && account.TwitchUsername != "" {
+				logins = append(logins, account.TwitchUsername)
+			}
+		}
+	}
+	check.checkTwitch(logins)
+	return check
+}
+
+// checkYouTubeQuota adds a problem if polling the YouTube channels of all targets would exceed the daily quota.
+func (check *configCheck) checkYouTubeQuota(targets []*teamspeakTarget) {
+	channels := len(groupChannelsByPlatform(targets)[youtube.Platform])
+	interval := viper.GetDuration("youtube.interval")
+	if channels == 0 {
+		return
+	}
+	if interval <= 0 {
+		check.addProblem("youtube.interval", "interval has to be a positive duration")
+		return
+	}
+	units := int(24*time.Hour/interval) * youtube.QuotaUnits(channels)
+	if units > youtube.DailyQuota {
+		check.addProblem("youtube.interval", "polling %d channels every %s uses about %d quota units per day, "+
+			"which exceeds the daily quota of %d", channels, interval, units, youtube.DailyQuota)
+	}
+}
+
+func (check *configCheck) checkConfigValues() {
+	for _, key := range []string{"twitch.clientid", "twitch.appaccesstoken"} {
+		if value := viper.GetString(key); value == "" || isPlaceholder(value) {
+			check.addProblem(key, "value is not set")
+		}
+	}
+	if viper.GetDuration("interval") <= 0 {
+		check.addProblem("interval", "interval has to be a positive duration")
+	}
+	if viper.GetDuration("queue.membershiprefresh") < 0 {
+		check.addProblem("queue.membershiprefresh", "value must not be negative, use 0 to disable the refresh")
+	}
+	if err := configureLogging(); err != nil {
+		check.addProblem("log", "%s", err)
+	}
+	switch exporter := viper.GetString("tracing.exporter"); exporter {
+	case "", tracingExporterOTLP, tracingExporterStdout:
+	default:
+		check.addProblem("tracing.exporter", "unknown exporter %q", exporter)
+	}
+	if ratio := viper.GetFloat64("tracing.sampleratio"); ratio < 0 || ratio > 1 {
+		check.addProblem("tracing.sampleratio", "value has to be between 0 and 1")
+	}
+	if _, err := globalStreamFilter(); err != nil {
+		check.addProblem("streamfilter", "%s", err)
+	}
+}
+
+func (check *configCheck) checkTargetValues(target *teamspeakTarget) {
+	if target.URL == "" || isPlaceholder(target.URL) {
+		check.addProblem(target.subject("url"), "value is not set")
+	}
+	if target.APIKey == "" || isPlaceholder(target.APIKey) {
+		check.addProblem(target.subject("apikey"), "value is not set")
+	}
+	check.checkActions(target)
+	if len(target.Accounts) == 0 {
+		check.addProblem(target.subject("accounts"), "no account pairs are configured")
+	}
+	for i, account := range target.Accounts {
+		subject := target.subject(fmt.Sprintf("accounts[%d]", i))
+		if account.TsIdentifier == "" {
+			check.addProblem(subject, "teamspeak identifier is not set")
+		}
+		if account.TwitchUsername == "" {
+			check.addProblem(subject, "channel login is not set")
+		}
+		switch account.channel().Platform {
+		case twitch.PlatformTwitch:
+		case youtube.Platform:
+			if apiKey := viper.GetString("youtube.apikey"); apiKey == "" || isPlaceholder(apiKey) {
+				check.addProblem(subject, "youtube account requires youtube.apikey to be set")
+			}
+		default:
+			check.addProblem(subject, "unknown streaming platform %q", account.Platform)
+		}
+		if account.Filter != nil {
+			if _, err := account.Filter.filter(); err != nil {
+				check.addProblem(subject+".filter", "%s", err)
+			}
+		}
+	}
+}
+
+func (check *configCheck) checkTeamspeak(target *teamspeakTarget) {
+	client := target.newClient()
+	if _, err := client.Version(); err != nil {
+		check.addProblem(target.subject("teamspeak"), "could not connect to teamspeak webquery: %s", err)
+		return
+	}
+	serverGroups, err := client.ServerGroupList()
+	if err != nil {
+		check.addProblem(target.subject("teamspeak"), "could not list server groups: %s", err)
+		return
+	}
+	serverGroupIds := target.serverGroupIds()
+	if viper.GetString("subscriptions.accesstoken") != "" {
+		for _, serverGroupId := range target.SubscriptionServerGroups {
+			serverGroupIds = append(serverGroupIds, serverGroupId)
+		}
+	}
+	addPower, addErr := client.StringPermissionGet(memberAddPowerPermission)
+	removePower, removeErr := client.StringPermissionGet(memberRemovePowerPermission)
+	for _, serverGroupId := range serverGroupIds {
+		subject := target.subject(fmt.Sprintf("servergroup %d", serverGroupId))
+		found := false
+		for _, serverGroup := range *serverGroups {
+			if serverGroup.ServerGroupId != serverGroupId {
+				continue
+			}
+			found = true
+			if addErr != nil || addPower.PermissionValue < serverGroup.NMemberAddp {
+				check.addProblem(subject, "query user is not allowed to add members to server group %q", serverGroup.Name)
+			}
+			if removeErr != nil || removePower.PermissionValue < serverGroup.NMemberRemovep {
+				check.addProblem(subject, "query user is not allowed to remove members from server group %q", serverGroup.Name)
+			}
+		}
+		if !found {
+			check.addProblem(subject, "server group does not exist")
+		}
+	}
+	for _, account := range target.Accounts {
+		subject := target.subject("teamspeak " + account.TsIdentifier)
+		if databaseId, err := strconv.Atoi(account.TsIdentifier); err == nil {
+			if _, err := client.ClientDbInfo(databaseId); err != nil {
+				check.addProblem(subject, "could not find teamspeak database id: %s", err)
+			}
+		} else if _, err := client.ClientGetDbIdFromUid(account.TsIdentifier); err != nil {
+			check.addProblem(subject, "could not resolve teamspeak identity: %s", err)
+		}
+	}
+}
+
+func (check *configCheck) checkTwitch(logins []string) {
+	if token := viper.GetString("twitch.appaccesstoken"); token == "" || isPlaceholder(token) {
+		return
+	}
+	client, err := newHelixClient(twitchHTTPClient)
+	if err != nil {
+		check.addProblem("twitch", "could not create twitch helix client: %s", err)
+		return
+	}
+	valid, _, err := client.ValidateToken(viper.GetString("twitch.appaccesstoken"))
+	if err != nil {
+		check.addProblem("twitch.appaccesstoken", "could not validate app access token: %s", err)
+		return
+	} else if !valid {
+		check.addProblem("twitch.appaccesstoken", "app access token is invalid")
+		return
+	}
+	if len(logins) == 0 {
+		return
+	}
+	client.SetAppAccessToken(viper.GetString("twitch.appaccesstoken"))
+	ids, err := twitch.RetrieveIDs(client, logins)
+	if err != nil {
+		check.addProblem("twitch", "could not resolve twitch logins: %s", err)
+		return
+	}
+	for _, login := range logins {
+		if _, ok := ids[strings.ToLower(login)]; !ok {
+			check.addProblem("twitch "+login, "twitch login does not exist")
+		}
+	}
+}
+
+// print writes the collected problems as a table to stdout and returns the resulting exit code.
+func (check *configCheck) print() int {
+	if len(check.problems) == 0 {
+		fmt.Println("No problems found.")
+		return 0
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "SUBJECT\tPROBLEM")
+	for _, problem := range check.problems {
+		_, _ = fmt.Fprintf(writer, "%s\t%s\n", problem.subject, problem.problem)
+	}
+	_ = writer.Flush()
+	fmt.Printf("Found %d problem(s).\n", len(check.problems))
+	return 1
+}
+
+// isPlaceholder returns whether the value is one of the placeholders written by setConfigDefaults.
+func isPlaceholder(value string) bool {
+	return strings.HasPrefix(value, "<") && strings.HasSuffix(value, ">")
+}
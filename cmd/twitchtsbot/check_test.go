@@ -0,0 +1,144 @@
+package main
+
+import (
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/testutil/helixtest"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/testutil/ts3test"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const checkConfigBase = `teamspeak:
+  url: '{url}'
+  apikey: '{apikey}'
+twitch:
+  clientid: '{clientid}'
+  appaccesstoken: '{token}'
+servergroupid: {servergroupid}
+`
+
+const checkAccounts = `accounts:
+  - ts: 'uid='
+    twitch: 'streamer'
+`
+
+func TestCheckConfig(t *testing.T) {
+	helixServer := helixtest.NewServer()
+	defer helixServer.Close()
+	helixServer.AddUser("streamer")
+	tsServer, err := ts3test.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tsServer.Close()
+	serverGroupId := tsServer.AddServerGroup("Live")
+	tsServer.AddClient("uid=", "streamer")
+	defaultHTTPClient := twitchHTTPClient
+	twitchHTTPClient = helixServer.HTTPClient()
+	defer func() {
+		twitchHTTPClient = defaultHTTPClient
+	}()
+	replacer := strings.NewReplacer("{url}", tsServer.URL, "{apikey}", tsServer.APIKey,
+		"{clientid}", helixServer.ClientID, "{token}", helixServer.IssueAppToken(),
+		"{servergroupid}", strconv.Itoa(serverGroupId))
+	for _, test := range []struct {
+		name     string
+		config   string
+		problems []string
+		exitCode int
+	}{
+		{
+			name:   "valid",
+			config: checkConfigBase + checkAccounts,
+		},
+		{
+			name:     "invalid yaml",
+			config:   checkConfigBase + "accounts: [\n",
+			problems: []string{"config"},
+			exitCode: 1,
+		},
+		{
+			name:     "negative intervals",
+			config:   checkConfigBase + checkAccounts + "interval: -1s\nqueue:\n  membershiprefresh: -1m\n",
+			problems: []string{"interval", "queue.membershiprefresh"},
+			exitCode: 1,
+		},
+		{
+			name:     "unknown presence",
+			config:   checkConfigBase + checkAccounts + "presence: 'nickname'\n",
+			problems: []string{"targets"},
+			exitCode: 1,
+		},
+		{
+			name: "presence and actions",
+			config: checkConfigBase + checkAccounts + "presence: 'servergroup'\n" +
+				"actions:\n  - type: 'servergroup'\n",
+			problems: []string{"targets"},
+			exitCode: 1,
+		},
+		{
+			name:     "no account pairs",
+			config:   checkConfigBase,
+			problems: []string{"accounts"},
+			exitCode: 1,
+		},
+		{
+			name: "invalid account pair",
+			config: checkConfigBase + checkAccounts + "  - ts: 'uid='\n" +
+				"    twitch: 'streamer'\n    platform: 'mixer'\n",
+			problems: []string{"accounts[1]"},
+			exitCode: 1,
+		},
+		{
+			name:     "unknown twitch login",
+			config:   checkConfigBase + checkAccounts + "  - ts: 'uid='\n    twitch: 'nobody'\n",
+			problems: []string{"twitch nobody"},
+			exitCode: 1,
+		},
+		{
+			name:     "unknown server group",
+			config:   strings.Replace(checkConfigBase, "{servergroupid}", "999", 1) + checkAccounts,
+			problems: []string{"servergroup 999"},
+			exitCode: 1,
+		},
+		{
+			name:     "unknown teamspeak identity",
+			config:   checkConfigBase + "accounts:\n  - ts: 'unknown='\n    twitch: 'streamer'\n",
+			problems: []string{"teamspeak unknown="},
+			exitCode: 1,
+		},
+		{
+			name: "youtube quota",
+			config: checkConfigBase + checkAccounts + "  - ts: 'uid='\n    twitch: 'channel'\n" +
+				"    platform: 'youtube'\nyoutube:\n  apikey: 'key'\n  interval: 1s\n",
+			problems: []string{"youtube.interval"},
+			exitCode: 1,
+		},
+		{
+			name: "youtube without api key",
+			config: checkConfigBase + checkAccounts + "  - ts: 'uid='\n    twitch: 'channel'\n" +
+				"    platform: 'youtube'\n",
+			problems: []string{"accounts[1]"},
+			exitCode: 1,
+		},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			_, err := setupTestConfig(t, replacer.Replace(test.config), map[string]string{}, nil)
+			if test.exitCode == 0 {
+				assert.NoError(t, err, "could not read config")
+			}
+			check := checkConfig()
+			subjects := make([]string, 0, len(check.problems))
+			for _, problem := range check.problems {
+				subjects = append(subjects, problem.subject)
+			}
+			if test.problems == nil {
+				test.problems = []string{}
+			}
+			assert.Equal(t, test.problems, subjects, "unexpected problems: %v", check.problems)
+			assert.Equal(t, test.exitCode, check.print(), "unexpected exit code")
+		})
+	}
+}
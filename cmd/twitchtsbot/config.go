@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	envPrefix        = "TWITCHTSBOT"
+	secretFileSuffix = "_file"
+	// defaultStateDir is the directory next to the config file which the state is persisted in if no state directory
+	// is configured.
+	defaultStateDir = "state"
+)
+
+// secrets contains all config values which have been read from secret files.
+var secrets = newSecretWatcher()
+
+// setupEnvironment makes every config key overridable by an environment variable such as TWITCHTSBOT_TEAMSPEAK_APIKEY.
+func setupEnvironment() {
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+}
+
+// readConfig reads the config file, applies the secret file overrides and loads the separate accounts file if
+// configured.
+func readConfig() error {
+	viper.SetConfigFile(*configPath)
+	if err := viper.ReadInConfig(); err != nil {
+		return err
+	}
+	if err := secrets.load(); err != nil {
+		return err
+	}
+	return loadAccountsFile()
+}
+
+// loadAccountsFile replaces the accounts of the main config with the ones from the accounts file if it is set.
+func loadAccountsFile() error {
+	path := viper.GetString("accountsfile")
+	if path == "" {
+		return nil
+	}
+	accountsConfig := viper.New()
+	accountsConfig.SetConfigFile(path)
+	if err := accountsConfig.ReadInConfig(); err != nil {
+		return err
+	}
+	viper.Set("accounts", accountsConfig.Get("accounts"))
+	return nil
+}
+
+// writeAccountsConfig writes the account pairs of the named target to the main config file. If no target is given, the
+// top level account pairs are written either to the accounts file if it is set or to the main config file. Only the raw
+// file content is rewritten so that neither defaults nor environment and secret file overrides are persisted.
+func writeAccountsConfig(targetName string, accounts []*accountEntry) error {
+	path := *configPath
+	if targetName == defaultTargetName && !viper.IsSet("targets") {
+		targetName = ""
+	}
+	if targetName == "" {
+		viper.Set("accounts", accounts)
+		if accountsFile := viper.GetString("accountsfile"); accountsFile != "" {
+			path = accountsFile
+		}
+	}
+	fileConfig := viper.New()
+	fileConfig.SetConfigFile(path)
+	if err := fileConfig.ReadInConfig(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if targetName == "" {
+		fileConfig.Set("accounts", accounts)
+		return fileConfig.WriteConfigAs(path)
+	}
+	targets, _ := fileConfig.Get("targets").([]interface{})
+	for i, target := range targets {
+		var entry map[string]interface{}
+		switch target := target.(type) {
+		case map[string]interface{}:
+			entry = target
+		case map[interface{}]interface{}:
+			entry = make(map[string]interface{}, len(target))
+			for key, value := range target {
+				entry[fmt.Sprint(key)] = value
+			}
+		default:
+			continue
+		}
+		name, _ := entry["name"].(string)
+		if name == "" {
+			name = "target" + strconv.Itoa(i)
+		}
+		if name != targetName {
+			continue
+		}
+		entry["accounts"] = accounts
+		targets[i] = entry
+		fileConfig.Set("targets", targets)
+		if err := fileConfig.WriteConfigAs(path); err != nil {
+			return err
+		}
+		// secret files of the targets are read again by loadTargets
+		viper.Set("targets", targets)
+		return nil
+	}
+	return fmt.Errorf("unknown teamspeak target: %s", targetName)
+}
+
+// stateDirectory returns the directory configured by the given key such as "queue.statedir" and creates it if it does
+// not exist yet. If the key is not set, the directory "state" next to the config file is used so that the state
+// survives a restart without further configuration.
+func stateDirectory(key string) (string, error) {
+	dir := viper.GetString(key)
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(*configPath), defaultStateDir)
+	}
+	return dir, os.MkdirAll(dir, 0700)
+}
+
+type secretFile struct {
+	key   string
+	path  string
+	value []byte
+}
+
+// secretWatcher reads config values from files which are referenced by either a "<key>_file" config key or the
+// corresponding environment variable (e.g. TWITCHTSBOT_TEAMSPEAK_APIKEY_FILE) and re-reads them on rotation. The
+// rotated values are kept by the watcher, as viper must not be changed while other goroutines read the config.
+type secretWatcher struct {
+	*sync.Mutex
+	files     []*secretFile
+	callbacks map[string][]func(value string)
+}
+
+func newSecretWatcher() *secretWatcher {
+	return &secretWatcher{Mutex: &sync.Mutex{}, callbacks: make(map[string][]func(value string))}
+}
+
+// load reads the secret files of all config keys into the config. It is called while the config is read, before any
+// other goroutine accesses it.
+func (watcher *secretWatcher) load() error {
+	watcher.Lock()
+	defer watcher.Unlock()
+	watcher.files = nil
+	for _, key := range viper.AllKeys() {
+		if strings.HasSuffix(key, secretFileSuffix) {
+			continue
+		}
+		// also checks the environment variable such as TWITCHTSBOT_TEAMSPEAK_APIKEY_FILE
+		path := viper.GetString(key + secretFileSuffix)
+		if path == "" {
+			continue
+		}
+		value, err := readSecretFile(path)
+		if err != nil {
+			return err
+		}
+		viper.Set(key, string(value))
+		watcher.files = append(watcher.files, &secretFile{key: key, path: path, value: value})
+	}
+	return nil
+}
+
+// Watch reads the secret file at the given path and watches it for rotations under the given key. It is used for the
+// secret files which are not referenced by a config key, such as the ones of the targets.
+func (watcher *secretWatcher) Watch(key, path string) (string, error) {
+	value, err := readSecretFile(path)
+	if err != nil {
+		return "", err
+	}
+	watcher.Lock()
+	defer watcher.Unlock()
+	for _, file := range watcher.files {
+		if file.key == key {
+			file.path, file.value = path, value
+			redactedSecrets.Add(string(value))
+			return string(value), nil
+		}
+	}
+	watcher.files = append(watcher.files, &secretFile{key: key, path: path, value: value})
+	redactedSecrets.Add(string(value))
+	return string(value), nil
+}
+
+// Get returns the current value of the config key, which differs from the config once its secret file is rotated.
+func (watcher *secretWatcher) Get(key string) string {
+	watcher.Lock()
+	defer watcher.Unlock()
+	for _, file := range watcher.files {
+		if file.key == key {
+			return string(file.value)
+		}
+	}
+	return viper.GetString(key)
+}
+
+// OnChange registers a callback which is called with the new value as soon as the secret file of the key is rotated.
+func (watcher *secretWatcher) OnChange(key string, callback func(value string)) {
+	watcher.Lock()
+	defer watcher.Unlock()
+	watcher.callbacks[key] = append(watcher.callbacks[key], callback)
+}
+
+// Start periodically checks all secret files for changes until the context is done.
+func (watcher *secretWatcher) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		for {
+			select {
+			case <-time.After(interval):
+				watcher.refresh()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (watcher *secretWatcher) refresh() {
+	watcher.Lock()
+	defer watcher.Unlock()
+	for _, file := range watcher.files {
+		value, err := readSecretFile(file.path)
+		if err != nil {
+			logrus.WithError(err).WithField("key", file.key).Warnln("Could not re-read secret file.")
+			continue
+		}
+		if bytes.Equal(value, file.value) {
+			continue
+		}
+		file.value = value
+		redactedSecrets.Add(string(value))
+		callbacks := watcher.callbacks[file.key]
+		logrus.WithFields(logrus.Fields{"key": file.key, "callbackAmount": len(callbacks)}).
+			Infoln("Secret file has been rotated.")
+		if len(callbacks) == 0 {
+			logrus.WithField("key", file.key).Warnln("Rotated secret is only applied after a restart.")
+		}
+		for _, callback := range callbacks {
+			callback(string(value))
+		}
+	}
+}
+
+func readSecretFile(path string) ([]byte, error) {
+	value, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(value), nil
+}
@@ -1,22 +1,85 @@
 package main
 
 import (
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/logging"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/teamspeak"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/youtube"
 	"github.com/spf13/viper"
+	"strings"
 	"time"
 )
 
 type accountEntry struct {
 	TsIdentifier   string `mapstructure:"ts" yaml:"ts"`
 	TwitchUsername string `mapstructure:"twitch" yaml:"twitch"`
+	// Platform sets the streaming platform of the account and defaults to twitch.
+	Platform string `mapstructure:"platform" yaml:"platform,omitempty"`
+	// Filter replaces the global stream filter for the channel of the account.
+	Filter *streamFilterConfig `mapstructure:"filter" yaml:"filter,omitempty"`
+}
+
+func (entry *accountEntry) channel() twitch.Channel {
+	platform := strings.ToLower(entry.Platform)
+	if platform == "" {
+		platform = twitch.PlatformTwitch
+	}
+	return twitch.Channel{Platform: platform, Login: entry.TwitchUsername}
 }
 
 func setConfigDefaults() {
 	viper.SetDefault("teamspeak.url", "<yourbaseurl>")
 	viper.SetDefault("teamspeak.apikey", "<yourapikey>")
 	viper.SetDefault("teamspeak.serverid", 1)
+	viper.SetDefault("teamspeak.queryaddress", "")
+	viper.SetDefault("teamspeak.queryuser", defaultQueryUser)
+	viper.SetDefault("teamspeak.querypassword", "")
 	viper.SetDefault("twitch.clientid", "<yourclientid>")
 	viper.SetDefault("twitch.appaccesstoken", "<yourtoken>")
+	viper.SetDefault("youtube.apikey", "")
+	viper.SetDefault("youtube.interval", youtube.DefaultInterval)
+	viper.SetDefault("subscriptions.accesstoken", "")
+	viper.SetDefault("subscriptions.interval", 5*time.Minute)
+	viper.SetDefault("subscriptions.servergroups", map[string]int{})
 	viper.SetDefault("accounts", []accountEntry{})
 	viper.SetDefault("interval", time.Second)
+	viper.SetDefault("maxbackoff", twitch.DefaultMaxBackoff)
+	viper.SetDefault("monitor.statedir", "")
+	viper.SetDefault("monitor.maxstateage", 10*time.Minute)
+	viper.SetDefault("streamfilter.includereruns", false)
+	viper.SetDefault("streamfilter.minuptime", "")
+	viper.SetDefault("streamfilter.excludecategories", []string{})
+	viper.SetDefault("streamfilter.excludetitles", []string{})
+	viper.SetDefault("streamfilter.excludemature", false)
+	viper.SetDefault("audit.file", "")
+	viper.SetDefault("history.file", "")
+	viper.SetDefault("history.retention", 90*24*time.Hour)
+	viper.SetDefault("history.sampleinterval", time.Minute)
 	viper.SetDefault("servergroupid", -1)
+	viper.SetDefault("authoritative", false)
+	viper.SetDefault("adoptmembers", false)
+	viper.SetDefault("actions", []actionConfig{})
+	viper.SetDefault("description.statedir", "")
+	viper.SetDefault("dryrun", false)
+	viper.SetDefault("log.level", "")
+	viper.SetDefault("log.format", logging.FormatText)
+	viper.SetDefault("log.file", "")
+	viper.SetDefault("log.maxsize", 100)
+	viper.SetDefault("log.maxbackups", 3)
+	viper.SetDefault("log.levels", map[string]string{})
+	viper.SetDefault("admin.listen", "")
+	viper.SetDefault("admin.password", "")
+	viper.SetDefault("admin.token", "")
+	viper.SetDefault("accountsfile", "")
+	viper.SetDefault("secretrefreshinterval", time.Minute)
+	viper.SetDefault("queue.statedir", "")
+	viper.SetDefault("queue.basedelay", teamspeak.DefaultQueueBaseDelay)
+	viper.SetDefault("queue.maxdelay", teamspeak.DefaultQueueMaxDelay)
+	viper.SetDefault("queue.membershiprefresh", teamspeak.DefaultMembershipRefreshInterval)
+	viper.SetDefault("shutdown.timeout", 30*time.Second)
+	viper.SetDefault("shutdown.removegroups", false)
+	viper.SetDefault("tracing.exporter", "")
+	viper.SetDefault("tracing.endpoint", "")
+	viper.SetDefault("tracing.insecure", false)
+	viper.SetDefault("tracing.sampleratio", 1.0)
 }
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/teamspeak"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/testutil/ts3test"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupTestConfig resets the config, writes the given files into a temporary directory and reads the config file
+// from it. The placeholder {dir} is replaced by the directory in the config, the files and the environment variables.
+func setupTestConfig(t *testing.T, config string, files, env map[string]string) (string, error) {
+	dir, err := ioutil.TempDir("", "twitchtsbot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	files["config.yml"] = config
+	for name, content := range files {
+		content = strings.ReplaceAll(content, "{dir}", dir)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for key, value := range env {
+		assert.NoError(t, os.Setenv(key, strings.ReplaceAll(value, "{dir}", dir)))
+		key := key
+		t.Cleanup(func() {
+			_ = os.Unsetenv(key)
+		})
+	}
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	secrets = newSecretWatcher()
+	setConfigDefaults()
+	setupEnvironment()
+	*configPath = filepath.Join(dir, "config.yml")
+	return dir, readConfig()
+}
+
+func TestReadConfig(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		config   string
+		files    map[string]string
+		env      map[string]string
+		key      string
+		expected string
+	}{
+		{
+			name:     "config value",
+			config:   "teamspeak:\n  apikey: 'configured'\n",
+			key:      "teamspeak.apikey",
+			expected: "configured",
+		},
+		{
+			name:     "default value",
+			key:      "teamspeak.queryuser",
+			expected: defaultQueryUser,
+		},
+		{
+			name:     "environment override",
+			config:   "teamspeak:\n  apikey: 'configured'\n",
+			env:      map[string]string{"TWITCHTSBOT_TEAMSPEAK_APIKEY": "environment"},
+			key:      "teamspeak.apikey",
+			expected: "environment",
+		},
+		{
+			name:     "secret file",
+			config:   "teamspeak:\n  apikey: 'configured'\n  apikey_file: '{dir}/apikey'\n",
+			files:    map[string]string{"apikey": "secret"},
+			key:      "teamspeak.apikey",
+			expected: "secret",
+		},
+		{
+			name:     "secret file is trimmed",
+			config:   "twitch:\n  appaccesstoken_file: '{dir}/token'\n",
+			files:    map[string]string{"token": "  token\n\n"},
+			key:      "twitch.appaccesstoken",
+			expected: "token",
+		},
+		{
+			name:     "secret file from environment",
+			env:      map[string]string{"TWITCHTSBOT_TWITCH_APPACCESSTOKEN_FILE": "{dir}/token"},
+			files:    map[string]string{"token": "environment token\n"},
+			key:      "twitch.appaccesstoken",
+			expected: "environment token",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if test.files == nil {
+				test.files = make(map[string]string)
+			}
+			_, err := setupTestConfig(t, test.config, test.files, test.env)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, viper.GetString(test.key))
+			assert.Equal(t, test.expected, secrets.Get(test.key))
+		})
+	}
+}
+
+func TestReadConfig_Errors(t *testing.T) {
+	_, err := setupTestConfig(t, "teamspeak:\n  apikey_file: '{dir}/missing'\n", map[string]string{}, nil)
+	assert.Error(t, err, "expected a missing secret file to fail")
+	_, err = setupTestConfig(t, "accountsfile: '{dir}/missing.yml'\n", map[string]string{}, nil)
+	assert.Error(t, err, "expected a missing accounts file to fail")
+}
+
+func TestLoadAccountsFile(t *testing.T) {
+	config := "accountsfile: '{dir}/accounts.yml'\naccounts:\n  - ts: '1'\n    twitch: 'ignored'\n"
+	accounts := "accounts:\n  - ts: '2'\n    twitch: 'streamer'\n    platform: 'youtube'\n"
+	_, err := setupTestConfig(t, config, map[string]string{"accounts.yml": accounts}, nil)
+	assert.NoError(t, err)
+	targets, err := loadTargets()
+	assert.NoError(t, err)
+	if assert.Len(t, targets, 1) {
+		assert.Equal(t, []*accountEntry{{TsIdentifier: "2", TwitchUsername: "streamer", Platform: "youtube"}},
+			targets[0].Accounts, "expected the accounts of the accounts file to replace the configured ones")
+	}
+}
+
+func TestSecretWatcher_Refresh(t *testing.T) {
+	config := `teamspeak:
+  apikey_file: '{dir}/apikey'
+targets:
+  - name: 'staff'
+    url: 'http://localhost'
+    apikey_file: '{dir}/staff_apikey'
+    querypassword_file: '{dir}/staff_querypassword'
+`
+	files := map[string]string{"apikey": "first", "staff_apikey": "staff first\n", "staff_querypassword": "query first"}
+	dir, err := setupTestConfig(t, config, files, nil)
+	assert.NoError(t, err)
+	targets, err := loadTargets()
+	assert.NoError(t, err)
+	if assert.Len(t, targets, 1) {
+		assert.Equal(t, "staff first", targets[0].APIKey)
+		assert.Equal(t, "query first", targets[0].queryPassword())
+	}
+	rotated := make(map[string][]string)
+	for _, key := range []string{"teamspeak.apikey", "targets[staff].apikey"} {
+		key := key
+		secrets.OnChange(key, func(value string) {
+			rotated[key] = append(rotated[key], value)
+		})
+	}
+
+	secrets.refresh()
+	assert.Empty(t, rotated, "expected unchanged secret files to not be applied")
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "apikey"), []byte("second\n"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "staff_apikey"), []byte("staff second"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "staff_querypassword"), []byte("query second"), 0600))
+	secrets.refresh()
+	secrets.refresh()
+	assert.Equal(t, map[string][]string{
+		"teamspeak.apikey":      {"second"},
+		"targets[staff].apikey": {"staff second"},
+	}, rotated, "expected every rotation to be applied once")
+	assert.Equal(t, "second", secrets.Get("teamspeak.apikey"))
+	assert.Equal(t, "staff second", secrets.Get("targets[staff].apikey"))
+	assert.Equal(t, "query second", targets[0].queryPassword(), "expected the rotated query password to be used")
+	assert.Equal(t, "first", viper.GetString("teamspeak.apikey"), "expected rotations to not change the config")
+	for _, value := range []string{"staff first", "second", "staff second", "query first", "query second"} {
+		assert.Contains(t, redactedSecrets.Get(), value, "expected rotated secrets to be redacted")
+	}
+
+	// a missing file keeps the last value
+	assert.NoError(t, os.Remove(filepath.Join(dir, "apikey")))
+	secrets.refresh()
+	assert.Equal(t, "second", secrets.Get("teamspeak.apikey"))
+}
+
+func TestTeamspeakTarget_AdoptMemberships(t *testing.T) {
+	tsServer, err := ts3test.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tsServer.Close()
+	liveGroupId := tsServer.AddServerGroup("Live")
+	subscriberGroupId := tsServer.AddServerGroup("Subscriber")
+	pairedDbId := tsServer.AddClient("paired=", "paired")
+	otherDbId := tsServer.AddClient("other=", "other")
+	for _, serverGroupId := range []int{liveGroupId, subscriberGroupId} {
+		tsServer.SetMember(serverGroupId, pairedDbId, true)
+		tsServer.SetMember(serverGroupId, otherDbId, true)
+	}
+	for _, adopt := range []bool{false, true} {
+		config := fmt.Sprintf("adoptmembers: %t\ntargets:\n  - name: 'default'\n    servergroupid: %d\n"+
+			"    subscriptionservergroups:\n      '1000': %d\n", adopt, liveGroupId, subscriberGroupId)
+		_, err := setupTestConfig(t, config, map[string]string{}, nil)
+		assert.NoError(t, err)
+		targets, err := loadTargets()
+		assert.NoError(t, err)
+		target := targets[0]
+		target.client = teamspeak.NewWebQueryClient(tsServer.URL, tsServer.APIKey, 1)
+		target.pairs = map[int]twitch.Channel{pairedDbId: {Platform: twitch.PlatformTwitch, Login: "paired"}}
+		ownership, err := teamspeak.NewOwnership(filepath.Join(t.TempDir(), "ownership.json"))
+		assert.NoError(t, err)
+
+		target.adoptMemberships(ownership)
+		for _, serverGroupId := range []int{liveGroupId, subscriberGroupId} {
+			assert.Equalf(t, adopt, ownership.Owns(serverGroupId, pairedDbId),
+				"expected the membership of the account pair to be adopted only if enabled (adopt: %t)", adopt)
+			assert.False(t, ownership.Owns(serverGroupId, otherDbId), "expected other members to never be adopted")
+		}
+		assert.Equal(t, !adopt, ownership.Fresh(), "expected the adoption to be persisted")
+	}
+}
+
+func TestStateDirectory(t *testing.T) {
+	dir, err := setupTestConfig(t, "queue:\n  statedir: '{dir}/queue'\n", map[string]string{}, nil)
+	assert.NoError(t, err)
+	for key, expected := range map[string]string{
+		"queue.statedir":       filepath.Join(dir, "queue"),
+		"description.statedir": filepath.Join(dir, defaultStateDir),
+	} {
+		stateDir, err := stateDirectory(key)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, stateDir)
+		info, err := os.Stat(stateDir)
+		if assert.NoError(t, err, "expected the state directory to be created") {
+			assert.True(t, info.IsDir())
+		}
+	}
+}
+
+func TestLoadTargets_Presence(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		config   string
+		expected []*actionConfig
+		err      bool
+	}{
+		{
+			name:     "no presence",
+			config:   "servergroupid: 42\n",
+			expected: []*actionConfig{{Type: actionServerGroup, ServerGroupID: 42}},
+		},
+		{
+			name:     "servergroup presence",
+			config:   "servergroupid: 42\npresence: 'servergroup'\n",
+			expected: []*actionConfig{{Type: actionServerGroup, ServerGroupID: 42}},
+		},
+		{
+			name:     "description presence",
+			config:   "servergroupid: 42\npresence: 'description'\ndescription:\n  format: 'LIVE %s'\n",
+			expected: []*actionConfig{{Type: actionDescription, Format: "LIVE %s"}},
+		},
+		{
+			name:     "description presence of a target",
+			config:   "targets:\n  - name: 'staff'\n    presence: 'description'\n",
+			expected: []*actionConfig{{Type: actionDescription}},
+		},
+		{
+			name:     "description format of an action",
+			config:   "actions:\n  - type: 'description'\ndescription:\n  format: 'LIVE %s'\n",
+			expected: []*actionConfig{{Type: actionDescription, Format: "LIVE %s"}},
+		},
+		{
+			name:   "unknown presence",
+			config: "presence: 'icon'\n",
+			err:    true,
+		},
+		{
+			name:   "presence and actions",
+			config: "presence: 'description'\nactions:\n  - type: 'servergroup'\n",
+			err:    true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := setupTestConfig(t, test.config, map[string]string{}, nil)
+			assert.NoError(t, err)
+			targets, err := loadTargets()
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) && assert.Len(t, targets, 1) {
+				assert.Equal(t, test.expected, targets[0].actionConfigs())
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/testutil/helixtest"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/testutil/ts3test"
+	"github.com/nicklaw5/helix"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const e2eConfig = `teamspeak:
+  url: '%s'
+  apikey: '%s'
+  serverid: 1
+  queryaddress: '%s'
+  querypassword: '%s'
+twitch:
+  clientid: '%s'
+  appaccesstoken: '%s'
+servergroupid: %d
+interval: 10ms
+adoptmembers: true
+queue:
+  basedelay: 10ms
+accounts:
+  - ts: 'uid='
+    twitch: 'streamer'
+    filter:
+      excludetitles: ['[rerun]']
+`
+
+// TestDaemon_EndToEnd runs the wiring of the daemon against a fake Twitch API and a fake TeamSpeak server.
+func TestDaemon_EndToEnd(t *testing.T) {
+	helixServer := helixtest.NewServer()
+	defer helixServer.Close()
+	helixServer.AddUser("streamer")
+	tsServer, err := ts3test.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tsServer.Close()
+	serverGroupId := tsServer.AddServerGroup("Live")
+	clientDbId := tsServer.AddClient("uid=", "streamer")
+	// the membership has been granted before the grants were recorded
+	tsServer.SetMember(serverGroupId, clientDbId, true)
+
+	dir, err := ioutil.TempDir("", "twitchtsbot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	*configPath = filepath.Join(dir, "config.yml")
+	config := fmt.Sprintf(e2eConfig, tsServer.URL, tsServer.APIKey, tsServer.QueryAddress, tsServer.QueryPassword,
+		helixServer.ClientID, helixServer.IssueAppToken(), serverGroupId)
+	assert.NoError(t, ioutil.WriteFile(*configPath, []byte(config), 0600))
+	defaultHTTPClient := twitchHTTPClient
+	twitchHTTPClient = helixServer.HTTPClient()
+	defer func() {
+		twitchHTTPClient = defaultHTTPClient
+	}()
+	setConfigDefaults()
+	loadConfig()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	running := startDaemon(ctx)
+
+	assert.Eventually(t, func() bool {
+		return !tsServer.IsMember(serverGroupId, clientDbId)
+	}, 5*time.Second, 10*time.Millisecond, "expected the adopted membership of the offline streamer to be removed")
+
+	// streams which are excluded by the filter of the account do not count as live
+	helixServer.SetLive("streamer", helix.Stream{Title: "[Rerun] title"})
+	assert.Never(t, func() bool {
+		return tsServer.IsMember(serverGroupId, clientDbId)
+	}, 200*time.Millisecond, 10*time.Millisecond, "expected excluded stream to not grant the server group")
+
+	helixServer.SetLive("streamer", helix.Stream{Title: "title"})
+	assert.Eventually(t, func() bool {
+		return tsServer.IsMember(serverGroupId, clientDbId)
+	}, 5*time.Second, 10*time.Millisecond, "expected live streamer to get the server group")
+
+	// the server group is added again as soon as the live streamer connects
+	tsServer.SetMember(serverGroupId, clientDbId, false)
+	assert.True(t, tsServer.Connect(clientDbId))
+	assert.Eventually(t, func() bool {
+		return tsServer.IsMember(serverGroupId, clientDbId)
+	}, 5*time.Second, 10*time.Millisecond, "expected connecting live streamer to get the server group")
+
+	helixServer.FailNext(http.StatusInternalServerError, 2)
+	helixServer.SetOffline("streamer")
+	assert.Eventually(t, func() bool {
+		return !tsServer.IsMember(serverGroupId, clientDbId)
+	}, 5*time.Second, 10*time.Millisecond, "expected offline streamer to lose the server group")
+
+	// the granted server group is removed on shutdown if enabled
+	helixServer.SetLive("streamer", helix.Stream{Title: "title"})
+	assert.Eventually(t, func() bool {
+		return tsServer.IsMember(serverGroupId, clientDbId)
+	}, 5*time.Second, 10*time.Millisecond, "expected live streamer to get the server group again")
+	viper.Set("shutdown.removegroups", true)
+	defer viper.Set("shutdown.removegroups", false)
+	assert.Equal(t, 0, running.shutdown(5*time.Second))
+	assert.False(t, tsServer.IsMember(serverGroupId, clientDbId), "expected the server group to be removed on shutdown")
+}
+
+const e2eReconnectConfig = `twitch:
+  clientid: '%s'
+  appaccesstoken: '%s'
+interval: 10ms
+queue:
+  basedelay: 10ms
+targets:
+  - name: 'reachable'
+    url: '%s'
+    apikey: '%s'
+    queryaddress: '%s'
+    servergroupid: %d
+    accounts:
+      - ts: 'uid='
+        twitch: 'streamer'
+  - name: 'unreachable'
+    url: '%s'
+    apikey: '%s'
+    servergroupid: %d
+    accounts:
+      - ts: 'uid='
+        twitch: 'streamer'
+`
+
+// TestDaemon_Reconnect checks that a target which is not reachable on startup does not keep the others from running
+// and is started once it becomes reachable. An unreachable ServerQuery does not keep a target from running either.
+func TestDaemon_Reconnect(t *testing.T) {
+	helixServer := helixtest.NewServer()
+	defer helixServer.Close()
+	helixServer.AddUser("streamer")
+	tsServers := make([]*ts3test.Server, 0, 2)
+	serverGroupIds := make([]int, 0, 2)
+	clientDbIds := make([]int, 0, 2)
+	for i := 0; i < 2; i++ {
+		tsServer, err := ts3test.NewServer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tsServer.Close()
+		tsServers = append(tsServers, tsServer)
+		serverGroupIds = append(serverGroupIds, tsServer.AddServerGroup("Live"))
+		clientDbIds = append(clientDbIds, tsServer.AddClient("uid=", "streamer"))
+	}
+	tsServers[1].FailNext("version", 3)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	closedAddress := listener.Addr().String()
+	assert.NoError(t, listener.Close())
+
+	dir, err := ioutil.TempDir("", "twitchtsbot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	*configPath = filepath.Join(dir, "config.yml")
+	config := fmt.Sprintf(e2eReconnectConfig, helixServer.ClientID, helixServer.IssueAppToken(),
+		tsServers[0].URL, tsServers[0].APIKey, closedAddress, serverGroupIds[0], tsServers[1].URL, tsServers[1].APIKey,
+		serverGroupIds[1])
+	assert.NoError(t, ioutil.WriteFile(*configPath, []byte(config), 0600))
+	defaultHTTPClient := twitchHTTPClient
+	twitchHTTPClient = helixServer.HTTPClient()
+	defer func() {
+		twitchHTTPClient = defaultHTTPClient
+	}()
+	viper.Reset()
+	defer viper.Reset()
+	secrets = newSecretWatcher()
+	setConfigDefaults()
+	loadConfig()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	running := startDaemon(ctx)
+
+	helixServer.SetLive("streamer", helix.Stream{Title: "title"})
+	assert.Eventually(t, func() bool {
+		return tsServers[0].IsMember(serverGroupIds[0], clientDbIds[0])
+	}, 5*time.Second, 10*time.Millisecond, "expected the reachable target to grant the server group")
+	assert.Eventually(t, func() bool {
+		return tsServers[1].IsMember(serverGroupIds[1], clientDbIds[1])
+	}, 5*time.Second, 10*time.Millisecond, "expected the target to grant the server group once it is reachable")
+	assert.Equal(t, 4, tsServers[1].Requests("version"), "expected the target to be connected in the background")
+	assert.Equal(t, 0, running.shutdown(5*time.Second))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/spf13/viper"
+	"strings"
+	"time"
+)
+
+// streamFilterConfig is the config of a stream filter. It is used for the global streamfilter key as well as for the
+// filters of single accounts.
+type streamFilterConfig struct {
+	IncludeReruns bool `mapstructure:"includereruns" yaml:"includereruns,omitempty"`
+	// MinUptime is a duration such as 5m. It is kept as a string so that the accounts are written back as configured.
+	MinUptime         string   `mapstructure:"minuptime" yaml:"minuptime,omitempty"`
+	ExcludeCategories []string `mapstructure:"excludecategories" yaml:"excludecategories,omitempty"`
+	ExcludeTitles     []string `mapstructure:"excludetitles" yaml:"excludetitles,omitempty"`
+	ExcludeMature     bool     `mapstructure:"excludemature" yaml:"excludemature,omitempty"`
+}
+
+func (config *streamFilterConfig) filter() (*twitch.StreamFilter, error) {
+	filter := &twitch.StreamFilter{
+		IncludeReruns:     config.IncludeReruns,
+		ExcludeCategories: config.ExcludeCategories,
+		ExcludeTitles:     config.ExcludeTitles,
+		ExcludeMature:     config.ExcludeMature,
+	}
+	if config.MinUptime != "" {
+		minUptime, err := time.ParseDuration(config.MinUptime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid minuptime: %w", err)
+		}
+		if minUptime < 0 {
+			return nil, fmt.Errorf("minuptime has to be positive")
+		}
+		filter.MinUptime = minUptime
+	}
+	return filter, nil
+}
+
+func globalStreamFilter() (*twitch.StreamFilter, error) {
+	var config streamFilterConfig
+	if err := viper.UnmarshalKey("streamfilter", &config); err != nil {
+		return nil, err
+	}
+	return config.filter()
+}
+
+// applyStreamFilters sets the global stream filter and the filters of the accounts of all targets on the monitors of
+// their platforms. If a channel is configured with a filter in several targets, the filter of the first one is used.
+func applyStreamFilters(monitors map[string]*twitch.Monitor, targets []*teamspeakTarget) error {
+	filter, err := globalStreamFilter()
+	if err != nil {
+		return fmt.Errorf("streamfilter: %w", err)
+	}
+	for _, monitor := range monitors {
+		monitor.Filter = filter
+		monitor.Filters = make(map[string]*twitch.StreamFilter)
+	}
+	for _, target := range targets {
+		for _, account := range target.Accounts {
+			channel := account.channel()
+			monitor, ok := monitors[channel.Platform]
+			if account.Filter == nil || !ok {
+				continue
+			}
+			login := strings.ToLower(channel.Login)
+			if _, ok := monitor.Filters[login]; ok {
+				continue
+			}
+			accountFilter, err := account.Filter.filter()
+			if err != nil {
+				return fmt.Errorf("%s: filter of account %s: %w", target.Name, channel, err)
+			}
+			monitor.Filters[login] = accountFilter
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runInit creates a new config file by interactively asking for all required values.
+func runInit(args []string) int {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	force := flags.Bool("force", false, "Overwrite an existing config file.")
+	_ = flags.Parse(args)
+	if _, err := os.Stat(*configPath); err == nil && !*force {
+		logrus.WithField("configPath", *configPath).Errorln("Config file already exists. Use -force to overwrite it.")
+		return 1
+	}
+	if err := runInitWizard(os.Stdin, os.Stdout); err != nil {
+		logrus.WithError(err).Errorln("Could not complete config wizard.")
+		return 1
+	}
+	if err := viper.WriteConfigAs(*configPath); err != nil {
+		logrus.WithError(err).WithField("configPath", *configPath).Errorln("Could not write config file.")
+		return 1
+	}
+	logrus.WithField("configPath", *configPath).Infoln("Written config file. Run the check command to validate it.")
+	return 0
+}
+
+type wizardQuestion struct {
+	key      string
+	question string
+}
+
+var (
+	wizardStringQuestions = []wizardQuestion{
+		{key: "teamspeak.url", question: "TeamSpeak WebQuery URL"},
+		{key: "teamspeak.apikey", question: "TeamSpeak WebQuery API key"},
+		{key: "twitch.clientid", question: "Twitch client id"},
+		{key: "twitch.appaccesstoken", question: "Twitch App Access Token"},
+	}
+	wizardIntQuestions = []wizardQuestion{
+		{key: "teamspeak.serverid", question: "TeamSpeak virtual server id"},
+		{key: "servergroupid", question: "Live server group id"},
+	}
+)
+
+type wizard struct {
+	reader *bufio.Reader
+	out    io.Writer
+}
+
+func runInitWizard(in io.Reader, out io.Writer) error {
+	w := &wizard{reader: bufio.NewReader(in), out: out}
+	for _, question := range wizardStringQuestions {
+		value, err := w.ask(question.question, "")
+		if err != nil {
+			return err
+		}
+		viper.Set(question.key, value)
+	}
+	for _, question := range wizardIntQuestions {
+		value, err := w.askInt(question.question, viper.GetInt(question.key))
+		if err != nil {
+			return err
+		}
+		viper.Set(question.key, value)
+	}
+	interval, err := w.askDuration("Twitch API retrieve interval", viper.GetDuration("interval"))
+	if err != nil {
+		return err
+	}
+	viper.Set("interval", interval.String())
+	accounts := make([]*accountEntry, 0)
+	_, _ = fmt.Fprintln(out, "Add account pairs. Leave the TeamSpeak identifier empty to finish.")
+	for {
+		identifier, err := w.ask("TeamSpeak UID or database id", "")
+		if err != nil {
+			return err
+		}
+		if identifier == "" {
+			break
+		}
+		login, err := w.ask("Channel login", "")
+		if err != nil {
+			return err
+		}
+		platform, err := w.ask("Streaming platform", "twitch")
+		if err != nil {
+			return err
+		}
+		accounts = append(accounts, &accountEntry{TsIdentifier: identifier, TwitchUsername: login, Platform: platform})
+	}
+	viper.Set("accounts", accounts)
+	return nil
+}
+
+func (w *wizard) ask(question, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		_, _ = fmt.Fprintf(w.out, "%s [%s]: ", question, defaultValue)
+	} else {
+		_, _ = fmt.Fprintf(w.out, "%s: ", question)
+	}
+	line, err := w.reader.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	if line = strings.TrimSpace(line); line == "" {
+		return defaultValue, nil
+	}
+	return line, nil
+}
+
+func (w *wizard) askInt(question string, defaultValue int) (int, error) {
+	for {
+		answer, err := w.ask(question, strconv.Itoa(defaultValue))
+		if err != nil {
+			return 0, err
+		}
+		value, err := strconv.Atoi(answer)
+		if err == nil {
+			return value, nil
+		}
+		_, _ = fmt.Fprintln(w.out, "Please enter a number.")
+	}
+}
+
+func (w *wizard) askDuration(question string, defaultValue time.Duration) (time.Duration, error) {
+	for {
+		answer, err := w.ask(question, defaultValue.String())
+		if err != nil {
+			return 0, err
+		}
+		value, err := time.ParseDuration(answer)
+		if err == nil {
+			return value, nil
+		}
+		_, _ = fmt.Fprintln(w.out, "Please enter a duration such as 1s or 10m.")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunInitWizard(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		input    string
+		expected map[string]interface{}
+		accounts []*accountEntry
+		err      bool
+	}{
+		{
+			name:  "defaults",
+			input: "http://localhost:10080\napikey\nclientid\ntoken\n\n\n\n\n",
+			expected: map[string]interface{}{
+				"teamspeak.url":         "http://localhost:10080",
+				"teamspeak.apikey":      "apikey",
+				"twitch.clientid":       "clientid",
+				"twitch.appaccesstoken": "token",
+				"teamspeak.serverid":    1,
+				"interval":              time.Second.String(),
+			},
+			accounts: []*accountEntry{},
+		},
+		{
+			name:  "invalid answers are asked again",
+			input: "url\napikey\nclientid\ntoken\none\n2\n42\nsoon\n1m\n\n",
+			expected: map[string]interface{}{
+				"teamspeak.serverid": 2,
+				"servergroupid":      42,
+				"interval":           time.Minute.String(),
+			},
+			accounts: []*accountEntry{},
+		},
+		{
+			name:  "account pairs",
+			input: "url\napikey\nclientid\ntoken\n\n\n\nuid1\nstreamer1\n\nuid2\nstreamer2\nyoutube\n\n",
+			accounts: []*accountEntry{
+				{TsIdentifier: "uid1", TwitchUsername: "streamer1", Platform: "twitch"},
+				{TsIdentifier: "uid2", TwitchUsername: "streamer2", Platform: "youtube"},
+			},
+		},
+		{
+			name:  "unfinished account pairs",
+			input: "url\napikey\nclientid\ntoken\n\n\n\nuid1\nstreamer1\n\n",
+			err:   true,
+		},
+		{
+			name:  "incomplete input",
+			input: "url\napikey\n",
+			err:   true,
+		},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			setConfigDefaults()
+			err := runInitWizard(strings.NewReader(test.input), ioutil.Discard)
+			if test.err {
+				assert.Error(t, err, "expected the wizard to fail")
+				return
+			}
+			if !assert.NoError(t, err, "could not complete the wizard") {
+				return
+			}
+			for key, value := range test.expected {
+				assert.Equal(t, value, viper.Get(key), "invalid value of %s", key)
+			}
+			assert.Equal(t, test.accounts, viper.Get("accounts"), "invalid account pairs")
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+)
+
+// stateLockFile is the name of the file in the queue state directory which is locked by the daemon and the sync command.
+const stateLockFile = "twitchtsbot.lock"
+
+// errStateLocked is returned if the state directory is locked by another instance.
+var errStateLocked = errors.New("the state directory is locked by another instance")
+
+// lockStateDirectory takes an exclusive lock of the queue state directory, so that the daemon and the sync command do
+// not overwrite the persisted server group grants of each other. The lock is held until the returned function is
+// called or the process exits.
+func lockStateDirectory() (func(), error) {
+	stateDir, err := stateDirectory("queue.statedir")
+	if err != nil {
+		return nil, err
+	}
+	file, err := lockFile(filepath.Join(stateDir, stateLockFile))
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		_ = file.Close()
+	}, nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLockStateDirectory(t *testing.T) {
+	_, err := setupTestConfig(t, "queue:\n  statedir: '{dir}/state'\n", map[string]string{}, nil)
+	assert.NoError(t, err)
+	unlock, err := lockStateDirectory()
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = lockStateDirectory()
+	assert.Equal(t, errStateLocked, err, "expected the state directory to be locked only once")
+	unlock()
+	unlock, err = lockStateDirectory()
+	if assert.NoError(t, err, "expected the released lock to be taken again") {
+		unlock()
+	}
+}
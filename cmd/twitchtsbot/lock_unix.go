@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile opens the file and takes an exclusive lock of it, which is released once the file is closed.
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, errStateLocked
+		}
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,29 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// errorSharingViolation is returned by CreateFile if the file is opened by another process.
+const errorSharingViolation syscall.Errno = 32
+
+// lockFile opens the file without sharing it, so that it cannot be opened by another process until it is closed.
+func lockFile(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil, syscall.OPEN_ALWAYS,
+		syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		if err == errorSharingViolation {
+			return nil, errStateLocked
+		}
+		return nil, err
+	}
+	return os.NewFile(uintptr(handle), path), nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/admin"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/audit"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/history"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/logging"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/teamspeak"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/youtube"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"sync"
+)
+
+// packageLoggers contains the Log variables of the packages whose level can be configured separately.
+var packageLoggers = map[string]**logrus.Logger{
+	"admin":     &admin.Log,
+	"audit":     &audit.Log,
+	"history":   &history.Log,
+	"teamspeak": &teamspeak.Log,
+	"twitch":    &twitch.Log,
+	"youtube":   &youtube.Log,
+}
+
+// redactedSecrets contains the secret values which are removed from the log output. It is filled once the config has
+// been read and extended by rotated secrets, so that the hook does not access the config for every log entry.
+var redactedSecrets = &secretList{RWMutex: &sync.RWMutex{}}
+
+type secretList struct {
+	*sync.RWMutex
+	values []string
+}
+
+func (list *secretList) Get() []string {
+	list.RLock()
+	defer list.RUnlock()
+	return list.values
+}
+
+// Add adds the given values. Values which are already contained are skipped, as rotated secrets are added as well.
+func (list *secretList) Add(values ...string) {
+	list.Lock()
+	defer list.Unlock()
+	combined := make([]string, 0, len(list.values)+len(values))
+	combined = append(combined, list.values...)
+	for _, value := range values {
+		if !containsString(combined, value) {
+			combined = append(combined, value)
+		}
+	}
+	// the slice is replaced so that the hook can use the returned values without holding the lock
+	list.values = combined
+}
+
+func containsString(values []string, value string) bool {
+	for _, contained := range values {
+		if contained == value {
+			return true
+		}
+	}
+	return false
+}
+
+// setupRedaction removes all configured secrets from the log output. It has to be called before any other hook is
+// added so that the hooks do not receive the secrets either.
+func setupRedaction() {
+	logrus.AddHook(&logging.RedactHook{Secrets: redactedSecrets.Get})
+}
+
+// configSecrets returns the values of all sensitive config keys including the ones of the targets. It is only called
+// while the config is loaded, as viper must not be read concurrently with the secret watcher.
+func configSecrets() []string {
+	secretValues := make([]string, 0)
+	for _, key := range viper.AllKeys() {
+		if logging.IsSensitive(key) {
+			secretValues = append(secretValues, viper.GetString(key))
+		}
+	}
+	targets, _ := viper.Get("targets").([]interface{})
+	for _, target := range targets {
+		switch target := target.(type) {
+		case map[string]interface{}:
+			for key, value := range target {
+				if logging.IsSensitive(key) {
+					secretValues = append(secretValues, fmt.Sprint(value))
+				}
+			}
+		case map[interface{}]interface{}:
+			for key, value := range target {
+				if logging.IsSensitive(fmt.Sprint(key)) {
+					secretValues = append(secretValues, fmt.Sprint(value))
+				}
+			}
+		}
+	}
+	return secretValues
+}
+
+// configureLogging applies the log config. The level of the -level parameter takes precedence over the configured one.
+func configureLogging() error {
+	if !levelFlagSet() && viper.GetString("log.level") != "" {
+		level, err := logrus.ParseLevel(viper.GetString("log.level"))
+		if err != nil {
+			return err
+		}
+		logrus.SetLevel(level)
+	}
+	formatter, err := logging.NewFormatter(viper.GetString("log.format"))
+	if err != nil {
+		return err
+	}
+	logrus.SetFormatter(formatter)
+	if path := viper.GetString("log.file"); path != "" {
+		file, err := logging.OpenRotatingFile(path, viper.GetInt64("log.maxsize")*1024*1024, viper.GetInt("log.maxbackups"))
+		if err != nil {
+			return err
+		}
+		logrus.SetOutput(file)
+	}
+	levels := viper.GetStringMapString("log.levels")
+	for name, logger := range packageLoggers {
+		level := logrus.GetLevel()
+		if configuredLevel, ok := levels[name]; ok {
+			if level, err = logrus.ParseLevel(configuredLevel); err != nil {
+				return fmt.Errorf("invalid level of package %s: %w", name, err)
+			}
+		}
+		*logger = logging.NewPackageLogger(logrus.StandardLogger(), level)
+	}
+	for name := range levels {
+		if _, ok := packageLoggers[name]; !ok {
+			return fmt.Errorf("unknown package %q in log levels", name)
+		}
+	}
+	return nil
+}
+
+func levelFlagSet() bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == "level"
+	})
+	return set
+}
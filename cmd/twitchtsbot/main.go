@@ -1,149 +1,89 @@
 package main
 
 import (
-	"context"
-	"errors"
 	"flag"
-	ts3 "github.com/jkoenig134/go-ts3"
-	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/teamspeak"
-	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"fmt"
 	"github.com/nicklaw5/helix"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 	"os"
-	"os/signal"
-	"strconv"
-	"syscall"
 )
 
-const defaultLogLevel = logrus.InfoLevel
+const (
+	defaultLogLevel   = logrus.InfoLevel
+	subscriptionScope = "channel:read:subscriptions"
+	defaultCommand    = "run"
+)
 
 var (
 	// application parameters
 	logLevel = flag.String("level", "info",
 		"Set the logging level. See https://github.com/sirupsen/logrus#level-logging for more details.")
-	configPath      = flag.String("config", "./config.yml", "Set the config file path.")
-	teamspeakClient ts3.TeamspeakHttpClient
-	helixClient     *helix.Client
-	GitVersion      string
-	GitBranch       string
+	configPath  = flag.String("config", "./config.yml", "Set the config file path.")
+	dryRun      = flag.Bool("dry-run", false, "Only log the TeamSpeak changes instead of applying them.")
+	helixClient *helix.Client
+	GitVersion  string
+	GitBranch   string
 )
 
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(args []string) int
+}
+
+var commands = []*command{
+	{name: "run", usage: "run", description: "Run the bot daemon (default).", run: runDaemon},
+	{name: "check", usage: "check", description: "Validate the config and all configured identities.", run: runCheck},
+	{name: "init", usage: "init [-force]", description: "Create a new config file interactively.", run: runInit},
+	{name: "accounts", usage: "accounts list|add|remove|import|export", description: "Manage the account pairs.",
+		run: runAccounts},
+	{name: "sync", usage: "sync [-timeout duration]", description: "Reconcile all server groups once and exit.", run: runSync},
+	{name: "status", usage: "status [-address host:port] [-token token]", description: "Query the admin API of a running instance.",
+		run: runStatus},
+	{name: "stats", usage: "stats [-since duration]", description: "Print the stream statistics of all channels.",
+		run: runStats},
+	{name: "audit", usage: "audit [-since duration] [filters]",
+		description: "Print the audit log of the TeamSpeak changes.", run: runAudit},
+}
+
 func main() {
+	flag.Usage = printUsage
 	flag.Parse()
 	setLogLevel()
-	logrus.WithField("version", GitVersion).WithField("branch", GitBranch).Infoln("Starting up...")
+	setupRedaction()
 	setConfigDefaults()
-	loadConfigOrWriteDefault()
-	logrus.RegisterExitHandler(func() {
-		logrus.Infoln("Shut down twitchtsbot. Goodbye!")
-	})
-	viper.SetConfigFile(*configPath)
-	if err := viper.ReadInConfig(); err != nil {
-		logrus.WithError(err).Fatalln("Could not load config file.")
-	}
-	initializeTeamspeakQueryClient()
-	pairs := loadTwitchAccountPairs()
-	monitor, notifyChan := initializeTwitchHelixClient(pairs)
-	monitor.Start()
-	ctx, cancel := context.WithCancel(context.Background())
-	logrus.DeferExitHandler(func() {
-		logrus.Infoln("Stopping Teamspeak Hook...")
-		cancel()
-	})
-	hook := teamspeak.NewHook(&teamspeakClient, monitor, notifyChan, ctx, pairs, viper.GetInt("servergroupid"))
-	if err := hook.Start(); err != nil {
-		logrus.WithError(err).Fatalln("Could not start Teamspeak hook.")
+	setupEnvironment()
+	name := defaultCommand
+	var args []string
+	if flag.NArg() > 0 {
+		name = flag.Arg(0)
+		args = flag.Args()[1:]
 	}
-	var signalChannel chan os.Signal
-	signalChannel = make(chan os.Signal, 1)
-	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
-	<-signalChannel
-	logrus.Exit(0)
+	os.Exit(runCommand(name, args))
 }
 
-func loadConfigOrWriteDefault() {
-	if _, err := os.Stat(*configPath); errors.Is(err, os.ErrNotExist) {
-		if err = viper.SafeWriteConfigAs(*configPath); err != nil {
-			if _, ok := err.(viper.ConfigFileAlreadyExistsError); !ok {
-				logrus.WithField("configPath", *configPath).WithError(err).
-					Fatalln("Could not safe write default configuration.")
-			}
-		} else {
-			logrus.WithField("configPath", *configPath).Infoln("Written default config. Please update the values.")
-			logrus.Exit(0)
+// runCommand runs the named command with its arguments and returns the exit code. Unknown commands print the usage and
+// return the exit code 2.
+func runCommand(name string, args []string) int {
+	for _, command := range commands {
+		if command.name == name {
+			return command.run(args)
 		}
 	}
+	_, _ = fmt.Fprintf(flag.CommandLine.Output(), "Unknown command %q.\n\n", name)
+	printUsage()
+	return 2
 }
 
-func initializeTwitchHelixClient(pairs map[int]string) (*twitch.Monitor, chan *twitch.UserState) {
-	twitchLogins := make([]string, 0)
-	for _, twitchLogin := range pairs {
-		twitchLogins = append(twitchLogins, twitchLogin)
-	}
-	var err error
-	helixClient, err = helix.NewClient(&helix.Options{
-		ClientID: viper.GetString("twitch.clientid"),
-	})
-	if err != nil {
-		logrus.WithError(err).Fatalln("Could not authenticate with Twitch Helix API.")
-	}
-	appAccessToken := viper.GetString("twitch.appaccesstoken")
-	valid, _, err := helixClient.ValidateToken(appAccessToken)
-	if err != nil {
-		logrus.WithError(err).Fatalln("Could not validate Twitch App Access Token.")
-	}
-	helixClient.SetAppAccessToken(appAccessToken)
-	if !valid {
-		logrus.WithError(err).WithField("appAccessToken", appAccessToken).Fatalln("Twitch App Access Token is invalid.")
-	}
-	notifyChan := make(chan *twitch.UserState)
-	ctx, cancel := context.WithCancel(context.Background())
-	logrus.DeferExitHandler(func() {
-		logrus.Infoln("Stopping Helix Twitch Monitor...")
-		cancel()
-	})
-	monitor := twitch.NewMonitor(helixClient, twitchLogins, viper.GetDuration("interval"), ctx, notifyChan)
-	return monitor, notifyChan
-}
-
-func initializeTeamspeakQueryClient() {
-	config := ts3.NewConfig(viper.GetString("teamspeak.url"), viper.GetString("teamspeak.apikey"))
-	teamspeakClient = ts3.NewClient(config)
-	teamspeakClient.SetServerID(viper.GetInt("teamspeak.serverid"))
-	version, err := teamspeakClient.Version()
-	if err != nil {
-		logrus.WithError(err).Fatalln("Could not retrieve Teamspeak Server version.")
-	}
-	logrus.WithField("teamspeakVersion", version).Infoln("Retrieved Teamspeak Server version.")
-}
-
-func loadTwitchAccountPairs() map[int]string {
-	var accounts []*accountEntry
-	if err := viper.UnmarshalKey("accounts", &accounts); err != nil {
-		logrus.WithError(err).Fatalln("Could not load account pairs.")
-	}
-	fetchedPairs := make(map[int]string)
-	for _, account := range accounts {
-		fetchPair(fetchedPairs, account.TsIdentifier, account.TwitchUsername)
-	}
-	logrus.WithField("pairAmount", len(fetchedPairs)).Infoln("Fetched account pairs.")
-	return fetchedPairs
-}
-
-func fetchPair(fetchedPairs map[int]string, identifier string, twitchLoginName string) {
-	var teamspeakDatabaseId int
-	var err error
-	if teamspeakDatabaseId, err = strconv.Atoi(identifier); err != nil {
-		logrus.WithField("identifier", identifier).Traceln("Could not parse int from identifier. Falling back to Teamspeak fetch.")
-		fetchedTeamspeakDatabaseId, err := teamspeakClient.ClientGetDbIdFromUid(identifier)
-		if err != nil {
-			logrus.WithError(err).WithField("identifier", identifier).Warnln("Could not retrieve Teamspeak database id.")
-			return
-		}
-		teamspeakDatabaseId = *fetchedTeamspeakDatabaseId
+func printUsage() {
+	output := flag.CommandLine.Output()
+	_, _ = fmt.Fprintf(output, "Usage: %s [parameters] <command> [arguments]\n\nCommands:\n", os.Args[0])
+	for _, command := range commands {
+		_, _ = fmt.Fprintf(output, "  %-40s %s\n", command.usage, command.description)
 	}
-	fetchedPairs[teamspeakDatabaseId] = twitchLoginName
+	_, _ = fmt.Fprintln(output, "\nParameters:")
+	flag.PrintDefaults()
 }
 
 func setLogLevel() {
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestRunCommand_Usage(t *testing.T) {
+	flag.CommandLine.SetOutput(ioutil.Discard)
+	t.Cleanup(func() {
+		flag.CommandLine.SetOutput(nil)
+	})
+	for _, test := range []struct {
+		name string
+		args []string
+	}{
+		{name: "unknown"},
+		{name: "accounts"},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, 2, runCommand(test.name, test.args), "unexpected exit code")
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/admin"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/audit"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/history"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/teamspeak"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// runDaemon runs the bot until it receives an interrupt or termination signal and shuts it down gracefully afterwards.
+// A second signal aborts the shutdown.
+func runDaemon(_ []string) int {
+	logrus.WithField("version", GitVersion).WithField("branch", GitBranch).Infoln("Starting up...")
+	loadConfig()
+	unlock, err := lockStateDirectory()
+	if err != nil {
+		logrus.WithError(err).Fatalln("Could not lock the state directory.")
+	}
+	defer unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	running := startDaemon(ctx)
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
+	<-signalChannel
+	exitCodeChan := make(chan int, 1)
+	go func() {
+		exitCodeChan <- running.shutdown(viper.GetDuration("shutdown.timeout"))
+	}()
+	select {
+	case exitCode := <-exitCodeChan:
+		return exitCode
+	case <-signalChannel:
+		logrus.Errorln("Received second signal, aborting shutdown.")
+		return 1
+	}
+}
+
+// startDaemon connects to all TeamSpeak targets and starts the stream monitors, hooks and optional services of the bot
+// until the context is done or the returned daemon is shut down. Targets which are not reachable are connected in the
+// background. The bot exits if none of them is reachable. The config has to be loaded before.
+func startDaemon(ctx context.Context) *daemon {
+	stopTracing, err := setupTracing()
+	if err != nil {
+		logrus.WithError(err).Fatalln("Could not set up tracing.")
+	}
+	targets := mustLoadTargets()
+	auditStore := openAuditStore()
+	connected := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		target.setAudit(auditStore)
+		if err := target.connect(); err != nil {
+			target.log().WithError(err).Errorln("Could not connect to Teamspeak target. Retrying in the background.")
+			continue
+		}
+		connected[target.Name] = true
+	}
+	if len(connected) == 0 {
+		logrus.Fatalln("Could not connect to any Teamspeak target.")
+	}
+	// the monitors are stopped before the rest of the daemon so that the remaining stream changes can be drained
+	pollCtx, stopPolling := context.WithCancel(ctx)
+	ctx, cancel := context.WithCancel(ctx)
+	running := &daemon{Mutex: &sync.Mutex{}, cancel: cancel, stopPolling: stopPolling, targets: targets,
+		stopTracing: stopTracing}
+	monitors, notifyChan, metadataChan := initializeMonitors(targets, pollCtx)
+	running.monitors, running.notifyChan, running.metadataChan = monitors, notifyChan, metadataChan
+	secrets.Start(ctx, viper.GetDuration("secretrefreshinterval"))
+	hookChans := make([]chan *twitch.UserState, 0, len(targets))
+	metadataChans := make([]chan *twitch.UserState, 0, len(targets))
+	// target name: server group change queue used by the hooks of the target
+	queues := make(map[string]*teamspeak.Queue, len(targets))
+	dryRunClients := make(map[string]*teamspeak.DryRunClient)
+	starters := make([]*targetStarter, 0, len(targets))
+	for _, target := range targets {
+		tsClient, dryRunClient := target.hookClient()
+		queue := target.newQueue(tsClient)
+		queue.Start(ctx)
+		queues[target.Name] = queue
+		if dryRunClient != nil {
+			dryRunClients[target.Name] = dryRunClient
+		}
+		starter := &targetStarter{target: target, client: tsClient, queue: queue, monitors: monitors,
+			notifyChan: make(chan *twitch.UserState), metadataChan: make(chan *twitch.UserState)}
+		hookChans = append(hookChans, starter.notifyChan)
+		metadataChans = append(metadataChans, starter.metadataChan)
+		starters = append(starters, starter)
+	}
+	running.queues = queues
+	historyStore, recorderChan := initializeHistory(monitors, ctx)
+	if recorderChan != nil {
+		hookChans = append(hookChans, recorderChan)
+	}
+	eventLog, eventChan := initializeEventLog(ctx)
+	if eventChan != nil {
+		hookChans = append(hookChans, eventChan)
+	}
+	twitch.Broadcast(ctx, notifyChan, hookChans)
+	twitch.Broadcast(ctx, metadataChan, metadataChans)
+	if viper.GetString("subscriptions.accesstoken") != "" {
+		subscriptionNotifyChan := initializeSubscriptionMonitor(targets, pollCtx)
+		subscriptionHookChans := make([]chan *twitch.SubscriptionState, 0, len(targets))
+		for _, starter := range starters {
+			if len(starter.target.SubscriptionServerGroups) == 0 {
+				continue
+			}
+			starter.subscriptionChan = make(chan *twitch.SubscriptionState)
+			subscriptionHookChans = append(subscriptionHookChans, starter.subscriptionChan)
+		}
+		twitch.BroadcastSubscriptions(ctx, subscriptionNotifyChan, subscriptionHookChans)
+	}
+	running.Lock()
+	for _, starter := range starters {
+		if connected[starter.target.Name] {
+			running.startTarget(ctx, starter)
+		} else {
+			go running.reconnect(ctx, starter)
+		}
+	}
+	running.Unlock()
+	for _, monitor := range monitors {
+		monitor.Start()
+	}
+	running.adminServer = startAdminServer(monitors, dryRunClients, queues, historyStore, running.startedHooks,
+		eventLog, auditStore)
+	return running
+}
+
+// targetStarter contains the components of a target which are created on startup, even if the target is not
+// reachable yet, so that they can be shared with the stream monitors and the admin API.
+type targetStarter struct {
+	target       *teamspeakTarget
+	client       teamspeak.Client
+	queue        *teamspeak.Queue
+	monitors     map[string]*twitch.Monitor
+	notifyChan   chan *twitch.UserState
+	metadataChan chan *twitch.UserState
+	// subscriptionChan is nil if the target does not grant subscription server groups
+	subscriptionChan chan *twitch.SubscriptionState
+}
+
+// startTarget listens for the client events of the connected target, resolves its account pairs and starts its hooks.
+// The daemon has to be locked.
+func (daemon *daemon) startTarget(ctx context.Context, starter *targetStarter) {
+	target := starter.target
+	target.listenForEvents(ctx)
+	target.loadPairs()
+	target.adoptMemberships(starter.queue.Members.Ownership)
+	hook := teamspeak.NewHook(starter.queue, starter.monitors, starter.notifyChan, ctx, target.pairs,
+		target.ServerGroupID)
+	hook.MetadataChan = starter.metadataChan
+	hook.Actions = target.newActions(starter.queue, starter.client)
+	for _, action := range hook.Actions {
+		if tagger, ok := action.(*teamspeak.DescriptionTagger); ok {
+			tagger.Start(ctx)
+			daemon.taggers = append(daemon.taggers, tagger)
+		}
+	}
+	daemon.hooks = append(daemon.hooks, hook)
+	if err := hook.Start(); err != nil {
+		target.log().WithError(err).Fatalln("Could not start Teamspeak hook.")
+	}
+	if starter.subscriptionChan != nil {
+		teamspeak.NewSubscriptionHook(starter.queue, starter.subscriptionChan, ctx, target.pairs,
+			target.SubscriptionServerGroups).Start()
+	}
+}
+
+// reconnect retries to connect to the target in the background and starts it once it is reachable. The stream changes
+// which are received until then are discarded, as the hook reconciles the current stream states when it is started.
+// Subscription changes which are discarded are applied once the subscription changes again.
+func (daemon *daemon) reconnect(ctx context.Context, starter *targetStarter) {
+	delay, maxDelay := retryDelays()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	// the channels are closed on shutdown, which stops the reconnection
+	notifyChan, metadataChan := starter.notifyChan, starter.metadataChan
+	for notifyChan != nil || metadataChan != nil {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-notifyChan:
+			if !ok {
+				notifyChan = nil
+			}
+		case _, ok := <-metadataChan:
+			if !ok {
+				metadataChan = nil
+			}
+		case <-starter.subscriptionChan:
+		case <-timer.C:
+			if err := starter.target.connect(); err != nil {
+				if delay *= 2; delay > maxDelay {
+					delay = maxDelay
+				}
+				starter.target.log().WithError(err).WithField("retryIn", delay.String()).
+					Warnln("Could not connect to Teamspeak target.")
+				timer.Reset(delay)
+				continue
+			}
+			daemon.Lock()
+			defer daemon.Unlock()
+			if !daemon.stopped {
+				starter.target.log().Infoln("Connected to Teamspeak target.")
+				daemon.startTarget(ctx, starter)
+			}
+			return
+		}
+	}
+}
+
+// initializeHistory starts recording the stream history if it is enabled. It returns the channel the stream states
+// have to be sent to, which is nil if the history is disabled.
+func initializeHistory(monitors map[string]*twitch.Monitor, ctx context.Context) (*history.Store,
+	chan *twitch.UserState) {
+	file := viper.GetString("history.file")
+	if file == "" {
+		return nil, nil
+	}
+	store, err := history.NewStore(file, viper.GetDuration("history.retention"))
+	if err != nil {
+		logrus.WithError(err).WithField("file", file).Fatalln("Could not load stream history.")
+	}
+	recorderChan := make(chan *twitch.UserState)
+	history.NewRecorder(store, monitors, recorderChan, viper.GetDuration("history.sampleinterval"), ctx).Start()
+	return store, recorderChan
+}
+
+// initializeEventLog records the recent stream transitions and logged problems for the dashboard if the admin API is
+// enabled. It returns the channel the stream states have to be sent to, which is nil if the admin API is disabled.
+func initializeEventLog(ctx context.Context) (*admin.EventLog, chan *twitch.UserState) {
+	if viper.GetString("admin.listen") == "" {
+		return nil, nil
+	}
+	eventLog := admin.NewEventLog()
+	logrus.AddHook(eventLog)
+	eventChan := make(chan *twitch.UserState)
+	eventLog.RecordTransitions(ctx, eventChan)
+	return eventLog, eventChan
+}
+
+// startAdminServer starts the admin API if it is enabled and returns it, which is nil otherwise.
+func startAdminServer(monitors map[string]*twitch.Monitor, dryRunClients map[string]*teamspeak.DryRunClient,
+	queues map[string]*teamspeak.Queue, historyStore *history.Store, hooks func() []*teamspeak.TwitchUpdateHook,
+	eventLog *admin.EventLog, auditStore *audit.Store) *admin.Server {
+	address := viper.GetString("admin.listen")
+	if address == "" {
+		return nil
+	}
+	server := admin.NewServer(GitVersion, GitBranch, monitors, dryRunClients, queues, historyStore)
+	server.Accounts = &configAccountManager{Mutex: &sync.Mutex{}}
+	server.Events = eventLog
+	server.Audit = auditStore
+	server.Password = viper.GetString("admin.password")
+	server.Token = viper.GetString("admin.token")
+	server.Sync = func() error {
+		states := make([]*twitch.UserState, 0)
+		for _, monitor := range monitors {
+			states = append(states, monitor.GetStates()...)
+		}
+		ctx := audit.WithCause(context.Background(), audit.Cause{Trigger: audit.TriggerAdmin})
+		for _, hook := range hooks() {
+			hook.ReconcileContext(ctx, states)
+		}
+		logrus.WithField("stateAmount", len(states)).Infoln("Triggered sync of all server groups.")
+		return nil
+	}
+	if err := server.Start(address); err != nil {
+		logrus.WithError(err).WithField("address", address).Fatalln("Could not start admin API.")
+	}
+	return server
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/youtube"
+	"github.com/nicklaw5/helix"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// twitchHTTPClient is used by all Twitch API clients. The end-to-end tests replace it to route the requests to a fake
+// Twitch API.
+var twitchHTTPClient helix.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// loadConfig reads the config file and exits if it does not exist or cannot be parsed.
+func loadConfig() {
+	if _, err := os.Stat(*configPath); errors.Is(err, os.ErrNotExist) {
+		logrus.WithField("configPath", *configPath).
+			Fatalln("Config file does not exist. Please create one by running the init command.")
+	}
+	if err := readConfig(); err != nil {
+		logrus.WithError(err).Fatalln("Could not load config file.")
+	}
+	redactedSecrets.Add(configSecrets()...)
+	if err := configureLogging(); err != nil {
+		logrus.WithError(err).Fatalln("Could not configure logging.")
+	}
+}
+
+func newProvider(platform string) twitch.Provider {
+	switch platform {
+	case twitch.PlatformTwitch:
+		mature := twitch.NewMatureRecorder(twitchHTTPClient)
+		provider := twitch.NewHelixProvider(initializeTwitchHelixClient(mature))
+		provider.Mature = mature
+		return provider
+	case youtube.Platform:
+		provider := youtube.NewProvider(viper.GetString("youtube.apikey"))
+		secrets.OnChange("youtube.apikey", provider.SetAPIKey)
+		return provider
+	default:
+		logrus.WithField("platform", platform).Fatalln("Unknown streaming platform.")
+		return nil
+	}
+}
+
+// monitorInterval returns the poll interval of the platform. YouTube has its own interval due to the daily quota of its
+// API.
+func monitorInterval(platform string) time.Duration {
+	if platform == youtube.Platform {
+		return viper.GetDuration("youtube.interval")
+	}
+	return viper.GetDuration("interval")
+}
+
+// initializeMonitors creates a single monitor per platform for the channels of all targets. It returns the monitors,
+// the channel of the stream transitions and the one of the metadata changes of running streams. The monitors poll until
+// the context is done.
+func initializeMonitors(targets []*teamspeakTarget, ctx context.Context) (map[string]*twitch.Monitor,
+	chan *twitch.UserState, chan *twitch.UserState) {
+	platformLogins := groupChannelsByPlatform(targets)
+	notifyChan := make(chan *twitch.UserState)
+	metadataChan := make(chan *twitch.UserState)
+	monitors := make(map[string]*twitch.Monitor, len(platformLogins))
+	for platform, logins := range platformLogins {
+		monitor := twitch.NewProviderMonitor(newProvider(platform), logins, monitorInterval(platform), ctx, notifyChan)
+		monitor.MaxBackoff = viper.GetDuration("maxbackoff")
+		monitor.MetadataChan = metadataChan
+		if stateDir := viper.GetString("monitor.statedir"); stateDir != "" {
+			monitor.StateFile = filepath.Join(stateDir, "monitor-"+platform+".json")
+			if _, err := monitor.LoadState(monitor.StateFile, viper.GetDuration("monitor.maxstateage")); err != nil {
+				logrus.WithError(err).WithField("stateFile", monitor.StateFile).Warnln("Could not restore monitor state.")
+			}
+		}
+		monitors[platform] = monitor
+	}
+	if err := applyStreamFilters(monitors, targets); err != nil {
+		logrus.WithError(err).Fatalln("Could not configure stream filters.")
+	}
+	return monitors, notifyChan, metadataChan
+}
+
+func initializeTwitchHelixClient(httpClient helix.HTTPClient) *helix.Client {
+	var err error
+	helixClient, err = newHelixClient(httpClient)
+	if err != nil {
+		logrus.WithError(err).Fatalln("Could not authenticate with Twitch Helix API.")
+	}
+	appAccessToken := viper.GetString("twitch.appaccesstoken")
+	valid, _, err := helixClient.ValidateToken(appAccessToken)
+	if err != nil {
+		logrus.WithError(err).Fatalln("Could not validate Twitch App Access Token.")
+	}
+	helixClient.SetAppAccessToken(appAccessToken)
+	if !valid {
+		logrus.WithError(err).Fatalln("Twitch App Access Token is invalid.")
+	}
+	secrets.OnChange("twitch.appaccesstoken", helixClient.SetAppAccessToken)
+	return helixClient
+}
+
+func initializeSubscriptionMonitor(targets []*teamspeakTarget, ctx context.Context) chan *twitch.SubscriptionState {
+	twitchLogins := groupChannelsByPlatform(targets)[twitch.PlatformTwitch]
+	accessToken := viper.GetString("subscriptions.accesstoken")
+	client, err := helix.NewClient(&helix.Options{
+		ClientID:        viper.GetString("twitch.clientid"),
+		UserAccessToken: accessToken,
+		HTTPClient:      twitchHTTPClient,
+	})
+	if err != nil {
+		logrus.WithError(err).Fatalln("Could not create Twitch Helix API client for subscriptions.")
+	}
+	valid, resp, err := client.ValidateToken(accessToken)
+	if err != nil {
+		logrus.WithError(err).Fatalln("Could not validate Twitch broadcaster access token.")
+	}
+	if !valid {
+		logrus.Fatalln("Twitch broadcaster access token is invalid.")
+	}
+	var hasScope bool
+	for _, scope := range resp.Data.Scopes {
+		hasScope = hasScope || scope == subscriptionScope
+	}
+	if !hasScope {
+		logrus.WithField("scope", subscriptionScope).Fatalln("Twitch broadcaster access token is missing required scope.")
+	}
+	secrets.OnChange("subscriptions.accesstoken", client.SetUserAccessToken)
+	notifyChan := make(chan *twitch.SubscriptionState)
+	monitor := twitch.NewSubscriptionMonitor(client, resp.Data.UserID, twitchLogins,
+		viper.GetDuration("subscriptions.interval"), ctx, notifyChan)
+	monitor.Start()
+	return notifyChan
+}
+
+func newHelixClient(httpClient helix.HTTPClient) (*helix.Client, error) {
+	return helix.NewClient(&helix.Options{
+		ClientID:   viper.GetString("twitch.clientid"),
+		HTTPClient: httpClient,
+	})
+}
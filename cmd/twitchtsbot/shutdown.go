@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/admin"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/audit"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/teamspeak"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"sync"
+	"time"
+)
+
+// daemon contains the running components of the bot which have to be stopped in order on shutdown.
+type daemon struct {
+	// the mutex guards the hooks and taggers, which are added once a target which is connected in the background is
+	// started
+	*sync.Mutex
+	// stopped is set once the hooks are drained, so that no target is started afterwards
+	stopped      bool
+	cancel       context.CancelFunc
+	stopPolling  context.CancelFunc
+	targets      []*teamspeakTarget
+	monitors     map[string]*twitch.Monitor
+	notifyChan   chan *twitch.UserState
+	metadataChan chan *twitch.UserState
+	hooks        []*teamspeak.TwitchUpdateHook
+	// target name: server group change queue used by the hooks of the target
+	queues      map[string]*teamspeak.Queue
+	taggers     []*teamspeak.DescriptionTagger
+	adminServer *admin.Server
+	// stopTracing exports the remaining spans
+	stopTracing func(ctx context.Context) error
+}
+
+// shutdown stops the daemon and returns the exit code of the bot. The stream monitors are stopped first, then the
+// remaining stream changes are performed and the pending TeamSpeak changes are applied. If enabled, the live server
+// groups granted by the bot are removed before. The exit code is 1 if the shutdown has not been completed within the
+// timeout or if changes could not be applied.
+func (daemon *daemon) shutdown(timeout time.Duration) int {
+	defer daemon.cancel()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	logrus.WithField("timeout", timeout.String()).Infoln("Shutting down...")
+	exitCode := 0
+	steps := []struct {
+		message string
+		run     func() bool
+	}{
+		{"Stopping stream monitors...", daemon.stopMonitors},
+		{"Draining stream changes...", daemon.drainHooks},
+		{"Removing granted live server groups...", daemon.removeServerGroups},
+		{"Restoring client descriptions...", daemon.restoreDescriptions},
+		{"Applying pending server group changes...", func() bool {
+			return daemon.drainQueues(ctx)
+		}},
+		{"Stopping admin API...", func() bool {
+			return daemon.adminServer == nil || daemon.adminServer.Shutdown(ctx) == nil
+		}},
+		{"Exporting remaining traces...", func() bool {
+			return daemon.stopTracing(ctx) == nil
+		}},
+	}
+	for _, step := range steps {
+		logrus.Infoln(step.message)
+		done := make(chan bool, 1)
+		go func(run func() bool) {
+			done <- run()
+		}(step.run)
+		select {
+		case ok := <-done:
+			if !ok {
+				exitCode = 1
+			}
+		case <-ctx.Done():
+			logrus.WithField("timeout", timeout.String()).Errorln("Could not shut down within the timeout.")
+			return 1
+		}
+	}
+	logrus.WithField("exitCode", exitCode).Infoln("Shut down twitchtsbot. Goodbye!")
+	return exitCode
+}
+
+// stopMonitors stops polling and persists the final state of the monitors.
+func (daemon *daemon) stopMonitors() bool {
+	daemon.stopPolling()
+	ok := true
+	for platform, monitor := range daemon.monitors {
+		monitor.Wait()
+		if monitor.StateFile == "" {
+			continue
+		}
+		if err := monitor.SaveState(monitor.StateFile); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{"platform": platform, "stateFile": monitor.StateFile}).
+				Errorln("Could not persist monitor state.")
+			ok = false
+		}
+	}
+	return ok
+}
+
+// drainHooks closes the channels of the stopped monitors and waits until the hooks have performed all stream changes
+// which have been detected before.
+func (daemon *daemon) drainHooks() bool {
+	close(daemon.notifyChan)
+	close(daemon.metadataChan)
+	daemon.Lock()
+	daemon.stopped = true
+	daemon.Unlock()
+	for _, hook := range daemon.startedHooks() {
+		hook.Wait()
+	}
+	return true
+}
+
+// startedHooks returns the hooks of the targets which have been started.
+func (daemon *daemon) startedHooks() []*teamspeak.TwitchUpdateHook {
+	daemon.Lock()
+	defer daemon.Unlock()
+	return append([]*teamspeak.TwitchUpdateHook(nil), daemon.hooks...)
+}
+
+// removeServerGroups enqueues the removal of all live server groups granted by the bot if it is enabled. They are
+// granted again after a restart as the restored stream states are reconciled.
+func (daemon *daemon) removeServerGroups() bool {
+	if !viper.GetBool("shutdown.removegroups") {
+		return true
+	}
+	for _, target := range daemon.targets {
+		queue := daemon.queues[target.Name]
+		liveServerGroups := make(map[int]bool)
+		for _, serverGroupId := range target.serverGroupIds() {
+			liveServerGroups[serverGroupId] = true
+		}
+		removed := 0
+		for _, grant := range queue.Members.Ownership.Grants() {
+			if liveServerGroups[grant.ServerGroupId] {
+				channel := target.pairs[grant.ClientDbId]
+				ctx := audit.WithCause(context.Background(), audit.Cause{Trigger: audit.TriggerShutdown,
+					Platform: channel.Platform, Login: channel.Login})
+				queue.SetServerGroupContext(ctx, grant.ServerGroupId, grant.ClientDbId, false)
+				removed++
+			}
+		}
+		target.log().WithField("removedAmount", removed).Infoln("Enqueued removal of granted live server groups.")
+	}
+	return true
+}
+
+func (daemon *daemon) restoreDescriptions() bool {
+	daemon.Lock()
+	defer daemon.Unlock()
+	for _, tagger := range daemon.taggers {
+		tagger.RestoreAll()
+	}
+	return true
+}
+
+// drainQueues applies the pending server group changes of all targets. Changes which could not be applied remain in
+// the state files of the queues.
+func (daemon *daemon) drainQueues(ctx context.Context) bool {
+	ok := true
+	for name, queue := range daemon.queues {
+		if err := queue.Drain(ctx); err != nil {
+			logrus.WithFields(logrus.Fields{"target": name, "pendingAmount": len(queue.Pending())}).
+				Errorln("Could not apply all server group changes.")
+			ok = false
+		}
+	}
+	return ok
+}
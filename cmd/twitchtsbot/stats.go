@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/history"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// runStats prints the aggregated stream statistics which have been recorded in the stream history file.
+func runStats(args []string) int {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	since := flags.Duration("since", 0, "Only take the streams of the given duration such as 168h into account.")
+	_ = flags.Parse(args)
+	loadConfig()
+	file := viper.GetString("history.file")
+	if file == "" {
+		logrus.Errorln("The stream history is not enabled. Set history.file to record it.")
+		return 1
+	}
+	store, err := history.NewStore(file, 0)
+	if err != nil {
+		logrus.WithError(err).WithField("file", file).Errorln("Could not load stream history.")
+		return 1
+	}
+	now := time.Now()
+	var sinceTime time.Time
+	if *since > 0 {
+		sinceTime = now.Add(-*since)
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "PLATFORM\tCHANNEL\tSTREAMS\tHOURS\tPER WEEK\tPEAK VIEWERS\tTOP GAMES")
+	for _, stats := range store.Stats(sinceTime, now) {
+		channel := stats.UserLogin
+		if stats.Live {
+			channel += " (live)"
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%d\t%.1f\t%.1f\t%d\t%s\n", stats.Platform, channel, stats.Streams,
+			stats.TotalHours, stats.StreamsPerWeek, stats.PeakViewers, strings.Join(stats.TopGames, ", "))
+	}
+	_ = writer.Flush()
+	return 0
+}
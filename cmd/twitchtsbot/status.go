@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/admin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"net/http"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// runStatus queries the admin API of a running instance and prints its status.
+func runStatus(args []string) int {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	address := flags.String("address", "", "Set the admin API address. Defaults to admin.listen of the config.")
+	token := flags.String("token", "", "Set the admin API token. Defaults to admin.token of the config.")
+	_ = flags.Parse(args)
+	var password string
+	if *address == "" || *token == "" {
+		if err := readConfig(); err != nil && *address == "" {
+			logrus.WithError(err).Errorln("Could not load config file. Use -address to set the admin API address.")
+			return 1
+		}
+		if *address == "" {
+			*address = viper.GetString("admin.listen")
+		}
+		if *token == "" {
+			*token = viper.GetString("admin.token")
+			password = viper.GetString("admin.password")
+		}
+	}
+	if *address == "" {
+		logrus.Errorln("The admin API is not enabled.")
+		return 1
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	request, err := http.NewRequest(http.MethodGet, "http://"+*address+"/api/status", nil)
+	if err != nil {
+		logrus.WithError(err).Errorln("Could not create admin API request.")
+		return 1
+	}
+	setAdminAuthorization(request, *token, password)
+	resp, err := client.Do(request)
+	if err != nil {
+		logrus.WithError(err).Errorln("Could not query admin API.")
+		return 1
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		logrus.WithField("statusCode", resp.StatusCode).Errorln("Admin API returned unexpected status code.")
+		return 1
+	}
+	var status admin.Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		logrus.WithError(err).Errorln("Could not decode admin API response.")
+		return 1
+	}
+	fmt.Printf("Version: %s (%s)\nDry run: %t\n\n", status.Version, status.Branch, status.DryRun)
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "PLATFORM\tINTERVAL\tEFFECTIVE INTERVAL\tFAILURES\tRATE LIMIT")
+	platforms := make([]string, 0, len(status.Monitors))
+	for platform := range status.Monitors {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+	for _, platform := range platforms {
+		monitor := status.Monitors[platform]
+		rateLimit := "-"
+		if monitor.RateLimit != nil {
+			rateLimit = fmt.Sprintf("%d/%d", monitor.RateLimit.Remaining, monitor.RateLimit.Limit)
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%s\n", platform, monitor.Interval, monitor.EffectiveInterval,
+			monitor.Failures, rateLimit)
+	}
+	_ = writer.Flush()
+	fmt.Println()
+	writer = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "PLATFORM\tCHANNEL\tSTATUS\tTITLE")
+	for _, state := range status.Streams {
+		var title string
+		if state.Stream != nil {
+			title = state.Stream.Title
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", state.Platform, state.UserLogin, state.StreamerStatus, title)
+	}
+	_ = writer.Flush()
+	if status.DryRun {
+		fmt.Println()
+		for target, mutations := range status.DryRunMutations {
+			fmt.Printf("%s: %d TeamSpeak change(s) skipped because of the dry run mode.\n", target, len(mutations))
+		}
+	}
+	return 0
+}
+
+// setAdminAuthorization authenticates the admin API request with the token or, if no token is set, the password.
+func setAdminAuthorization(request *http.Request, token, password string) {
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	} else if password != "" {
+		request.SetBasicAuth("admin", password)
+	}
+}
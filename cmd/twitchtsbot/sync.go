@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/audit"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/teamspeak"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// runSync fetches the current stream states once, reconciles the server groups of all account pairs and exits. If an
+// instance is running, the sync is triggered through its admin API instead, as both would overwrite the persisted
+// server group grants of each other. Without the admin API, the sync is refused while the instance is running.
+func runSync(args []string) int {
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	timeout := flags.Duration("timeout", time.Minute, "Set how long failed TeamSpeak changes are retried.")
+	_ = flags.Parse(args)
+	loadConfig()
+	if address := viper.GetString("admin.listen"); address != "" {
+		triggered, err := triggerDaemonSync(address, viper.GetString("admin.token"), viper.GetString("admin.password"))
+		if err != nil {
+			logrus.WithError(err).WithField("address", address).Errorln("Could not trigger sync of running instance.")
+			return 1
+		}
+		if triggered {
+			logrus.WithField("address", address).Infoln("Triggered sync of running instance.")
+			return 0
+		}
+	}
+	unlock, err := lockStateDirectory()
+	if err != nil {
+		logrus.WithError(err).
+			Errorln("Could not lock the state directory. Enable the admin API to sync a running instance.")
+		return 1
+	}
+	defer unlock()
+	stopTracing, err := setupTracing()
+	if err != nil {
+		logrus.WithError(err).Fatalln("Could not set up tracing.")
+	}
+	targets := mustLoadTargets()
+	auditStore := openAuditStore()
+	hooks := make([]*teamspeak.TwitchUpdateHook, 0, len(targets))
+	// target name: server group change queue
+	queues := make(map[string]*teamspeak.Queue, len(targets))
+	dryRunClients := make(map[string]*teamspeak.DryRunClient)
+	// target name: description taggers whose failed changes are retried before the sync exits
+	taggers := make(map[string][]*teamspeak.DescriptionTagger)
+	exitCode := 0
+	for _, target := range targets {
+		if err := target.connect(); err != nil {
+			target.log().WithError(err).Errorln("Could not connect to Teamspeak target.")
+			exitCode = 1
+			continue
+		}
+		target.loadPairs()
+		target.setAudit(auditStore)
+		tsClient, dryRunClient := target.hookClient()
+		if dryRunClient != nil {
+			dryRunClients[target.Name] = dryRunClient
+		}
+		// the queue is not persisted as it could otherwise interfere with the one of a running daemon
+		queue, _ := teamspeak.NewQueue(tsClient, "")
+		queue.BaseDelay = viper.GetDuration("queue.basedelay")
+		queue.MaxDelay = viper.GetDuration("queue.maxdelay")
+		// the granted server groups are shared with the daemon, as they would not be removed otherwise. The daemon is
+		// not running, as the state directory is locked.
+		target.setOwnership(queue)
+		target.adoptMemberships(queue.Members.Ownership)
+		queue.Members.Audit = target.audit
+		queues[target.Name] = queue
+		hook := teamspeak.NewHook(queue, nil, nil, context.Background(), target.pairs, target.ServerGroupID)
+		// the original descriptions are shared with the daemon as well, so that it restores them once the streams end
+		hook.Actions = target.newActions(queue, tsClient)
+		for _, action := range hook.Actions {
+			if tagger, ok := action.(*teamspeak.DescriptionTagger); ok {
+				taggers[target.Name] = append(taggers[target.Name], tagger)
+			}
+		}
+		hooks = append(hooks, hook)
+	}
+	for platform, logins := range groupChannelsByPlatform(targets) {
+		monitor := twitch.NewProviderMonitor(newProvider(platform), logins, 0, context.Background(), nil)
+		if err := applyStreamFilters(map[string]*twitch.Monitor{platform: monitor}, targets); err != nil {
+			logrus.WithError(err).Errorln("Could not configure stream filters.")
+			exitCode = 1
+			continue
+		}
+		states, err := monitor.FetchStates()
+		if err != nil {
+			logrus.WithError(err).WithField("platform", platform).Errorln("Could not fetch stream states.")
+			exitCode = 1
+			continue
+		}
+		for _, hook := range hooks {
+			hook.ReconcileContext(audit.WithCause(context.Background(), audit.Cause{Trigger: audit.TriggerAdmin}), states)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	for target, queue := range queues {
+		if err := queue.Drain(ctx); err != nil {
+			logrus.WithFields(logrus.Fields{"target": target, "pendingAmount": len(queue.Pending())}).
+				Errorln("Could not apply all server group changes.")
+			exitCode = 1
+		}
+	}
+	for target, targetTaggers := range taggers {
+		for _, tagger := range targetTaggers {
+			if err := tagger.Drain(ctx); err != nil {
+				logrus.WithField("target", target).Errorln("Could not apply all client description changes.")
+				exitCode = 1
+			}
+		}
+	}
+	if err := stopTracing(ctx); err != nil {
+		logrus.WithError(err).Warnln("Could not export remaining traces.")
+	}
+	for target, dryRunClient := range dryRunClients {
+		logrus.WithFields(logrus.Fields{"target": target, "mutationAmount": len(dryRunClient.Mutations())}).
+			Infoln("Finished dry run sync.")
+	}
+	if len(dryRunClients) == 0 {
+		logrus.Infoln("Finished sync.")
+	}
+	return exitCode
+}
+
+// triggerDaemonSync triggers the sync of the instance whose admin API listens on the given address. It returns false
+// without an error if the admin API is not reachable, e.g. because no instance is running.
+func triggerDaemonSync(address, token, password string) (bool, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	request, err := http.NewRequest(http.MethodPost, "http://"+address+"/api/sync", strings.NewReader("{}"))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	setAdminAuthorization(request, token, password)
+	resp, err := client.Do(request)
+	if err != nil {
+		logrus.WithError(err).WithField("address", address).Debugln("Admin API is not reachable.")
+		return false, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return false, fmt.Errorf("admin API returned unexpected status code %d", resp.StatusCode)
+	}
+	return true, nil
+}
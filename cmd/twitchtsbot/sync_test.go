@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTriggerDaemonSync(t *testing.T) {
+	statusCode := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/sync", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		w.WriteHeader(statusCode)
+	}))
+	address := strings.TrimPrefix(server.URL, "http://")
+
+	triggered, err := triggerDaemonSync(address, "token", "password")
+	assert.NoError(t, err)
+	assert.True(t, triggered)
+
+	statusCode = http.StatusUnauthorized
+	triggered, err = triggerDaemonSync(address, "token", "password")
+	assert.Error(t, err, "expected a rejected sync of a running instance to fail")
+	assert.False(t, triggered)
+
+	server.Close()
+	triggered, err = triggerDaemonSync(address, "token", "password")
+	assert.NoError(t, err, "expected an unreachable admin API to be ignored")
+	assert.False(t, triggered)
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/audit"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/logging"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/teamspeak"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// defaultTargetName is the name of the target which is built from the top level teamspeak, servergroupid and accounts
+// config keys if no targets are configured.
+const defaultTargetName = "default"
+
+// defaultQueryUser is the ServerQuery login name which is used if none is configured.
+const defaultQueryUser = "serveradmin"
+
+// teamspeakTarget is a single TeamSpeak virtual server with its own server group rules and account pairs.
+type teamspeakTarget struct {
+	Name          string          `mapstructure:"name"`
+	URL           string          `mapstructure:"url"`
+	APIKey        string          `mapstructure:"apikey"`
+	APIKeyFile    string          `mapstructure:"apikey_file"`
+	ServerID      int             `mapstructure:"serverid"`
+	ServerGroupID int             `mapstructure:"servergroupid"`
+	Accounts      []*accountEntry `mapstructure:"accounts"`
+	// Actions are performed for every presence change of the account pairs.
+	Actions []*actionConfig `mapstructure:"actions"`
+	// Presence is the deprecated predecessor of Actions. It is either servergroup or description.
+	Presence string `mapstructure:"presence"`
+	// QueryAddress is the host and port of the raw ServerQuery which delivers the client enter events. The events are
+	// disabled if it is empty.
+	QueryAddress      string `mapstructure:"queryaddress"`
+	QueryUser         string `mapstructure:"queryuser"`
+	QueryPassword     string `mapstructure:"querypassword"`
+	QueryPasswordFile string `mapstructure:"querypassword_file"`
+	// subscription tier: server group id
+	SubscriptionServerGroups map[string]int `mapstructure:"subscriptionservergroups"`
+	legacy                   bool
+	client                   *teamspeak.WebQueryClient
+	// teamspeak database identifier: streaming channel
+	pairs map[int]twitch.Channel
+	// audit records the TeamSpeak changes and is nil if the audit log is disabled
+	audit *audit.Recorder
+}
+
+// loadTargets returns all configured TeamSpeak targets. If the targets list is empty, a single target is built from
+// the top level config keys.
+func loadTargets() ([]*teamspeakTarget, error) {
+	var targets []*teamspeakTarget
+	if err := viper.UnmarshalKey("targets", &targets); err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		target := &teamspeakTarget{
+			Name:          defaultTargetName,
+			URL:           viper.GetString("teamspeak.url"),
+			APIKey:        viper.GetString("teamspeak.apikey"),
+			ServerID:      viper.GetInt("teamspeak.serverid"),
+			ServerGroupID: viper.GetInt("servergroupid"),
+			QueryAddress:  viper.GetString("teamspeak.queryaddress"),
+			QueryUser:     viper.GetString("teamspeak.queryuser"),
+			QueryPassword: viper.GetString("teamspeak.querypassword"),
+			Presence:      viper.GetString("presence"),
+			legacy:        true,
+		}
+		if err := viper.UnmarshalKey("accounts", &target.Accounts); err != nil {
+			return nil, err
+		}
+		if err := viper.UnmarshalKey("actions", &target.Actions); err != nil {
+			return nil, err
+		}
+		if err := viper.UnmarshalKey("subscriptions.servergroups", &target.SubscriptionServerGroups); err != nil {
+			return nil, err
+		}
+		if err := target.migratePresence(); err != nil {
+			return nil, err
+		}
+		return []*teamspeakTarget{target}, nil
+	}
+	names := make(map[string]bool, len(targets))
+	for i, target := range targets {
+		if target.Name == "" {
+			target.Name = "target" + strconv.Itoa(i)
+		}
+		if names[target.Name] {
+			return nil, fmt.Errorf("duplicate teamspeak target name: %s", target.Name)
+		}
+		names[target.Name] = true
+		if target.ServerID == 0 {
+			target.ServerID = 1
+		}
+		if target.QueryUser == "" {
+			target.QueryUser = defaultQueryUser
+		}
+		if target.APIKeyFile != "" {
+			apiKey, err := secrets.Watch(target.subject("apikey"), target.APIKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			target.APIKey = apiKey
+		}
+		if target.QueryPasswordFile != "" {
+			queryPassword, err := secrets.Watch(target.subject("querypassword"), target.QueryPasswordFile)
+			if err != nil {
+				return nil, err
+			}
+			target.QueryPassword = queryPassword
+		}
+		if err := target.migratePresence(); err != nil {
+			return nil, err
+		}
+	}
+	return targets, nil
+}
+
+// migratePresence maps the deprecated presence and description.format keys onto the actions of the target.
+func (target *teamspeakTarget) migratePresence() error {
+	format := viper.GetString("description.format")
+	if format != "" {
+		for _, action := range target.Actions {
+			if action.Type == actionDescription && action.Format == "" {
+				action.Format = format
+			}
+		}
+	}
+	if target.Presence == "" {
+		return nil
+	}
+	if len(target.Actions) > 0 {
+		return fmt.Errorf("%s: presence has been replaced by actions and cannot be combined with them",
+			target.subject("presence"))
+	}
+	switch target.Presence {
+	case actionServerGroup:
+		target.Actions = []*actionConfig{{Type: actionServerGroup, ServerGroupID: target.ServerGroupID}}
+	case actionDescription:
+		target.Actions = []*actionConfig{{Type: actionDescription, Format: format}}
+	default:
+		return fmt.Errorf("%s: unknown presence %q, use actions instead", target.subject("presence"),
+			target.Presence)
+	}
+	target.log().WithField("presence", target.Presence).
+		Warnln("The presence key is deprecated. Configure actions instead.")
+	return nil
+}
+
+// mustLoadTargets loads all TeamSpeak targets and exits if they cannot be loaded.
+func mustLoadTargets() []*teamspeakTarget {
+	targets, err := loadTargets()
+	if err != nil {
+		logrus.WithError(err).Fatalln("Could not load TeamSpeak targets.")
+	}
+	return targets
+}
+
+// legacyTargetKeys maps the target config keys to the top level config keys of the default target.
+var legacyTargetKeys = map[string]string{
+	"url":           "teamspeak.url",
+	"apikey":        "teamspeak.apikey",
+	"queryaddress":  "teamspeak.queryaddress",
+	"queryuser":     "teamspeak.queryuser",
+	"querypassword": "teamspeak.querypassword",
+	"presence":      "presence",
+}
+
+// subject returns the name of a config key of the target as it is shown to the user.
+func (target *teamspeakTarget) subject(key string) string {
+	if target.legacy {
+		if legacyKey, ok := legacyTargetKeys[key]; ok {
+			return legacyKey
+		}
+		return key
+	}
+	return fmt.Sprintf("targets[%s].%s", target.Name, key)
+}
+
+func (target *teamspeakTarget) log() *logrus.Entry {
+	return logrus.WithField("target", target.Name)
+}
+
+func (target *teamspeakTarget) newClient() *teamspeak.WebQueryClient {
+	return teamspeak.NewWebQueryClient(target.URL, target.APIKey, target.ServerID)
+}
+
+// connect creates the TeamSpeak client of the target if it does not exist yet and checks that the WebQuery is
+// reachable.
+func (target *teamspeakTarget) connect() error {
+	if target.client == nil {
+		target.client = target.newClient()
+		secrets.OnChange(target.subject("apikey"), target.client.SetAPIKey)
+	}
+	version, err := target.client.Version()
+	if err != nil {
+		return fmt.Errorf("could not retrieve teamspeak server version: %w", err)
+	}
+	target.log().WithField("teamspeakVersion", version).Infoln("Retrieved Teamspeak Server version.")
+	return nil
+}
+
+// queryPassword returns the current ServerQuery password of the target, which differs from the config once its secret
+// file is rotated.
+func (target *teamspeakTarget) queryPassword() string {
+	if target.legacy || target.QueryPasswordFile != "" {
+		return secrets.Get(target.subject("querypassword"))
+	}
+	return target.QueryPassword
+}
+
+// listenForEvents connects to the ServerQuery of the target, which delivers the client events. If it is not reachable,
+// the connection is retried in the background until the context is done. Server groups are still updated on stream
+// changes in the meantime, only not when clients connect. A rotated query password is used by the following connection
+// attempts, while an established connection stays logged in until the bot is restarted, as go-ts3 cannot close it.
+func (target *teamspeakTarget) listenForEvents(ctx context.Context) {
+	if target.QueryAddress == "" {
+		target.log().Infoln("No ServerQuery address configured. Server groups are not updated when clients connect.")
+		return
+	}
+	log := target.log().WithField("queryAddress", target.QueryAddress)
+	secrets.OnChange(target.subject("querypassword"), func(string) {
+		log.Infoln("The rotated ServerQuery password is used when connecting to the ServerQuery the next time.")
+	})
+	startEventClient := func() bool {
+		err := target.client.StartEventClient(target.QueryAddress, target.QueryUser, target.queryPassword())
+		if err != nil {
+			log.WithError(err).Warnln("Could not connect to Teamspeak ServerQuery.")
+			return false
+		}
+		log.Infoln("Listening for Teamspeak client events.")
+		return true
+	}
+	if startEventClient() {
+		return
+	}
+	go func() {
+		delay, maxDelay := retryDelays()
+		for {
+			log.WithField("retryIn", delay.String()).Infoln("Retrying to connect to Teamspeak ServerQuery.")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			if startEventClient() {
+				return
+			}
+			if delay *= 2; delay > maxDelay {
+				delay = maxDelay
+			}
+		}
+	}()
+}
+
+// retryDelays returns the delay before the first attempt to reconnect to a TeamSpeak server and the maximum one. They
+// are the delays of the server group change queue.
+func retryDelays() (time.Duration, time.Duration) {
+	delay, maxDelay := viper.GetDuration("queue.basedelay"), viper.GetDuration("queue.maxdelay")
+	if delay <= 0 {
+		delay = teamspeak.DefaultQueueBaseDelay
+	}
+	if maxDelay < delay {
+		maxDelay = delay
+	}
+	return delay, maxDelay
+}
+
+// loadPairs resolves the TeamSpeak database ids of all account pairs of the target.
+func (target *teamspeakTarget) loadPairs() {
+	target.pairs = make(map[int]twitch.Channel)
+	for _, account := range target.Accounts {
+		target.fetchPair(account.TsIdentifier, account.channel())
+	}
+	target.log().WithField("pairAmount", len(target.pairs)).Infoln("Fetched account pairs.")
+}
+
+func (target *teamspeakTarget) fetchPair(identifier string, channel twitch.Channel) {
+	var teamspeakDatabaseId int
+	var err error
+	if teamspeakDatabaseId, err = strconv.Atoi(identifier); err != nil {
+		target.log().WithField("identifier", identifier).Traceln("Could not parse int from identifier. Falling back to Teamspeak fetch.")
+		fetchedTeamspeakDatabaseId, err := target.client.ClientGetDbIdFromUid(identifier)
+		if err != nil {
+			target.log().WithError(err).WithField("identifier", identifier).Warnln("Could not retrieve Teamspeak database id.")
+			return
+		}
+		teamspeakDatabaseId = *fetchedTeamspeakDatabaseId
+	}
+	target.pairs[teamspeakDatabaseId] = channel
+}
+
+// hookClient returns the TeamSpeak client which should be used by the hooks of the target. If the dry run mode is
+// enabled, the client is wrapped by a DryRunClient which is returned as well.
+func (target *teamspeakTarget) hookClient() (teamspeak.Client, *teamspeak.DryRunClient) {
+	if !*dryRun && !viper.GetBool("dryrun") {
+		return target.client, nil
+	}
+	target.log().Warnln("Dry run mode is enabled. TeamSpeak changes are only logged and not applied.")
+	dryRunClient := teamspeak.NewDryRunClient(target.client)
+	return dryRunClient, dryRunClient
+}
+
+// newQueue creates the server group change queue of the target. The pending changes are persisted in a file named
+// after the target in the state directory of the queue.
+func (target *teamspeakTarget) newQueue(client teamspeak.Client) *teamspeak.Queue {
+	stateDir, err := stateDirectory("queue.statedir")
+	if err != nil {
+		target.log().WithError(err).WithField("stateDir", stateDir).Fatalln("Could not create queue state directory.")
+	}
+	stateFile := filepath.Join(stateDir, "queue-"+target.Name+".json")
+	queue, err := teamspeak.NewQueue(client, stateFile)
+	if err != nil {
+		target.log().WithError(err).WithField("stateFile", stateFile).Fatalln("Could not load pending server group changes.")
+	}
+	queue.BaseDelay = viper.GetDuration("queue.basedelay")
+	queue.MaxDelay = viper.GetDuration("queue.maxdelay")
+	queue.Members.RefreshInterval = viper.GetDuration("queue.membershiprefresh")
+	queue.Members.Audit = target.audit
+	target.setOwnership(queue)
+	return queue
+}
+
+// setOwnership sets the record of the server group memberships granted by the target. The grants are persisted in a
+// file named after the target in the state directory of the queue. Unless the bot is authoritative, only granted
+// memberships are removed.
+func (target *teamspeakTarget) setOwnership(queue *teamspeak.Queue) {
+	stateDir, err := stateDirectory("queue.statedir")
+	if err != nil {
+		target.log().WithError(err).WithField("stateDir", stateDir).Fatalln("Could not create queue state directory.")
+	}
+	stateFile := filepath.Join(stateDir, "ownership-"+target.Name+".json")
+	ownership, err := teamspeak.NewOwnership(stateFile)
+	if err != nil {
+		target.log().WithError(err).WithField("stateFile", stateFile).Fatalln("Could not load granted server groups.")
+	}
+	queue.Members.Ownership = ownership
+	queue.Members.Authoritative = viper.GetBool("authoritative")
+}
+
+// adoptMemberships records the existing memberships of the account pairs in the live and subscription server groups
+// as granted if the grants are recorded for the first time and adoptmembers is enabled, so that the memberships which
+// have been granted by earlier versions are still removed. It is disabled by default, as the members who have been put
+// into the server groups manually cannot be told apart. If the members cannot be listed, the adoption is tried again
+// on the next start. The account pairs have to be loaded before.
+func (target *teamspeakTarget) adoptMemberships(ownership *teamspeak.Ownership) {
+	if !viper.GetBool("adoptmembers") || !ownership.Fresh() {
+		return
+	}
+	serverGroupIds := make(map[int]bool)
+	for _, config := range target.actionConfigs() {
+		if config.Type == actionServerGroup && config.ServerGroupID > 0 {
+			serverGroupIds[config.ServerGroupID] = true
+		}
+	}
+	for _, serverGroupId := range target.SubscriptionServerGroups {
+		serverGroupIds[serverGroupId] = true
+	}
+	grants := make([]teamspeak.Grant, 0)
+	for serverGroupId := range serverGroupIds {
+		members, err := target.client.ServerGroupClientList(serverGroupId)
+		if err != nil {
+			target.log().WithError(err).WithField(logging.FieldServerGroupId, serverGroupId).
+				Warnln("Could not list server group members to adopt them as granted.")
+			return
+		}
+		for _, member := range *members {
+			if _, ok := target.pairs[member.ClientDbId]; ok {
+				grants = append(grants, teamspeak.Grant{ServerGroupId: serverGroupId, ClientDbId: member.ClientDbId})
+			}
+		}
+	}
+	ownership.Adopt(grants)
+	target.log().WithField("grantAmount", len(grants)).Infoln("Adopted existing server group memberships as granted.")
+}
+
+// groupChannelsByPlatform returns the distinct channel logins of the accounts of all targets mapped by their platform.
+// The accounts of targets which are not connected yet are included, so that they are monitored once they are.
+func groupChannelsByPlatform(targets []*teamspeakTarget) map[string][]string {
+	platformLogins := make(map[string][]string)
+	seen := make(map[twitch.Channel]bool)
+	for _, target := range targets {
+		for _, account := range target.Accounts {
+			channel := account.channel()
+			if seen[channel] {
+				continue
+			}
+			seen[channel] = true
+			platformLogins[channel.Platform] = append(platformLogins[channel.Platform], channel.Login)
+		}
+	}
+	return platformLogins
+}
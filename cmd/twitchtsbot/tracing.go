@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"os"
+)
+
+// Exporters which can be configured as tracing.exporter. Tracing is disabled if no exporter is set.
+const (
+	tracingExporterOTLP   = "otlp"
+	tracingExporterStdout = "stdout"
+)
+
+// setupTracing registers the tracer provider which exports the spans of the polls, the presence changes and the
+// TeamSpeak operations to the configured exporter. The returned function exports the remaining spans and stops the
+// provider.
+func setupTracing() (func(ctx context.Context) error, error) {
+	exporter, err := newSpanExporter()
+	if err != nil || exporter == nil {
+		return func(context.Context) error { return nil }, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String("twitchtsbot"),
+			semconv.ServiceVersionKey.String(GitVersion),
+		)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(viper.GetFloat64("tracing.sampleratio")))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// newSpanExporter returns the configured exporter or nil if tracing is disabled.
+func newSpanExporter() (sdktrace.SpanExporter, error) {
+	switch exporter := viper.GetString("tracing.exporter"); exporter {
+	case "":
+		return nil, nil
+	case tracingExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case tracingExporterOTLP:
+		options := make([]otlptracehttp.Option, 0)
+		// without an endpoint, the OTEL_EXPORTER_OTLP_ENDPOINT environment variable or localhost:4318 is used
+		if endpoint := viper.GetString("tracing.endpoint"); endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(endpoint))
+		}
+		if viper.GetBool("tracing.insecure") {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(context.Background(), options...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", exporter)
+	}
+}
@@ -0,0 +1,92 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ErrInvalidAccount is wrapped by the errors of an AccountManager if the account cannot be added or removed, e.g.
+// because it already exists or does not exist.
+var ErrInvalidAccount = errors.New("invalid account")
+
+// Account is a single account pair of a TeamSpeak target.
+type Account struct {
+	Target       string `json:"target"`
+	TsIdentifier string `json:"ts"`
+	Platform     string `json:"platform"`
+	Login        string `json:"login"`
+}
+
+// AccountManager lists and changes the configured account pairs. Changes are persisted to the config and take effect
+// after a restart.
+type AccountManager interface {
+	Accounts() ([]Account, error)
+	AddAccount(account Account) error
+	RemoveAccount(account Account) error
+}
+
+func (server *Server) handleAccounts(w http.ResponseWriter, r *http.Request) {
+	if server.Accounts == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "account management is disabled"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		accounts, err := server.Accounts.Accounts()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, accounts)
+	case http.MethodPost:
+		account, ok := readAccount(w, r)
+		if !ok {
+			return
+		}
+		if err := server.Accounts.AddAccount(account); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, account)
+	case http.MethodDelete:
+		account, ok := readAccount(w, r)
+		if !ok {
+			return
+		}
+		if err := server.Accounts.RemoveAccount(account); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// readAccount decodes the account of the JSON request body. Requiring JSON prevents cross site form submissions as
+// browsers do not send this content type without a CORS preflight.
+func readAccount(w http.ResponseWriter, r *http.Request) (Account, bool) {
+	var account Account
+	if !isJSON(r) {
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": "expected json request body"})
+		return account, false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&account); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid account"})
+		return account, false
+	}
+	if account.TsIdentifier == "" || account.Login == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "ts and login are required"})
+		return account, false
+	}
+	return account, true
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrInvalidAccount) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,76 @@
+package admin
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testAccountManager struct {
+	accounts []Account
+}
+
+func (manager *testAccountManager) Accounts() ([]Account, error) {
+	return manager.accounts, nil
+}
+
+func (manager *testAccountManager) AddAccount(account Account) error {
+	for _, existing := range manager.accounts {
+		if existing == account {
+			return fmt.Errorf("%w: account pair already exists", ErrInvalidAccount)
+		}
+	}
+	manager.accounts = append(manager.accounts, account)
+	return nil
+}
+
+func (manager *testAccountManager) RemoveAccount(account Account) error {
+	for i, existing := range manager.accounts {
+		if existing == account {
+			manager.accounts = append(manager.accounts[:i], manager.accounts[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: account pair does not exist", ErrInvalidAccount)
+}
+
+func TestServer_Accounts(t *testing.T) {
+	manager := &testAccountManager{}
+	server := NewServer("v1.0.0", "main", nil, nil, nil, nil)
+	server.Accounts = manager
+	request := func(method, contentType, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		r := httptest.NewRequest(method, "/api/accounts", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		server.Handler().ServeHTTP(recorder, r)
+		return recorder
+	}
+	body := `{"target":"default","ts":"3","platform":"twitch","login":"streamer"}`
+	assert.Equal(t, http.StatusCreated, request(http.MethodPost, "application/json", body).Code)
+	assert.Equal(t, []Account{{Target: "default", TsIdentifier: "3", Platform: "twitch", Login: "streamer"}},
+		manager.accounts)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, "application/json", body).Code,
+		"expected duplicate account to be rejected")
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, "application/json; charset=utf-8", body).Code,
+		"expected the content type parameters to be ignored")
+	assert.Equal(t, http.StatusUnsupportedMediaType,
+		request(http.MethodPost, "application/x-www-form-urlencoded", "ts=3&login=streamer").Code,
+		"expected form submissions to be rejected")
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, "application/json", `{"ts":"3"}`).Code)
+	recorder := request(http.MethodGet, "", "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, "["+body+"]", recorder.Body.String())
+	assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, "application/json", body).Code)
+	assert.Empty(t, manager.accounts)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodDelete, "application/json", body).Code)
+}
+
+func TestServer_AccountsDisabled(t *testing.T) {
+	server := NewServer("v1.0.0", "main", nil, nil, nil, nil)
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/accounts", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
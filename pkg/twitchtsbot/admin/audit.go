@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/audit"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// handleAudit responds with the entries of the audit log which match the query parameters. since limits the entries
+// to a duration such as 168h, while target, ts, login, action, trigger and outcome have to match exactly. limit keeps
+// only the newest entries.
+func (server *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if server.Audit == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "audit log is disabled"})
+		return
+	}
+	filter, message := parseAuditFilter(r.URL.Query(), time.Now())
+	if message != "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": message})
+		return
+	}
+	entries, err := server.Audit.Query(filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// parseAuditFilter returns the filter of the query parameters or the message of the invalid parameter.
+func parseAuditFilter(query url.Values, now time.Time) (audit.Filter, string) {
+	filter := audit.Filter{
+		Target:  query.Get("target"),
+		Login:   query.Get("login"),
+		Action:  query.Get("action"),
+		Trigger: query.Get("trigger"),
+		Outcome: query.Get("outcome"),
+	}
+	if value := query.Get("since"); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return filter, "invalid since duration"
+		}
+		filter.Since = now.Add(-duration)
+	}
+	if value := query.Get("ts"); value != "" {
+		clientDbId, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, "invalid ts database id"
+		}
+		filter.ClientDbId = clientDbId
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return filter, "invalid limit"
+		}
+		filter.Limit = limit
+	}
+	return filter, ""
+}
@@ -0,0 +1,41 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// authenticate only passes requests to the handler which either contain the token as bearer token or the password by
+// HTTP basic authentication. As browsers cannot send a bearer token when navigating to the dashboard, the token is
+// accepted as basic authentication password as well. All requests are passed if neither a password nor a token is
+// configured, which Start refuses.
+func (server *Server) authenticate(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if server.Password == "" && server.Token == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if server.Token != "" && strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") &&
+			secureCompare(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), server.Token) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if _, password, ok := r.BasicAuth(); ok && server.validPassword(password) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="twitchtsbot", charset="UTF-8"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	})
+}
+
+// validPassword returns whether the password of the HTTP basic authentication is either the password or the token.
+func (server *Server) validPassword(password string) bool {
+	return (server.Password != "" && secureCompare(password, server.Password)) ||
+		(server.Token != "" && secureCompare(password, server.Token))
+}
+
+func secureCompare(given, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
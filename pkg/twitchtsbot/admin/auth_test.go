@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer_Authenticate(t *testing.T) {
+	server := NewServer("v1.0.0", "main", nil, nil, nil, nil)
+	request := func(modify func(r *http.Request)) int {
+		recorder := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+		modify(r)
+		server.Handler().ServeHTTP(recorder, r)
+		return recorder.Code
+	}
+	assert.Equal(t, http.StatusOK, request(func(r *http.Request) {}), "expected no authentication by default")
+	server.Password = "password"
+	server.Token = "token"
+	assert.Equal(t, http.StatusUnauthorized, request(func(r *http.Request) {}))
+	assert.Equal(t, http.StatusOK, request(func(r *http.Request) {
+		r.SetBasicAuth("admin", "password")
+	}))
+	assert.Equal(t, http.StatusUnauthorized, request(func(r *http.Request) {
+		r.SetBasicAuth("admin", "wrong")
+	}))
+	assert.Equal(t, http.StatusOK, request(func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer token")
+	}))
+	assert.Equal(t, http.StatusUnauthorized, request(func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer password")
+	}))
+	server.Password = ""
+	assert.Equal(t, http.StatusUnauthorized, request(func(r *http.Request) {
+		r.SetBasicAuth("admin", "")
+	}), "expected empty password to be rejected if only a token is configured")
+	assert.Equal(t, http.StatusOK, request(func(r *http.Request) {
+		r.SetBasicAuth("admin", "token")
+	}), "expected the token to be accepted as basic authentication password")
+}
+
+func TestServer_AuthenticateDashboard(t *testing.T) {
+	server := NewServer("v1.0.0", "main", nil, nil, nil, nil)
+	request := func(path string, modify func(r *http.Request)) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		modify(r)
+		server.Handler().ServeHTTP(recorder, r)
+		return recorder
+	}
+	server.Token = "token"
+	for _, path := range []string{"/", "/app.js", "/api/status"} {
+		recorder := request(path, func(r *http.Request) {})
+		assert.Equalf(t, http.StatusUnauthorized, recorder.Code, "expected %s to require the token", path)
+		assert.NotEmptyf(t, recorder.Header().Get("WWW-Authenticate"), "expected %s to ask the browser", path)
+		assert.Equalf(t, http.StatusOK, request(path, func(r *http.Request) {
+			r.SetBasicAuth("admin", "token")
+		}).Code, "expected %s to be served with the token", path)
+	}
+	server.Password = "password"
+	assert.Equal(t, http.StatusUnauthorized, request("/", func(r *http.Request) {}).Code,
+		"expected the dashboard to require the password")
+}
+
+func TestServer_StartWithoutCredentials(t *testing.T) {
+	server := NewServer("v1.0.0", "main", nil, nil, nil, nil)
+	assert.Equal(t, ErrNoCredentials, server.Start("127.0.0.1:0"))
+}
@@ -0,0 +1,153 @@
+"use strict";
+
+const refreshInterval = 5000;
+
+async function api(method, path, body) {
+  const options = {method: method, headers: {}};
+  if (body !== undefined) {
+    options.headers["Content-Type"] = "application/json";
+    options.body = JSON.stringify(body);
+  }
+  const response = await fetch(path, options);
+  if (!response.ok) {
+    let message = response.statusText;
+    try {
+      message = (await response.json()).error || message;
+    } catch (e) {
+      // keep status text
+    }
+    throw new Error(message);
+  }
+  if (response.status === 204 || response.status === 202) {
+    return null;
+  }
+  return response.json();
+}
+
+function element(tag, attributes, ...children) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(attributes || {})) {
+    node.setAttribute(key, value);
+  }
+  for (const child of children) {
+    node.append(child instanceof Node ? child : document.createTextNode(child === undefined ? "" : String(child)));
+  }
+  return node;
+}
+
+function row(...cells) {
+  return element("tr", {}, ...cells.map(cell => element("td", {}, cell)));
+}
+
+function formatTime(value) {
+  const time = new Date(value);
+  return isNaN(time.getTime()) || time.getFullYear() < 2000 ? "-" : time.toLocaleString();
+}
+
+function showMessage(text) {
+  const message = document.getElementById("message");
+  message.textContent = text;
+  message.hidden = false;
+  clearTimeout(showMessage.timeout);
+  showMessage.timeout = setTimeout(() => message.hidden = true, 4000);
+}
+
+function renderStreams(streams) {
+  const container = document.getElementById("streams");
+  container.replaceChildren(...streams.map(state => {
+    const live = state.status === "live";
+    const stream = state.stream || {};
+    const thumbnail = live && stream.thumbnailUrl
+      ? element("img", {src: stream.thumbnailUrl.replace("{width}", "440").replace("{height}", "248"), alt: ""})
+      : element("span", {class: "placeholder"});
+    const title = live ? [stream.gameName || stream.gameId, stream.title].filter(Boolean).join(" - ") : "";
+    return element("div", {class: "card"}, thumbnail, element("div", {},
+      element("strong", {}, state.userLogin), " ",
+      element("span", {class: live ? "badge live" : "badge"}, state.status), " ",
+      element("span", {class: "title"}, state.platform),
+      element("div", {class: "title"}, title),
+      element("div", {class: "title"}, (live ? stream.viewerCount + " viewers, " : "") + "since " +
+        formatTime(state.since))));
+  }));
+}
+
+function renderPending(pendingMutations) {
+  const rows = [];
+  for (const [target, mutations] of Object.entries(pendingMutations || {})) {
+    for (const mutation of mutations) {
+      rows.push(row(target, mutation.add ? "add" : "remove", mutation.serverGroupId, mutation.clientDbId,
+        mutation.attempts, formatTime(mutation.nextAttempt)));
+    }
+  }
+  document.getElementById("pending").replaceChildren(...rows);
+}
+
+function renderAccounts(accounts) {
+  document.getElementById("accounts").replaceChildren(...accounts.map(account => {
+    const remove = element("button", {type: "button"}, "Remove");
+    remove.addEventListener("click", async () => {
+      try {
+        await api("DELETE", "api/accounts", account);
+        showMessage("Removed account pair. Restart the bot to apply the change.");
+        await refreshAccounts();
+      } catch (e) {
+        showMessage("Could not remove account pair: " + e.message);
+      }
+    });
+    return row(account.target, account.ts, account.platform, account.login, remove);
+  }));
+}
+
+function renderEvents(events) {
+  document.getElementById("events").replaceChildren(...events.map(event =>
+    row(formatTime(event.time), element("span", {class: "badge " + event.kind}, event.kind), event.channel || "",
+      event.message)));
+}
+
+async function refreshAccounts() {
+  try {
+    renderAccounts(await api("GET", "api/accounts"));
+  } catch (e) {
+    document.getElementById("accounts").replaceChildren(row("", "", "", e.message, ""));
+  }
+}
+
+async function refresh() {
+  try {
+    const status = await api("GET", "api/status");
+    document.getElementById("version").textContent = status.version + " (" + status.branch + ")";
+    document.getElementById("dry-run").hidden = !status.dryRun;
+    renderStreams(status.streams);
+    renderPending(status.pendingMutations);
+    renderEvents(await api("GET", "api/events"));
+  } catch (e) {
+    showMessage("Could not refresh status: " + e.message);
+  }
+}
+
+document.getElementById("sync").addEventListener("click", async () => {
+  try {
+    await api("POST", "api/sync", {});
+    showMessage("Started sync of all server groups.");
+  } catch (e) {
+    showMessage("Could not start sync: " + e.message);
+  }
+});
+
+document.getElementById("add-account").addEventListener("submit", async event => {
+  event.preventDefault();
+  const form = event.target;
+  const account = Object.fromEntries(new FormData(form).entries());
+  try {
+    await api("POST", "api/accounts", account);
+    form.reset();
+    showMessage("Added account pair. Restart the bot to apply the change.");
+    await refreshAccounts();
+  } catch (e) {
+    showMessage("Could not add account pair: " + e.message);
+  }
+});
+
+refresh();
+refreshAccounts();
+setInterval(refresh, refreshInterval);
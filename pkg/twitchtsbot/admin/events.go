@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"context"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// maxEvents is the amount of recent events which are kept by the EventLog.
+const maxEvents = 200
+
+const (
+	EventTransition = "transition"
+	EventWarning    = "warning"
+	EventError      = "error"
+)
+
+// Event is a stream transition or a logged problem which is shown in the dashboard.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Message string    `json:"message"`
+	// Channel is only set for stream transitions.
+	Channel string `json:"channel,omitempty"`
+}
+
+// EventLog keeps the most recent events in memory. It implements logrus.Hook to record all logged warnings and errors.
+type EventLog struct {
+	*sync.Mutex
+	events []Event
+}
+
+func NewEventLog() *EventLog {
+	return &EventLog{Mutex: &sync.Mutex{}}
+}
+
+func (eventLog *EventLog) Add(event Event) {
+	eventLog.Lock()
+	defer eventLog.Unlock()
+	eventLog.events = append(eventLog.events, event)
+	if len(eventLog.events) > maxEvents {
+		eventLog.events = eventLog.events[len(eventLog.events)-maxEvents:]
+	}
+}
+
+// Events returns the recorded events from the newest to the oldest one.
+func (eventLog *EventLog) Events() []Event {
+	eventLog.Lock()
+	defer eventLog.Unlock()
+	events := make([]Event, len(eventLog.events))
+	for i, event := range eventLog.events {
+		events[len(events)-1-i] = event
+	}
+	return events
+}
+
+// RecordTransitions adds an event for every state received from the channel until the context is done.
+func (eventLog *EventLog) RecordTransitions(ctx context.Context, notifyChan <-chan *twitch.UserState) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case state, ok := <-notifyChan:
+				if !ok {
+					return
+				}
+				eventLog.Add(Event{
+					Time:    state.Since,
+					Kind:    EventTransition,
+					Message: state.StreamerStatus.String(),
+					Channel: state.Channel().String(),
+				})
+			}
+		}
+	}()
+}
+
+func (eventLog *EventLog) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel, logrus.WarnLevel}
+}
+
+func (eventLog *EventLog) Fire(entry *logrus.Entry) error {
+	kind := EventError
+	if entry.Level == logrus.WarnLevel {
+		kind = EventWarning
+	}
+	message := entry.Message
+	if err, ok := entry.Data[logrus.ErrorKey].(error); ok {
+		message += ": " + err.Error()
+	}
+	eventLog.Add(Event{Time: entry.Time, Kind: kind, Message: message})
+	return nil
+}
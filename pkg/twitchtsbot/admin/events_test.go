@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEventLog_Add(t *testing.T) {
+	eventLog := NewEventLog()
+	for i := 0; i < maxEvents+10; i++ {
+		eventLog.Add(Event{Message: strconv.Itoa(i)})
+	}
+	events := eventLog.Events()
+	assert.Len(t, events, maxEvents, "expected event log to be limited")
+	assert.Equal(t, strconv.Itoa(maxEvents+9), events[0].Message, "expected newest event to be first")
+	assert.Equal(t, "10", events[len(events)-1].Message)
+}
+
+func TestEventLog_Hook(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	eventLog := NewEventLog()
+	logger.AddHook(eventLog)
+	logger.Infoln("ignored")
+	logger.Warnln("warning")
+	logger.WithError(errors.New("connection refused")).Errorln("could not update")
+	events := eventLog.Events()
+	if assert.Len(t, events, 2, "expected only warnings and errors to be recorded") {
+		assert.Equal(t, EventError, events[0].Kind)
+		assert.Equal(t, "could not update: connection refused", events[0].Message)
+		assert.Equal(t, EventWarning, events[1].Kind)
+	}
+	assert.Contains(t, eventLog.Levels(), logrus.WarnLevel)
+}
+
+func TestEventLog_RecordTransitions(t *testing.T) {
+	eventLog := NewEventLog()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notifyChan := make(chan *twitch.UserState)
+	eventLog.RecordTransitions(ctx, notifyChan)
+	notifyChan <- &twitch.UserState{Platform: twitch.PlatformTwitch, UserLogin: "streamer",
+		StreamerStatus: twitch.StreamerStatusLive, Since: time.Now()}
+	assert.Eventually(t, func() bool {
+		return len(eventLog.Events()) == 1
+	}, time.Second, 10*time.Millisecond)
+	event := eventLog.Events()[0]
+	assert.Equal(t, EventTransition, event.Kind)
+	assert.Equal(t, "twitch/streamer", event.Channel)
+	assert.Equal(t, "live", event.Message)
+}
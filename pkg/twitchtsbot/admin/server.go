@@ -0,0 +1,241 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/audit"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/history"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/teamspeak"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"mime"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// MonitorStatus contains the polling state of the stream monitor of a single platform.
+type MonitorStatus struct {
+	Interval          string `json:"interval"`
+	EffectiveInterval string `json:"effectiveInterval"`
+	Failures          int    `json:"failures"`
+	// RateLimit is only set if the platform API reports its rate limit.
+	RateLimit *twitch.RateLimit `json:"rateLimit,omitempty"`
+}
+
+// Status is the response body of the status endpoint.
+type Status struct {
+	Version string              `json:"version"`
+	Branch  string              `json:"branch"`
+	DryRun  bool                `json:"dryRun"`
+	Streams []*twitch.UserState `json:"streams"`
+	// platform identifier: monitor polling state
+	Monitors map[string]*MonitorStatus `json:"monitors"`
+	// teamspeak target name: skipped mutations
+	DryRunMutations map[string][]teamspeak.Mutation `json:"dryRunMutations,omitempty"`
+	// teamspeak target name: server group changes which have not been applied yet
+	PendingMutations map[string][]teamspeak.PendingMutation `json:"pendingMutations"`
+}
+
+// Server serves the admin API which exposes the state of a running bot instance.
+type Server struct {
+	Version string
+	Branch  string
+	// platform identifier: monitor
+	Monitors map[string]*twitch.Monitor
+	// teamspeak target name: dry run client, empty if the dry run mode is disabled
+	DryRuns map[string]*teamspeak.DryRunClient
+	// teamspeak target name: server group change queue
+	Queues map[string]*teamspeak.Queue
+	// History is nil if the stream history is disabled.
+	History *history.Store
+	// the following fields are optional and have to be set before the server is started
+	// Accounts allows to manage the account pairs by the dashboard and is nil if it is not supported.
+	Accounts AccountManager
+	// Sync reconciles all server groups and is nil if it is not supported.
+	Sync   func() error
+	Events *EventLog
+	// Audit is the audit log of the TeamSpeak changes and is nil if it is disabled.
+	Audit *audit.Store
+	// Password is required by HTTP basic authentication and Token as bearer token. At least one of them has to be set
+	// before the server is started.
+	Password   string
+	Token      string
+	httpServer *http.Server
+}
+
+func NewServer(version, branch string, monitors map[string]*twitch.Monitor,
+	dryRuns map[string]*teamspeak.DryRunClient, queues map[string]*teamspeak.Queue, historyStore *history.Store) *Server {
+	server := &Server{
+		Version:  version,
+		Branch:   branch,
+		Monitors: monitors,
+		DryRuns:  dryRuns,
+		Queues:   queues,
+		History:  historyStore,
+	}
+	server.httpServer = &http.Server{Handler: server.Handler()}
+	return server
+}
+
+// ErrNoCredentials is returned by Start if neither a password nor a token is configured.
+var ErrNoCredentials = errors.New("admin API requires a password or token")
+
+// Handler returns the HTTP handler of all admin API endpoints and the dashboard.
+func (server *Server) Handler() http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("/api/status", server.handleStatus)
+	api.HandleFunc("/api/stats", server.handleStats)
+	api.HandleFunc("/api/accounts", server.handleAccounts)
+	api.HandleFunc("/api/events", server.handleEvents)
+	api.HandleFunc("/api/sync", server.handleSync)
+	api.HandleFunc("/api/audit", server.handleAudit)
+	mux := http.NewServeMux()
+	mux.Handle("/api/", server.authenticate(api))
+	mux.Handle("/", server.authenticate(dashboardHandler()))
+	return mux
+}
+
+// Start listens on the given address and serves the admin API in the background. It refuses to serve the admin API
+// without a password or token, as it allows to change the account pairs and the server groups.
+func (server *Server) Start(address string) error {
+	if server.Password == "" && server.Token == "" {
+		return ErrNoCredentials
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	Log.WithField("address", listener.Addr().String()).Infoln("Starting admin API")
+	go func() {
+		if err := server.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			Log.WithError(err).Errorln("Admin API stopped unexpectedly!")
+		}
+	}()
+	return nil
+}
+
+func (server *Server) Shutdown(ctx context.Context) error {
+	return server.httpServer.Shutdown(ctx)
+}
+
+func (server *Server) status() *Status {
+	status := &Status{
+		Version:          server.Version,
+		Branch:           server.Branch,
+		DryRun:           len(server.DryRuns) > 0,
+		Streams:          make([]*twitch.UserState, 0),
+		Monitors:         make(map[string]*MonitorStatus, len(server.Monitors)),
+		PendingMutations: make(map[string][]teamspeak.PendingMutation, len(server.Queues)),
+	}
+	for target, queue := range server.Queues {
+		status.PendingMutations[target] = queue.Pending()
+	}
+	platforms := make([]string, 0, len(server.Monitors))
+	for platform := range server.Monitors {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+	for _, platform := range platforms {
+		monitor := server.Monitors[platform]
+		status.Streams = append(status.Streams, monitor.GetStates()...)
+		monitorStatus := &MonitorStatus{
+			Interval:          monitor.Interval.String(),
+			EffectiveInterval: monitor.EffectiveInterval().String(),
+			Failures:          monitor.Failures(),
+		}
+		if provider, ok := monitor.Provider.(twitch.RateLimitedProvider); ok {
+			if rateLimit, ok := provider.RateLimit(); ok {
+				monitorStatus.RateLimit = &rateLimit
+			}
+		}
+		status.Monitors[platform] = monitorStatus
+	}
+	if len(server.DryRuns) > 0 {
+		status.DryRunMutations = make(map[string][]teamspeak.Mutation, len(server.DryRuns))
+		for target, dryRun := range server.DryRuns {
+			status.DryRunMutations[target] = dryRun.Mutations()
+		}
+	}
+	return status
+}
+
+func (server *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, server.status())
+}
+
+// handleStats responds with the stream statistics of all channels. The optional since query parameter limits the
+// statistics to a duration such as 168h.
+func (server *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if server.History == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "stream history is disabled"})
+		return
+	}
+	now := time.Now()
+	var since time.Time
+	if value := r.URL.Query().Get("since"); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid since duration"})
+			return
+		}
+		since = now.Add(-duration)
+	}
+	writeJSON(w, http.StatusOK, server.History.Stats(since, now))
+}
+
+func (server *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	events := make([]Event, 0)
+	if server.Events != nil {
+		events = server.Events.Events()
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// handleSync reconciles all server groups. Only JSON requests are accepted to prevent cross site form submissions.
+func (server *Server) handleSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !isJSON(r) {
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": "expected json request body"})
+		return
+	}
+	if server.Sync == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "sync is not supported"})
+		return
+	}
+	if err := server.Sync(); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// isJSON returns whether the content type of the request is JSON. Parameters such as the charset are ignored.
+func isJSON(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		Log.WithError(err).Warnln("Could not write admin API response.")
+	}
+}
@@ -0,0 +1,170 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/audit"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/history"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/teamspeak"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testProvider struct{}
+
+func (provider *testProvider) Platform() string {
+	return twitch.PlatformTwitch
+}
+
+func (provider *testProvider) LiveStreams(_ []string) ([]twitch.Stream, error) {
+	return nil, nil
+}
+
+func TestServer_Status(t *testing.T) {
+	notifyChan := make(chan *twitch.UserState, 1)
+	monitor := twitch.NewProviderMonitor(&testProvider{}, []string{"testuser"}, time.Second, context.Background(), notifyChan)
+	monitor.States = map[string]*twitch.UserState{"testuser": {Platform: twitch.PlatformTwitch, UserLogin: "testuser"}}
+	server := NewServer("v1.0.0", "main", map[string]*twitch.Monitor{twitch.PlatformTwitch: monitor}, nil, nil, nil)
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "expected status endpoint to respond with status ok")
+	var status map[string]interface{}
+	assert.Nil(t, json.NewDecoder(recorder.Body).Decode(&status), "expected status response to be valid json")
+	assert.Equal(t, "v1.0.0", status["version"])
+	assert.Equal(t, false, status["dryRun"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"platform":  twitch.PlatformTwitch,
+		"userLogin": "testuser",
+		"status":    "offline",
+		"since":     "0001-01-01T00:00:00Z",
+	}}, status["streams"])
+	assert.Equal(t, map[string]interface{}{twitch.PlatformTwitch: map[string]interface{}{
+		"interval":          "1s",
+		"effectiveInterval": "1s",
+		"failures":          float64(0),
+	}}, status["monitors"])
+	assert.NotContains(t, status, "dryRunMutations", "expected dry run mutations to be omitted")
+}
+
+func TestServer_StatusDryRun(t *testing.T) {
+	dryRun := teamspeak.NewDryRunClient(nil)
+	server := NewServer("v1.0.0", "main", nil, map[string]*teamspeak.DryRunClient{"default": dryRun}, nil, nil)
+	_ = dryRun.ServerGroupAddClient(42, 1)
+	status := server.status()
+	assert.True(t, status.DryRun, "expected dry run to be enabled")
+	assert.Len(t, status.DryRunMutations["default"], 1, "expected status to contain dry run mutations")
+}
+
+func TestServer_StatusMethodNotAllowed(t *testing.T) {
+	server := NewServer("v1.0.0", "main", nil, nil, nil, nil)
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/status", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func TestServer_StatusPendingMutations(t *testing.T) {
+	queue, err := teamspeak.NewQueue(nil, "")
+	assert.NoError(t, err)
+	queue.SetServerGroup(42, 1, true)
+	server := NewServer("v1.0.0", "main", nil, nil, map[string]*teamspeak.Queue{"default": queue}, nil)
+	status := server.status()
+	if assert.Len(t, status.PendingMutations["default"], 1, "expected status to contain pending mutations") {
+		assert.Equal(t, 42, status.PendingMutations["default"][0].ServerGroupId)
+		assert.True(t, status.PendingMutations["default"][0].Add)
+	}
+}
+
+func TestServer_Stats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twitchtsbot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store, err := history.NewStore(filepath.Join(dir, "history.json"), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Record(&twitch.UserState{Platform: twitch.PlatformTwitch, UserLogin: "testuser",
+		StreamerStatus: twitch.StreamerStatusLive, Since: time.Now().Add(-time.Hour)}))
+	server := NewServer("v1.0.0", "main", nil, nil, nil, store)
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/stats?since=168h", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var stats []*history.Stats
+	assert.Nil(t, json.NewDecoder(recorder.Body).Decode(&stats), "expected stats response to be valid json")
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, "testuser", stats[0].UserLogin)
+		assert.Equal(t, 1, stats[0].Streams)
+		assert.True(t, stats[0].Live)
+	}
+	recorder = httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/stats?since=week", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestServer_StatsDisabled(t *testing.T) {
+	server := NewServer("v1.0.0", "main", nil, nil, nil, nil)
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestServer_Audit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twitchtsbot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	server := NewServer("v1.0.0", "main", nil, nil, nil, nil)
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/audit", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code, "expected the audit log to be disabled")
+
+	server.Audit = audit.NewStore(filepath.Join(dir, "audit.jsonl"))
+	for _, clientDbId := range []int{1, 2, 1} {
+		assert.NoError(t, server.Audit.Append(audit.Entry{Time: time.Now(), Target: "default", ClientDbId: clientDbId,
+			Action: audit.ActionServerGroupAdded, Trigger: audit.TriggerTransition, Outcome: audit.OutcomeSuccess}))
+	}
+	recorder = httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder,
+		httptest.NewRequest(http.MethodGet, "/api/audit?since=1h&ts=1&trigger=transition&limit=5", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var entries []audit.Entry
+	assert.Nil(t, json.NewDecoder(recorder.Body).Decode(&entries), "expected audit response to be valid json")
+	assert.Len(t, entries, 2)
+	for _, query := range []string{"since=week", "ts=streamer", "limit=-1"} {
+		recorder = httptest.NewRecorder()
+		server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/audit?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "expected %s to be rejected", query)
+	}
+}
+
+func TestServer_Sync(t *testing.T) {
+	server := NewServer("v1.0.0", "main", nil, nil, nil, nil)
+	synced := false
+	server.Sync = func() error {
+		synced = true
+		return nil
+	}
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/api/sync", strings.NewReader("{}"))
+	request.Header.Set("Content-Type", "application/json")
+	server.Handler().ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+	assert.True(t, synced, "expected sync to be triggered")
+	recorder = httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/sync", nil))
+	assert.Equal(t, http.StatusUnsupportedMediaType, recorder.Code)
+}
+
+func TestServer_Dashboard(t *testing.T) {
+	server := NewServer("v1.0.0", "main", nil, nil, nil, nil)
+	for _, path := range []string{"/", "/app.js", "/style.css"} {
+		recorder := httptest.NewRecorder()
+		server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equalf(t, http.StatusOK, recorder.Code, "expected dashboard file %s to be served", path)
+		assert.NotEmpty(t, recorder.Body.String())
+	}
+}
@@ -0,0 +1,126 @@
+// Package audit records every change the bot makes to TeamSpeak clients in an append-only file, so that it can be
+// looked up later why a client has got or lost a group and whether the change has been applied.
+package audit
+
+import (
+	"context"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/logging"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// Actions which are recorded in the audit log.
+const (
+	ActionServerGroupAdded    = "servergroup_added"
+	ActionServerGroupRemoved  = "servergroup_removed"
+	ActionChannelGroupChanged = "channelgroup_changed"
+	ActionDescriptionChanged  = "description_changed"
+	ActionDescriptionRestored = "description_restored"
+)
+
+// Triggers which cause a change.
+const (
+	// TriggerTransition is a stream which has started or ended according to the poll of its platform.
+	TriggerTransition = "transition"
+	// TriggerMetadata is a changed title or game of a running stream.
+	TriggerMetadata = "metadata"
+	// TriggerEnterView is a streamer who has connected to the TeamSpeak server.
+	TriggerEnterView = "enterview"
+	// TriggerReconciliation is the reconciliation of the current states on startup.
+	TriggerReconciliation = "reconciliation"
+	// TriggerAdmin is a sync which has been started by the sync command or the admin API.
+	TriggerAdmin = "admin"
+	// TriggerSubscription is a changed subscription tier.
+	TriggerSubscription = "subscription"
+	// TriggerShutdown is the removal of the changes of the bot while it shuts down.
+	TriggerShutdown = "shutdown"
+)
+
+// Outcomes of a change.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	// OutcomeSkipped is a change which has been decided but deliberately not applied.
+	OutcomeSkipped = "skipped"
+)
+
+// Entry is a single change of a TeamSpeak client.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Target is the name of the TeamSpeak target the client belongs to.
+	Target     string `json:"target"`
+	ClientDbId int    `json:"clientDbId"`
+	Platform   string `json:"platform,omitempty"`
+	Login      string `json:"login,omitempty"`
+	Action     string `json:"action"`
+	// ServerGroupId is only set for server group changes.
+	ServerGroupId int `json:"serverGroupId,omitempty"`
+	// ChannelId and ChannelGroupId are only set for channel group changes.
+	ChannelId      int    `json:"channelId,omitempty"`
+	ChannelGroupId int    `json:"channelGroupId,omitempty"`
+	Trigger        string `json:"trigger"`
+	Outcome        string `json:"outcome"`
+	// Reason is the error of a failed change or the reason why a change has been skipped.
+	Reason string `json:"reason,omitempty"`
+	// CorrelationId is the id of the trace of the change if tracing is enabled.
+	CorrelationId string `json:"correlationId,omitempty"`
+}
+
+// Cause describes why a change is made. It is passed along with the context of the change.
+type Cause struct {
+	Trigger  string `json:"trigger"`
+	Platform string `json:"platform,omitempty"`
+	Login    string `json:"login,omitempty"`
+}
+
+type causeKey struct{}
+
+// WithCause returns a copy of the context which carries the cause.
+func WithCause(ctx context.Context, cause Cause) context.Context {
+	return context.WithValue(ctx, causeKey{}, cause)
+}
+
+// CauseFromContext returns the cause carried by the context, which is empty if it has not been set.
+func CauseFromContext(ctx context.Context) Cause {
+	cause, _ := ctx.Value(causeKey{}).(Cause)
+	return cause
+}
+
+// Recorder records the changes of a single TeamSpeak target. A nil Recorder does not record anything, so that the
+// audit log can be disabled.
+type Recorder struct {
+	Store  *Store
+	Target string
+}
+
+// Record completes the entry and appends it to the store. The trigger and the channel are taken from the cause in the
+// context and the correlation id from its span. Unless the outcome of the entry is set, it is derived from the error
+// of the change. A failed append is only logged, as it must not prevent the change.
+func (recorder *Recorder) Record(ctx context.Context, entry Entry, err error) {
+	if recorder == nil {
+		return
+	}
+	cause := CauseFromContext(ctx)
+	entry.Time = time.Now()
+	entry.Target = recorder.Target
+	entry.Trigger = cause.Trigger
+	entry.Platform, entry.Login = cause.Platform, cause.Login
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		entry.CorrelationId = spanContext.TraceID().String()
+	}
+	if entry.Outcome == "" {
+		entry.Outcome = OutcomeSuccess
+		if err != nil {
+			entry.Outcome = OutcomeFailure
+			entry.Reason = err.Error()
+		}
+	}
+	if err := recorder.Store.Append(entry); err != nil {
+		Log.WithError(err).WithFields(logrus.Fields{
+			"file":                     recorder.Store.File,
+			"action":                   entry.Action,
+			logging.FieldTeamspeakDbId: entry.ClientDbId,
+		}).Errorln("could not append to audit log")
+	}
+}
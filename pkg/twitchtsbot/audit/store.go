@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store appends the entries to a file which contains a single JSON object per line. Existing entries are never changed
+// or removed.
+type Store struct {
+	*sync.Mutex
+	File string
+}
+
+func NewStore(file string) *Store {
+	return &Store{Mutex: &sync.Mutex{}, File: file}
+}
+
+// Append writes the entry to the end of the file. The file is created if it does not exist yet.
+func (store *Store) Append(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	store.Lock()
+	defer store.Unlock()
+	file, err := os.OpenFile(store.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Filter selects entries of the audit log. Empty fields match all entries.
+type Filter struct {
+	Since      time.Time
+	Until      time.Time
+	Target     string
+	ClientDbId int
+	// Login is compared case-insensitively.
+	Login   string
+	Action  string
+	Trigger string
+	Outcome string
+	// Limit is the maximum amount of entries, of which the newest ones are kept. All entries are returned if it is zero.
+	Limit int
+}
+
+// Matches returns whether the entry is selected by the filter.
+func (filter *Filter) Matches(entry *Entry) bool {
+	return (filter.Since.IsZero() || !entry.Time.Before(filter.Since)) &&
+		(filter.Until.IsZero() || entry.Time.Before(filter.Until)) &&
+		(filter.Target == "" || entry.Target == filter.Target) &&
+		(filter.ClientDbId == 0 || entry.ClientDbId == filter.ClientDbId) &&
+		(filter.Login == "" || strings.EqualFold(entry.Login, filter.Login)) &&
+		(filter.Action == "" || entry.Action == filter.Action) &&
+		(filter.Trigger == "" || entry.Trigger == filter.Trigger) &&
+		(filter.Outcome == "" || entry.Outcome == filter.Outcome)
+}
+
+// Query returns the entries selected by the filter from the oldest to the newest one. Lines which cannot be decoded,
+// such as one which has only been written partially before a crash, are skipped.
+func (store *Store) Query(filter Filter) ([]Entry, error) {
+	store.Lock()
+	defer store.Unlock()
+	entries := make([]Entry, 0)
+	file, err := os.Open(store.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			Log.WithError(err).WithField("file", store.File).WithField("line", line).
+				Warnln("skipping invalid audit log entry")
+			continue
+		}
+		if !filter.Matches(&entry) {
+			continue
+		}
+		entries = append(entries, entry)
+		if filter.Limit > 0 && len(entries) > filter.Limit {
+			entries = entries[1:]
+		}
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *Store {
+	dir, err := ioutil.TempDir("", "twitchtsbot")
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	return NewStore(filepath.Join(dir, "audit.jsonl"))
+}
+
+func TestStore(t *testing.T) {
+	store := newTestStore(t)
+	entries, err := store.Query(Filter{})
+	assert.NoError(t, err)
+	assert.Empty(t, entries, "expected a missing file to not contain entries")
+
+	now := time.Now()
+	assert.NoError(t, store.Append(Entry{Time: now.Add(-2 * time.Hour), Target: "default", ClientDbId: 1,
+		Login: "streamer", Action: ActionServerGroupAdded, Trigger: TriggerTransition, Outcome: OutcomeFailure}))
+	assert.NoError(t, store.Append(Entry{Time: now.Add(-time.Hour), Target: "default", ClientDbId: 1,
+		Login: "streamer", Action: ActionServerGroupAdded, Trigger: TriggerTransition, Outcome: OutcomeSuccess}))
+	// a partially written line is skipped
+	file, err := os.OpenFile(store.File, os.O_WRONLY|os.O_APPEND, 0600)
+	assert.NoError(t, err)
+	_, err = file.WriteString("{\"time\":\n")
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+	assert.NoError(t, store.Append(Entry{Time: now, Target: "staff", ClientDbId: 2, Login: "other",
+		Action: ActionDescriptionChanged, Trigger: TriggerEnterView, Outcome: OutcomeSuccess}))
+
+	entries, err = store.Query(Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	entries, err = store.Query(Filter{Login: "STREAMER"})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2, "expected the login to be compared case-insensitively")
+	entries, err = store.Query(Filter{ClientDbId: 1, Outcome: OutcomeFailure})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	entries, err = store.Query(Filter{Since: now.Add(-90 * time.Minute)})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	entries, err = store.Query(Filter{Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "staff", entries[0].Target, "expected the limit to keep the newest entries")
+	entries, err = store.Query(Filter{Target: "staff", Action: ActionServerGroupAdded})
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestRecorder(t *testing.T) {
+	store := newTestStore(t)
+	recorder := &Recorder{Store: store, Target: "default"}
+	ctx := WithCause(context.Background(), Cause{Trigger: TriggerAdmin, Platform: "twitch", Login: "streamer"})
+	recorder.Record(ctx, Entry{ClientDbId: 1, Action: ActionServerGroupAdded, ServerGroupId: 42}, nil)
+	recorder.Record(ctx, Entry{ClientDbId: 1, Action: ActionServerGroupAdded, ServerGroupId: 42},
+		errors.New("connection refused"))
+	recorder.Record(context.Background(), Entry{ClientDbId: 1, Action: ActionServerGroupRemoved, ServerGroupId: 42,
+		Outcome: OutcomeSkipped, Reason: "not granted"}, nil)
+	var disabled *Recorder
+	disabled.Record(ctx, Entry{ClientDbId: 1, Action: ActionServerGroupAdded}, nil)
+
+	entries, err := store.Query(Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	for i := range entries {
+		assert.False(t, entries[i].Time.IsZero(), "expected the time to be set")
+		entries[i].Time = time.Time{}
+	}
+	assert.Equal(t, []Entry{
+		{Target: "default", ClientDbId: 1, Platform: "twitch", Login: "streamer", Action: ActionServerGroupAdded,
+			ServerGroupId: 42, Trigger: TriggerAdmin, Outcome: OutcomeSuccess},
+		{Target: "default", ClientDbId: 1, Platform: "twitch", Login: "streamer", Action: ActionServerGroupAdded,
+			ServerGroupId: 42, Trigger: TriggerAdmin, Outcome: OutcomeFailure, Reason: "connection refused"},
+		{Target: "default", ClientDbId: 1, Action: ActionServerGroupRemoved, ServerGroupId: 42,
+			Outcome: OutcomeSkipped, Reason: "not granted"},
+	}, entries)
+}
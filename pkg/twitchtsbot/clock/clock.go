@@ -0,0 +1,63 @@
+package clock
+
+import "time"
+
+// Clock provides the current time and channels which fire after a duration. It allows the time based behaviour of
+// the bot to be tested without waiting for real time to pass.
+type Clock interface {
+	Now() time.Time
+	// After waits for the duration to elapse and then sends the current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is the Clock counterpart of time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker is the Clock counterpart of time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real is the Clock which is backed by the time package.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return &realTimer{Timer: time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{Ticker: time.NewTicker(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (timer *realTimer) C() <-chan time.Time {
+	return timer.Timer.C
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (ticker *realTicker) C() <-chan time.Time {
+	return ticker.Ticker.C
+}
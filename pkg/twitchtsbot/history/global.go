@@ -0,0 +1,5 @@
+package history
+
+import "github.com/sirupsen/logrus"
+
+var Log = logrus.StandardLogger()
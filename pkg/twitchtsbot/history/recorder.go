@@ -0,0 +1,84 @@
+package history
+
+import (
+	"context"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/logging"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// Recorder records the transitions of all monitors into a Store and samples the metadata of live streams.
+type Recorder struct {
+	Store *Store
+	// platform identifier: monitor
+	Monitors       map[string]*twitch.Monitor
+	NotifyChan     chan *twitch.UserState
+	SampleInterval time.Duration
+	Ctx            context.Context
+}
+
+func NewRecorder(store *Store, monitors map[string]*twitch.Monitor, notifyChan chan *twitch.UserState,
+	sampleInterval time.Duration, ctx context.Context) *Recorder {
+	return &Recorder{
+		Store:          store,
+		Monitors:       monitors,
+		NotifyChan:     notifyChan,
+		SampleInterval: sampleInterval,
+		Ctx:            ctx,
+	}
+}
+
+// Start records the transitions and samples the live streams every SampleInterval until the context is done. Samples
+// are not taken if the interval is not positive.
+func (recorder *Recorder) Start() {
+	go func() {
+		for {
+			select {
+			case <-recorder.Ctx.Done():
+				return
+			case state, ok := <-recorder.NotifyChan:
+				if !ok {
+					return
+				}
+				recorder.record(state, recorder.Store.Record)
+			}
+		}
+	}()
+	if recorder.SampleInterval <= 0 {
+		return
+	}
+	// the samples are taken separately as the monitors are locked while they notify the transitions
+	go func() {
+		ticker := time.NewTicker(recorder.SampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-recorder.Ctx.Done():
+				return
+			case <-ticker.C:
+				recorder.sample()
+			}
+		}
+	}()
+}
+
+// sample updates the metadata of all live sessions as the monitors only notify about transitions.
+func (recorder *Recorder) sample() {
+	for _, monitor := range recorder.Monitors {
+		for _, state := range monitor.GetStates() {
+			if state.StreamerStatus == twitch.StreamerStatusLive {
+				recorder.record(state, recorder.Store.Sample)
+			}
+		}
+	}
+}
+
+func (recorder *Recorder) record(state *twitch.UserState, apply func(state *twitch.UserState) error) {
+	if err := apply(state); err != nil {
+		Log.WithError(err).WithFields(logrus.Fields{
+			"platform":               state.Platform,
+			logging.FieldTwitchLogin: state.UserLogin,
+		}).Warnln("could not record stream history")
+	}
+}
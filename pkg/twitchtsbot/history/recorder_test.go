@@ -0,0 +1,71 @@
+package history
+
+import (
+	"context"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type staticProvider struct{}
+
+func (provider staticProvider) Platform() string {
+	return twitch.PlatformTwitch
+}
+
+func (provider staticProvider) LiveStreams(_ []string) ([]twitch.Stream, error) {
+	return nil, nil
+}
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twitchtsbot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store, err := NewStore(filepath.Join(dir, "history.json"), 0)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	monitor := twitch.NewProviderMonitor(staticProvider{}, []string{"streamer"}, time.Second, ctx, nil)
+	live := &twitch.UserState{Platform: twitch.PlatformTwitch, UserLogin: "streamer",
+		StreamerStatus: twitch.StreamerStatusLive, Since: time.Now(), Stream: &twitch.Stream{Title: "first"}}
+	monitor.States = map[string]*twitch.UserState{"streamer": live}
+	notifyChan := make(chan *twitch.UserState)
+	NewRecorder(store, map[string]*twitch.Monitor{twitch.PlatformTwitch: monitor}, notifyChan, time.Millisecond,
+		ctx).Start()
+
+	// the monitor is locked while it notifies, which must not keep the recorder from receiving the transition
+	monitor.Lock()
+	transition := *live
+	select {
+	case notifyChan <- &transition:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the recorder to receive transitions while the monitor is locked")
+	}
+	live.Stream = &twitch.Stream{Title: "second"}
+	monitor.Unlock()
+	assert.Eventually(t, func() bool {
+		sessions := store.Sessions()
+		return len(sessions) == 1 && sessions[0].Title == "second"
+	}, 5*time.Second, time.Millisecond, "expected the metadata of the live stream to be sampled")
+
+	// samples do not start sessions
+	monitor.Lock()
+	live.StreamerStatus = twitch.StreamerStatusOffline
+	transition = *live
+	monitor.Unlock()
+	notifyChan <- &transition
+	assert.Eventually(t, func() bool {
+		sessions := store.Sessions()
+		return len(sessions) == 1 && sessions[0].End != nil
+	}, 5*time.Second, time.Millisecond, "expected the offline transition to end the session")
+	assert.NoError(t, store.Sample(&twitch.UserState{Platform: twitch.PlatformTwitch, UserLogin: "streamer",
+		StreamerStatus: twitch.StreamerStatusLive, Stream: &twitch.Stream{Title: "stale"}}))
+	sessions := store.Sessions()
+	if assert.Len(t, sessions, 1, "expected a sample to not start a session") {
+		assert.Equal(t, "second", sessions[0].Title)
+	}
+}
@@ -0,0 +1,107 @@
+package history
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	week = 7 * 24 * time.Hour
+	// topGamesAmount is the amount of games which are part of the statistics of a channel.
+	topGamesAmount = 3
+)
+
+// Stats contains the aggregated stream statistics of a single channel.
+type Stats struct {
+	Platform       string   `json:"platform"`
+	UserLogin      string   `json:"userLogin"`
+	Streams        int      `json:"streams"`
+	TotalHours     float64  `json:"totalHours"`
+	StreamsPerWeek float64  `json:"streamsPerWeek"`
+	PeakViewers    int      `json:"peakViewers"`
+	TopGames       []string `json:"topGames"`
+	Live           bool     `json:"live"`
+}
+
+// Stats aggregates all sessions which have not ended before since. The statistics are ordered by the total hours
+// streamed. If since is zero, all stored sessions are taken into account.
+func (store *Store) Stats(since, now time.Time) []*Stats {
+	return Aggregate(store.Sessions(), since, now)
+}
+
+// Aggregate computes the statistics of each channel from the given sessions.
+func Aggregate(sessions []*Session, since, now time.Time) []*Stats {
+	start := since
+	// channel: stats
+	statsMap := make(map[string]*Stats)
+	// channel: game: amount of streams
+	games := make(map[string]map[string]int)
+	for _, session := range sessions {
+		if session.End != nil && session.End.Before(since) {
+			continue
+		}
+		if since.IsZero() && (start.IsZero() || session.Start.Before(start)) {
+			start = session.Start
+		}
+		key := session.Channel().String()
+		stats, ok := statsMap[key]
+		if !ok {
+			stats = &Stats{Platform: session.Platform, UserLogin: session.UserLogin, TopGames: []string{}}
+			statsMap[key] = stats
+			games[key] = make(map[string]int)
+		}
+		sessionStart := session.Start
+		if sessionStart.Before(since) {
+			sessionStart = since
+		}
+		end := now
+		if session.End != nil {
+			end = *session.End
+		} else {
+			stats.Live = true
+		}
+		stats.Streams++
+		stats.TotalHours += end.Sub(sessionStart).Hours()
+		if session.PeakViewers > stats.PeakViewers {
+			stats.PeakViewers = session.PeakViewers
+		}
+		for _, game := range session.Games {
+			games[key][game]++
+		}
+	}
+	weeks := float64(now.Sub(start)) / float64(week)
+	if weeks < 1 {
+		weeks = 1
+	}
+	result := make([]*Stats, 0, len(statsMap))
+	for key, stats := range statsMap {
+		stats.StreamsPerWeek = float64(stats.Streams) / weeks
+		stats.TopGames = topGames(games[key])
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TotalHours != result[j].TotalHours {
+			return result[i].TotalHours > result[j].TotalHours
+		}
+		return result[i].UserLogin < result[j].UserLogin
+	})
+	return result
+}
+
+// topGames returns the games which have been played in the most streams.
+func topGames(streams map[string]int) []string {
+	games := make([]string, 0, len(streams))
+	for game := range streams {
+		games = append(games, game)
+	}
+	sort.Slice(games, func(i, j int) bool {
+		if streams[games[i]] != streams[games[j]] {
+			return streams[games[i]] > streams[games[j]]
+		}
+		return games[i] < games[j]
+	})
+	if len(games) > topGamesAmount {
+		games = games[:topGamesAmount]
+	}
+	return games
+}
@@ -0,0 +1,42 @@
+package history
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAggregate(t *testing.T) {
+	now := time.Date(2020, 1, 29, 0, 0, 0, 0, time.UTC)
+	end := func(start time.Time, duration time.Duration) *time.Time {
+		end := start.Add(duration)
+		return &end
+	}
+	first := now.Add(-4 * week)
+	sessions := []*Session{
+		{Platform: "twitch", UserLogin: "a", Start: first, End: end(first, 2*time.Hour), Games: []string{"Chess"},
+			PeakViewers: 3},
+		{Platform: "twitch", UserLogin: "a", Start: now.Add(-week), End: end(now.Add(-week), time.Hour),
+			Games: []string{"Go", "Chess"}, PeakViewers: 7},
+		{Platform: "twitch", UserLogin: "b", Start: now.Add(-30 * time.Minute), Games: []string{"Poker"}},
+	}
+	stats := Aggregate(sessions, time.Time{}, now)
+	assert.Equal(t, []*Stats{
+		{Platform: "twitch", UserLogin: "a", Streams: 2, TotalHours: 3, StreamsPerWeek: 0.5, PeakViewers: 7,
+			TopGames: []string{"Chess", "Go"}},
+		{Platform: "twitch", UserLogin: "b", Streams: 1, TotalHours: 0.5, StreamsPerWeek: 0.25,
+			TopGames: []string{"Poker"}, Live: true},
+	}, stats)
+	stats = Aggregate(sessions, now.Add(-2*week), now)
+	assert.Equal(t, []*Stats{
+		{Platform: "twitch", UserLogin: "a", Streams: 1, TotalHours: 1, StreamsPerWeek: 0.5, PeakViewers: 7,
+			TopGames: []string{"Chess", "Go"}},
+		{Platform: "twitch", UserLogin: "b", Streams: 1, TotalHours: 0.5, StreamsPerWeek: 0.5,
+			TopGames: []string{"Poker"}, Live: true},
+	}, stats)
+}
+
+func TestTopGames(t *testing.T) {
+	assert.Equal(t, []string{"c", "a", "b"}, topGames(map[string]int{"a": 2, "b": 2, "c": 5, "d": 1}))
+	assert.Equal(t, []string{}, topGames(map[string]int{}))
+}
@@ -0,0 +1,187 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// Session is a single stream of a channel from its online to its offline transition.
+type Session struct {
+	Platform  string    `json:"platform"`
+	UserLogin string    `json:"userLogin"`
+	Start     time.Time `json:"start"`
+	// End is nil as long as the stream is live.
+	End   *time.Time `json:"end,omitempty"`
+	Title string     `json:"title"`
+	// Games contains the distinct games of the stream in the order they have been played.
+	Games       []string `json:"games"`
+	PeakViewers int      `json:"peakViewers"`
+}
+
+func (session *Session) Channel() twitch.Channel {
+	return twitch.Channel{Platform: session.Platform, Login: session.UserLogin}
+}
+
+func (session *Session) copy() *Session {
+	sessionCopy := *session
+	if session.End != nil {
+		end := *session.End
+		sessionCopy.End = &end
+	}
+	sessionCopy.Games = append([]string(nil), session.Games...)
+	return &sessionCopy
+}
+
+// update applies the metadata of the live stream and returns whether the session has changed.
+func (session *Session) update(stream *twitch.Stream) bool {
+	if stream == nil {
+		return false
+	}
+	changed := false
+	if stream.Title != session.Title {
+		session.Title = stream.Title
+		changed = true
+	}
+	if stream.ViewerCount > session.PeakViewers {
+		session.PeakViewers = stream.ViewerCount
+		changed = true
+	}
+	game := stream.GameName
+	if game == "" {
+		game = stream.GameID
+	}
+	if game == "" {
+		return changed
+	}
+	for _, playedGame := range session.Games {
+		if playedGame == game {
+			return changed
+		}
+	}
+	session.Games = append(session.Games, game)
+	return true
+}
+
+// Store keeps the stream sessions of all channels in a JSON file. Ended sessions which are older than the retention
+// are removed.
+type Store struct {
+	*sync.Mutex
+	File string
+	// Retention is the duration ended sessions are kept. Sessions are kept forever if it is zero.
+	Retention time.Duration
+	sessions  []*Session
+}
+
+// NewStore creates a store and loads the sessions from the file if it exists.
+func NewStore(file string, retention time.Duration) (*Store, error) {
+	store := &Store{
+		Mutex:     &sync.Mutex{},
+		File:      file,
+		Retention: retention,
+	}
+	data, err := ioutil.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.sessions); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Record applies the state of a channel. An online transition starts a new session, an offline transition ends the
+// live session and the metadata of live sessions is kept up to date.
+func (store *Store) Record(state *twitch.UserState) error {
+	store.Lock()
+	defer store.Unlock()
+	changed := false
+	session := store.liveSession(state.Channel())
+	since := state.Since
+	if since.IsZero() {
+		since = time.Now()
+	}
+	if state.StreamerStatus == twitch.StreamerStatusLive {
+		if session == nil {
+			session = &Session{Platform: state.Platform, UserLogin: state.UserLogin, Start: since, Games: []string{}}
+			store.sessions = append(store.sessions, session)
+			changed = true
+		}
+		changed = session.update(state.Stream) || changed
+	} else if session != nil {
+		session.End = &since
+		changed = true
+	}
+	changed = store.prune(time.Now()) || changed
+	if !changed {
+		return nil
+	}
+	return store.persist()
+}
+
+// Sample updates the metadata of the live session of the channel. In contrast to Record, it neither starts nor ends
+// sessions, so a sample which has been taken before a concurrent transition can not reopen a session.
+func (store *Store) Sample(state *twitch.UserState) error {
+	store.Lock()
+	defer store.Unlock()
+	session := store.liveSession(state.Channel())
+	if session == nil || state.StreamerStatus != twitch.StreamerStatusLive || !session.update(state.Stream) {
+		return nil
+	}
+	return store.persist()
+}
+
+// Sessions returns all stored sessions ordered by their start.
+func (store *Store) Sessions() []*Session {
+	store.Lock()
+	defer store.Unlock()
+	sessions := make([]*Session, 0, len(store.sessions))
+	for _, session := range store.sessions {
+		sessions = append(sessions, session.copy())
+	}
+	return sessions
+}
+
+func (store *Store) liveSession(channel twitch.Channel) *Session {
+	for i := len(store.sessions) - 1; i >= 0; i-- {
+		if session := store.sessions[i]; session.End == nil && session.Channel() == channel {
+			return session
+		}
+	}
+	return nil
+}
+
+// prune removes all sessions which ended before the retention and returns whether any session has been removed.
+func (store *Store) prune(now time.Time) bool {
+	if store.Retention <= 0 {
+		return false
+	}
+	threshold := now.Add(-store.Retention)
+	sessions := store.sessions[:0]
+	for _, session := range store.sessions {
+		if session.End == nil || session.End.After(threshold) {
+			sessions = append(sessions, session)
+		}
+	}
+	pruned := len(sessions) != len(store.sessions)
+	store.sessions = sessions
+	return pruned
+}
+
+func (store *Store) persist() error {
+	data, err := json.Marshal(store.sessions)
+	if err != nil {
+		return err
+	}
+	tempFile := store.File + ".tmp"
+	if err := ioutil.WriteFile(tempFile, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, store.File)
+}
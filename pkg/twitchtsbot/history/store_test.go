@@ -0,0 +1,66 @@
+package history
+
+import (
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_Record(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twitchtsbot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "history.json")
+	store, err := NewStore(file, 0)
+	assert.NoError(t, err)
+	start := time.Date(2020, 1, 1, 20, 0, 0, 0, time.UTC)
+	assert.NoError(t, store.Record(&twitch.UserState{Platform: twitch.PlatformTwitch, UserLogin: "streamer",
+		StreamerStatus: twitch.StreamerStatusOffline, Since: start.Add(-time.Hour)}))
+	assert.Empty(t, store.Sessions(), "expected offline state without live session to be ignored")
+	assert.NoError(t, store.Record(&twitch.UserState{Platform: twitch.PlatformTwitch, UserLogin: "streamer",
+		StreamerStatus: twitch.StreamerStatusLive, Since: start,
+		Stream: &twitch.Stream{Title: "first", GameName: "Chess", ViewerCount: 10}}))
+	assert.NoError(t, store.Record(&twitch.UserState{Platform: twitch.PlatformTwitch, UserLogin: "streamer",
+		StreamerStatus: twitch.StreamerStatusLive, Since: start,
+		Stream: &twitch.Stream{Title: "second", GameName: "Go", ViewerCount: 5}}))
+	assert.NoError(t, store.Record(&twitch.UserState{Platform: twitch.PlatformTwitch, UserLogin: "streamer",
+		StreamerStatus: twitch.StreamerStatusOffline, Since: start.Add(2 * time.Hour)}))
+	end := start.Add(2 * time.Hour)
+	expected := []*Session{{Platform: twitch.PlatformTwitch, UserLogin: "streamer", Start: start, End: &end,
+		Title: "second", Games: []string{"Chess", "Go"}, PeakViewers: 10}}
+	assert.Equal(t, expected, store.Sessions())
+	loaded, err := NewStore(file, 0)
+	assert.NoError(t, err)
+	sessions := loaded.Sessions()
+	if assert.Len(t, sessions, 1, "expected sessions to be persisted") {
+		assert.True(t, sessions[0].End.Equal(end))
+		assert.Equal(t, 10, sessions[0].PeakViewers)
+	}
+}
+
+func TestStore_Retention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twitchtsbot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store, err := NewStore(filepath.Join(dir, "history.json"), 24*time.Hour)
+	assert.NoError(t, err)
+	now := time.Now()
+	oldEnd := now.Add(-48 * time.Hour)
+	recentEnd := now.Add(-time.Hour)
+	store.sessions = []*Session{
+		{UserLogin: "old", Start: oldEnd.Add(-time.Hour), End: &oldEnd},
+		{UserLogin: "recent", Start: recentEnd.Add(-time.Hour), End: &recentEnd},
+		{UserLogin: "live", Start: now.Add(-72 * time.Hour)},
+	}
+	assert.True(t, store.prune(now))
+	sessions := store.Sessions()
+	if assert.Len(t, sessions, 2, "expected only the old session to be removed") {
+		assert.Equal(t, "recent", sessions[0].UserLogin)
+		assert.Equal(t, "live", sessions[1].UserLogin)
+	}
+	assert.False(t, store.prune(now))
+}
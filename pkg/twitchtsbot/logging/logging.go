@@ -0,0 +1,52 @@
+// Package logging configures the log output of the bot. It provides the formatters, a rotating log file, the
+// redaction of secrets and the field names which are shared by all packages.
+package logging
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+)
+
+// Field names which identify the same entity across the log calls of all packages.
+const (
+	FieldTwitchLogin   = "twitch_login"
+	FieldTeamspeakDbId = "ts_dbid"
+	FieldServerGroupId = "server_group_id"
+	// FieldCorrelationId is the id of the trace which links a stream change to the TeamSpeak changes caused by it.
+	FieldCorrelationId = "correlation_id"
+)
+
+// Log formats which can be passed to NewFormatter.
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+// NewFormatter returns the formatter of the given log format. The text format is colored if the output is a terminal,
+// while logfmt is never colored and always contains the full timestamp.
+func NewFormatter(format string) (logrus.Formatter, error) {
+	switch format {
+	case FormatText, "":
+		return &logrus.TextFormatter{}, nil
+	case FormatJSON:
+		return &logrus.JSONFormatter{}, nil
+	case FormatLogfmt:
+		return &logrus.TextFormatter{DisableColors: true, FullTimestamp: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+// NewPackageLogger returns a logger which shares the output, formatter and hooks with the parent but has its own
+// level. It is used for the Log variables of the packages so that their levels can be set separately.
+func NewPackageLogger(parent *logrus.Logger, level logrus.Level) *logrus.Logger {
+	return &logrus.Logger{
+		Out:          parent.Out,
+		Hooks:        parent.Hooks,
+		Formatter:    parent.Formatter,
+		ReportCaller: parent.ReportCaller,
+		Level:        level,
+		ExitFunc:     parent.ExitFunc,
+	}
+}
@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestLogger(t *testing.T, format string) (*logrus.Logger, *bytes.Buffer) {
+	formatter, err := NewFormatter(format)
+	assert.NoError(t, err)
+	output := &bytes.Buffer{}
+	logger := logrus.New()
+	logger.Out = output
+	logger.Formatter = formatter
+	return logger, output
+}
+
+func TestNewFormatter(t *testing.T) {
+	logger, output := newTestLogger(t, FormatJSON)
+	logger.WithField(FieldTwitchLogin, "streamer").Infoln("stream started")
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &entry))
+	assert.Equal(t, "streamer", entry[FieldTwitchLogin])
+	assert.Equal(t, "stream started", entry["msg"])
+
+	logger, output = newTestLogger(t, FormatLogfmt)
+	logger.WithField(FieldServerGroupId, 42).Infoln("server group added")
+	assert.Contains(t, output.String(), `msg="server group added" server_group_id=42`)
+
+	_, err := NewFormatter("xml")
+	assert.Error(t, err)
+}
+
+func TestNewPackageLogger(t *testing.T) {
+	parent, output := newTestLogger(t, FormatLogfmt)
+	parent.AddHook(&RedactHook{Secrets: func() []string {
+		return []string{"secret-token"}
+	}})
+	logger := NewPackageLogger(parent, logrus.DebugLevel)
+	logger.Debugln("using secret-token")
+	parent.Debugln("not logged")
+	assert.Contains(t, output.String(), "using [REDACTED]", "expected the hooks of the parent to be used")
+	assert.NotContains(t, output.String(), "not logged", "expected the level of the parent to be independent")
+}
+
+func TestRedactHook(t *testing.T) {
+	logger, output := newTestLogger(t, FormatJSON)
+	logger.AddHook(&RedactHook{Secrets: func() []string {
+		return []string{"abcdef123456", "", "1"}
+	}})
+	fields := logrus.Fields{"appAccessToken": "other", "url": "https://example.org/?key=abcdef123456", "serverid": "1"}
+	entry := logger.WithFields(fields)
+	entry.WithError(errors.New("invalid token abcdef123456")).Errorln("token abcdef123456 is invalid")
+	var logged map[string]interface{}
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &logged))
+	assert.NotContains(t, output.String(), "abcdef123456")
+	assert.Equal(t, Redacted, logged["appAccessToken"])
+	assert.Equal(t, "https://example.org/?key=[REDACTED]", logged["url"])
+	assert.Equal(t, "1", logged["serverid"], "expected short values to not be redacted")
+	assert.Equal(t, "invalid token [REDACTED]", logged[logrus.ErrorKey])
+	assert.Equal(t, "token [REDACTED] is invalid", logged["msg"])
+	assert.Equal(t, "other", entry.Data["appAccessToken"], "expected the fields of the entry to not be changed")
+
+	assert.True(t, IsSensitive("twitch.appaccesstoken"))
+	assert.True(t, IsSensitive("teamspeak.apikey"))
+	assert.False(t, IsSensitive("teamspeak.apikey_file"))
+	assert.False(t, IsSensitive("secretrefreshinterval"))
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twitchtsbot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "twitchtsbot.log")
+	file, err := OpenRotatingFile(path, 10, 2)
+	assert.NoError(t, err)
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := file.Write([]byte(line))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, file.Close())
+	for path, expected := range map[string]string{path: "fourth\n", path + ".1": "third\n", path + ".2": "second\n"} {
+		data, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(data))
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err), "expected only the configured amount of backups to be kept")
+
+	// an existing file is appended to
+	file, err = OpenRotatingFile(path, 100, 2)
+	assert.NoError(t, err)
+	_, err = file.Write([]byte("fifth\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "fourth\nfifth\n", string(data))
+}
@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"strings"
+)
+
+// Redacted replaces secrets in the log output.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeyParts are the parts of config keys and log fields whose values are secrets.
+var sensitiveKeyParts = []string{"token", "password", "apikey"}
+
+// IsSensitive returns whether the config key or log field contains a secret. References to secret files such as
+// "apikey_file" are not sensitive.
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	if strings.HasSuffix(key, "_file") {
+		return false
+	}
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactHook removes secrets from all log entries before they are written or passed to the hooks added after it.
+// Fields whose name is sensitive are redacted completely, while the secret values are replaced wherever they appear in
+// the message, string fields or errors.
+type RedactHook struct {
+	// Secrets returns the current secret values. It is called for every entry, so rotated secrets are redacted as well.
+	Secrets func() []string
+}
+
+func (hook *RedactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (hook *RedactHook) Fire(entry *logrus.Entry) error {
+	secrets := hook.secrets()
+	entry.Message = redact(entry.Message, secrets)
+	// the data may be shared with other entries, so it is replaced instead of being changed in place
+	data := make(logrus.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		switch typedValue := value.(type) {
+		case string:
+			value = redact(typedValue, secrets)
+		case error:
+			if message := redact(typedValue.Error(), secrets); message != typedValue.Error() {
+				value = errors.New(message)
+			}
+		}
+		if IsSensitive(key) {
+			value = Redacted
+		}
+		data[key] = value
+	}
+	entry.Data = data
+	return nil
+}
+
+func (hook *RedactHook) secrets() []string {
+	if hook.Secrets == nil {
+		return nil
+	}
+	secrets := make([]string, 0)
+	for _, secret := range hook.Secrets() {
+		// short values such as a server id would redact unrelated parts of the output
+		if len(secret) >= 4 {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+func redact(value string, secrets []string) string {
+	for _, secret := range secrets {
+		value = strings.ReplaceAll(value, secret, Redacted)
+	}
+	return value
+}
@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is a log file which is rotated once it exceeds its maximum size. The rotated files are named like the
+// file with the suffix ".1" for the newest up to ".<MaxBackups>" for the oldest one. Older files are removed.
+type RotatingFile struct {
+	*sync.Mutex
+	Path string
+	// MaxSize is the size in bytes above which the file is rotated. The file is never rotated if it is zero.
+	MaxSize    int64
+	MaxBackups int
+	file       *os.File
+	size       int64
+}
+
+// OpenRotatingFile opens the log file at the given path and appends to it if it exists already.
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	file := &RotatingFile{
+		Mutex:      &sync.Mutex{},
+		Path:       path,
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+	}
+	if err := file.open(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (file *RotatingFile) Write(p []byte) (int, error) {
+	file.Lock()
+	defer file.Unlock()
+	if file.MaxSize > 0 && file.size > 0 && file.size+int64(len(p)) > file.MaxSize {
+		if err := file.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := file.file.Write(p)
+	file.size += int64(n)
+	return n, err
+}
+
+func (file *RotatingFile) Close() error {
+	file.Lock()
+	defer file.Unlock()
+	return file.file.Close()
+}
+
+// rotate renames the current file to the newest backup and opens a new one. The file has to be locked.
+func (file *RotatingFile) rotate() error {
+	if err := file.file.Close(); err != nil {
+		return err
+	}
+	if file.MaxBackups <= 0 {
+		if err := os.Remove(file.Path); err != nil {
+			return err
+		}
+		return file.open()
+	}
+	for i := file.MaxBackups - 1; i >= 1; i-- {
+		err := os.Rename(file.backupPath(i), file.backupPath(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(file.Path, file.backupPath(1)); err != nil {
+		return err
+	}
+	return file.open()
+}
+
+func (file *RotatingFile) open() error {
+	opened, err := os.OpenFile(file.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := opened.Stat()
+	if err != nil {
+		_ = opened.Close()
+		return err
+	}
+	file.file = opened
+	file.size = info.Size()
+	return nil
+}
+
+func (file *RotatingFile) backupPath(index int) string {
+	return fmt.Sprintf("%s.%d", file.Path, index)
+}
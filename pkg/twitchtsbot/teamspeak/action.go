@@ -0,0 +1,342 @@
+package teamspeak
+
+import (
+	"context"
+	ts3 "github.com/jkoenig134/go-ts3"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/audit"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"strings"
+	"sync"
+)
+
+// iconPermission is the client permission which sets the icon shown next to the client name.
+const iconPermission = "i_icon_id"
+
+// PresenceEvent is the kind of change a presence action is performed for.
+type PresenceEvent int
+
+const (
+	// PresenceLive is emitted when the stream of the channel has started.
+	PresenceLive PresenceEvent = iota
+	// PresenceOffline is emitted when the stream of the channel has ended.
+	PresenceOffline
+	// PresenceMetadata is emitted when the title or game of a running stream has changed.
+	PresenceMetadata
+	// PresenceEnterView is emitted when the client connects to the TeamSpeak server.
+	PresenceEnterView
+	// PresenceSync is emitted when the current state is reconciled without a change, e.g. on startup or by the sync
+	// command.
+	PresenceSync
+)
+
+func (event PresenceEvent) String() string {
+	switch event {
+	case PresenceLive:
+		return "live"
+	case PresenceOffline:
+		return "offline"
+	case PresenceMetadata:
+		return "metadata"
+	case PresenceEnterView:
+		return "enterview"
+	default:
+		return "sync"
+	}
+}
+
+// Presence describes a change of the stream state of the channel of a mapped TeamSpeak client.
+type Presence struct {
+	Event      PresenceEvent
+	ClientDbId int
+	// ClientId is the id of the connection which has entered the server and is only set for PresenceEnterView.
+	ClientId int
+	// ServerGroups are the server groups of the client which has entered the server and are only set for
+	// PresenceEnterView.
+	ServerGroups []int
+	State        *twitch.UserState
+	// Context carries the span of the presence change. The TeamSpeak operations of the actions are traced as its
+	// children.
+	Context context.Context
+}
+
+// Live returns whether the stream of the channel is currently live.
+func (presence *Presence) Live() bool {
+	return presence.State.StreamerStatus == twitch.StreamerStatusLive
+}
+
+// context returns the context of the presence change or the background context if it is not set.
+func (presence *Presence) context() context.Context {
+	if presence.Context == nil {
+		return context.Background()
+	}
+	return presence.Context
+}
+
+// client returns the given client whose operations are traced as part of the presence change.
+func (presence *Presence) client(client Client) Client {
+	return NewTracingClient(presence.context(), client)
+}
+
+// Action highlights live streamers on TeamSpeak. It is performed for every presence change of a mapped client and
+// decides on its own which events it reacts to.
+type Action interface {
+	// Name returns the type of the action as it is used in the config.
+	Name() string
+	Perform(presence *Presence) error
+}
+
+// formatTitle replaces %s in the format by the title of the stream.
+func formatTitle(format string, state *twitch.UserState) string {
+	var title string
+	if state.Stream != nil {
+		title = state.Stream.Title
+	}
+	return strings.ReplaceAll(format, "%s", title)
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// connections returns all voice connections of the client. For PresenceEnterView, only the connection which has
+// entered the server is returned.
+func connections(client Client, presence *Presence) ([]ts3.Client, error) {
+	clients, err := client.ClientList()
+	if err != nil {
+		return nil, err
+	}
+	connections := make([]ts3.Client, 0)
+	for _, connection := range *clients {
+		if connection.ClientDatabaseId != presence.ClientDbId || connection.IsBot() {
+			continue
+		}
+		if presence.Event == PresenceEnterView && connection.ClientId != presence.ClientId {
+			continue
+		}
+		connections = append(connections, connection)
+	}
+	return connections, nil
+}
+
+// ServerGroupAction adds live streamers to a server group and removes them once the stream has ended. The changes are
+// applied by the queue, which retries failed ones.
+type ServerGroupAction struct {
+	Queue         *Queue
+	ServerGroupId int
+}
+
+func NewServerGroupAction(queue *Queue, serverGroupId int) *ServerGroupAction {
+	return &ServerGroupAction{Queue: queue, ServerGroupId: serverGroupId}
+}
+
+func (action *ServerGroupAction) Name() string {
+	return "servergroup"
+}
+
+func (action *ServerGroupAction) Perform(presence *Presence) error {
+	if presence.Event == PresenceMetadata {
+		return nil
+	} else if presence.Event == PresenceEnterView {
+		// the server group may have been changed by others since the members have been listed
+		action.Queue.Members.Observe(action.ServerGroupId, presence.ClientDbId,
+			containsInt(presence.ServerGroups, action.ServerGroupId))
+	}
+	action.Queue.SetServerGroupContext(presence.context(), action.ServerGroupId, presence.ClientDbId, presence.Live())
+	return nil
+}
+
+// ChannelGroupAction assigns a channel group in a channel to live streamers and resets it to the default channel group
+// once the stream has ended.
+type ChannelGroupAction struct {
+	Client                Client
+	ChannelId             int
+	ChannelGroupId        int
+	DefaultChannelGroupId int
+	// Audit records the channel group changes and may be nil.
+	Audit *audit.Recorder
+}
+
+func (action *ChannelGroupAction) Name() string {
+	return "channelgroup"
+}
+
+func (action *ChannelGroupAction) Perform(presence *Presence) error {
+	if presence.Event == PresenceMetadata {
+		return nil
+	}
+	channelGroupId := action.DefaultChannelGroupId
+	if presence.Live() {
+		channelGroupId = action.ChannelGroupId
+	}
+	err := presence.client(action.Client).SetClientChannelGroup(channelGroupId, action.ChannelId, presence.ClientDbId)
+	action.Audit.Record(presence.context(), audit.Entry{
+		ClientDbId:     presence.ClientDbId,
+		Action:         audit.ActionChannelGroupChanged,
+		ChannelId:      action.ChannelId,
+		ChannelGroupId: channelGroupId,
+	}, err)
+	return err
+}
+
+// IconAction shows an icon next to the name of live streamers by granting them the icon permission.
+type IconAction struct {
+	Client Client
+	IconId int
+}
+
+func (action *IconAction) Name() string {
+	return "icon"
+}
+
+func (action *IconAction) Perform(presence *Presence) error {
+	if presence.Event == PresenceMetadata {
+		return nil
+	}
+	client := presence.client(action.Client)
+	if presence.Live() {
+		return client.ClientAddStringPermission(presence.ClientDbId, iconPermission, action.IconId, false)
+	}
+	return client.ClientDeletePermission(ts3.ClientDeletePermission{
+		ClientDbId: presence.ClientDbId,
+		PermsId:    []string{iconPermission},
+	})
+}
+
+// MoveAction moves live streamers into a channel when their stream starts or when they connect while being live. Once
+// the stream has ended, they are moved back to the channel they came from unless they have left the channel on their
+// own in the meantime.
+type MoveAction struct {
+	*sync.Mutex
+	Client    Client
+	ChannelId int
+	// client id: channel the connection has been moved from
+	previous map[int]previousChannel
+}
+
+type previousChannel struct {
+	ClientDbId int
+	ChannelId  int
+}
+
+func NewMoveAction(client Client, channelId int) *MoveAction {
+	return &MoveAction{
+		Mutex:     &sync.Mutex{},
+		Client:    client,
+		ChannelId: channelId,
+		previous:  make(map[int]previousChannel),
+	}
+}
+
+func (action *MoveAction) Name() string {
+	return "move"
+}
+
+func (action *MoveAction) Perform(presence *Presence) error {
+	if presence.Live() && (presence.Event == PresenceLive || presence.Event == PresenceEnterView) {
+		return action.moveIn(presence)
+	} else if presence.Event == PresenceOffline {
+		return action.moveBack(presence)
+	}
+	return nil
+}
+
+func (action *MoveAction) moveIn(presence *Presence) error {
+	client := presence.client(action.Client)
+	connections, err := connections(client, presence)
+	if err != nil {
+		return err
+	}
+	action.Lock()
+	defer action.Unlock()
+	clientIds := make([]int, 0, len(connections))
+	for _, connection := range connections {
+		if connection.ChannelId != action.ChannelId {
+			clientIds = append(clientIds, connection.ClientId)
+		}
+	}
+	if len(clientIds) == 0 {
+		return nil
+	}
+	if err := client.ClientMove(ts3.ClientMoveRequest{ClientId: clientIds, ChannelId: action.ChannelId}); err != nil {
+		return err
+	}
+	for _, connection := range connections {
+		if connection.ChannelId != action.ChannelId {
+			action.previous[connection.ClientId] = previousChannel{ClientDbId: presence.ClientDbId,
+				ChannelId: connection.ChannelId}
+		}
+	}
+	return nil
+}
+
+func (action *MoveAction) moveBack(presence *Presence) error {
+	client := presence.client(action.Client)
+	connections, err := connections(client, presence)
+	if err != nil {
+		return err
+	}
+	action.Lock()
+	defer action.Unlock()
+	var moveErr error
+	for _, connection := range connections {
+		previous, ok := action.previous[connection.ClientId]
+		// connections which have left the channel on their own are not moved back
+		if !ok || connection.ChannelId != action.ChannelId {
+			continue
+		}
+		err := client.ClientMove(ts3.ClientMoveRequest{ClientId: []int{connection.ClientId},
+			ChannelId: previous.ChannelId})
+		if err != nil && moveErr == nil {
+			moveErr = err
+		}
+	}
+	// the moves are not retried and the ids of closed connections may be reused
+	for clientId, previous := range action.previous {
+		if previous.ClientDbId == presence.ClientDbId {
+			delete(action.previous, clientId)
+		}
+	}
+	return moveErr
+}
+
+// MessageAction sends a private text message to the streamer when the stream starts or ends. In both messages, %s is
+// replaced by the stream title. An empty message is not sent.
+type MessageAction struct {
+	Client  Client
+	Live    string
+	Offline string
+}
+
+func (action *MessageAction) Name() string {
+	return "message"
+}
+
+func (action *MessageAction) Perform(presence *Presence) error {
+	var format string
+	switch presence.Event {
+	case PresenceLive:
+		format = action.Live
+	case PresenceOffline:
+		format = action.Offline
+	}
+	if format == "" {
+		return nil
+	}
+	client := presence.client(action.Client)
+	connections, err := connections(client, presence)
+	if err != nil {
+		return err
+	}
+	message := formatTitle(format, presence.State)
+	for _, connection := range connections {
+		if err := client.SendClientMessage(connection.ClientId, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
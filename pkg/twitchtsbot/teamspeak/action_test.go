@@ -0,0 +1,98 @@
+package teamspeak
+
+import (
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/testutil/ts3test"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTwitchUpdateHook_Actions(t *testing.T) {
+	server, client := newTestServer(t)
+	clientDbId := server.AddClient("uid=", "streamer")
+	streamingChannel := server.AddChannel()
+	assert.True(t, server.Connect(clientDbId))
+	queue, err := NewQueue(client, "")
+	assert.NoError(t, err)
+	hook := NewHook(queue, nil, nil, nil, map[int]twitch.Channel{clientDbId: testChannel}, 0)
+	hook.Actions = []Action{
+		&ChannelGroupAction{Client: client, ChannelId: streamingChannel, ChannelGroupId: 5, DefaultChannelGroupId: 8},
+		&IconAction{Client: client, IconId: 1234},
+		NewMoveAction(client, streamingChannel),
+		&MessageAction{Client: client, Live: "You are live: %s", Offline: "Your stream has ended."},
+	}
+	liveState := &twitch.UserState{Platform: testChannel.Platform, UserLogin: testChannel.Login,
+		StreamerStatus: twitch.StreamerStatusLive, Stream: &twitch.Stream{Title: "title"}}
+	offlineState := &twitch.UserState{Platform: testChannel.Platform, UserLogin: testChannel.Login,
+		StreamerStatus: twitch.StreamerStatusOffline}
+
+	hook.handleState(PresenceLive, liveState)
+	assert.Equal(t, 5, server.ChannelGroup(streamingChannel, clientDbId))
+	iconId, ok := server.Permission(clientDbId, iconPermission)
+	assert.True(t, ok, "expected the icon permission to be granted")
+	assert.Equal(t, 1234, iconId)
+	assert.Equal(t, streamingChannel, server.Channel(clientDbId), "expected the streamer to be moved")
+	assert.Equal(t, "You are live: title", server.Messages()[0].Text)
+
+	// metadata changes and reconciles neither move the streamer again nor send messages
+	server.SetChannel(clientDbId, ts3test.DefaultChannelID)
+	hook.handleState(PresenceMetadata, liveState)
+	hook.Reconcile([]*twitch.UserState{liveState})
+	assert.Equal(t, ts3test.DefaultChannelID, server.Channel(clientDbId))
+	assert.Len(t, server.Messages(), 1)
+
+	hook.handleState(PresenceOffline, offlineState)
+	assert.Equal(t, 8, server.ChannelGroup(streamingChannel, clientDbId))
+	_, ok = server.Permission(clientDbId, iconPermission)
+	assert.False(t, ok, "expected the icon permission to be removed")
+	assert.Equal(t, "Your stream has ended.", server.Messages()[1].Text)
+	assert.Equal(t, ts3test.DefaultChannelID, server.Channel(clientDbId))
+}
+
+func TestMoveAction(t *testing.T) {
+	server, client := newTestServer(t)
+	clientDbId := server.AddClient("uid=", "streamer")
+	previousChannel := server.AddChannel()
+	streamingChannel := server.AddChannel()
+	otherChannel := server.AddChannel()
+	assert.True(t, server.Connect(clientDbId))
+	server.SetChannel(clientDbId, previousChannel)
+	action := NewMoveAction(client, streamingChannel)
+	live := &Presence{Event: PresenceLive, ClientDbId: clientDbId, State: &twitch.UserState{
+		StreamerStatus: twitch.StreamerStatusLive}}
+	offline := &Presence{Event: PresenceOffline, ClientDbId: clientDbId, State: &twitch.UserState{
+		StreamerStatus: twitch.StreamerStatusOffline}}
+
+	assert.NoError(t, action.Perform(live))
+	assert.Equal(t, streamingChannel, server.Channel(clientDbId))
+	assert.NoError(t, action.Perform(offline))
+	assert.Equal(t, previousChannel, server.Channel(clientDbId), "expected the streamer to be moved back")
+
+	// streamers who have left the streaming channel on their own stay where they are
+	assert.NoError(t, action.Perform(live))
+	server.SetChannel(clientDbId, otherChannel)
+	assert.NoError(t, action.Perform(offline))
+	assert.Equal(t, otherChannel, server.Channel(clientDbId))
+
+	// streamers who already are in the streaming channel are not moved back
+	server.SetChannel(clientDbId, streamingChannel)
+	assert.NoError(t, action.Perform(live))
+	assert.NoError(t, action.Perform(offline))
+	assert.Equal(t, streamingChannel, server.Channel(clientDbId))
+}
+
+func TestTwitchUpdateHook_ActionFailure(t *testing.T) {
+	server, client := newTestServer(t)
+	clientDbId := server.AddClient("uid=", "streamer")
+	queue, err := NewQueue(client, "")
+	assert.NoError(t, err)
+	hook := NewHook(queue, nil, nil, nil, map[int]twitch.Channel{clientDbId: testChannel}, 0)
+	hook.Actions = []Action{
+		&ChannelGroupAction{Client: client, ChannelId: 999, ChannelGroupId: 5, DefaultChannelGroupId: 8},
+		&IconAction{Client: client, IconId: 1234},
+	}
+	hook.handleState(PresenceLive, &twitch.UserState{Platform: testChannel.Platform, UserLogin: testChannel.Login,
+		StreamerStatus: twitch.StreamerStatusLive})
+	_, ok := server.Permission(clientDbId, iconPermission)
+	assert.True(t, ok, "expected a failed action to not prevent the following ones")
+}
@@ -0,0 +1,20 @@
+package teamspeak
+
+import ts3 "github.com/jkoenig134/go-ts3"
+
+// Client contains the TeamSpeak WebQuery operations used by the hooks. It is implemented by *WebQueryClient.
+type Client interface {
+	SubscribeEvent(event ts3.TeamspeakEvent, fn interface{}) error
+	ServerGroupClientList(serverGroupId int) (*[]ts3.ServerGroupClientList, error)
+	ServerGroupAddClient(serverGroupId, clientDbId int) error
+	ServerGroupDeleteClient(serverGroupId, clientDbId int) error
+	ClientList() (*[]ts3.Client, error)
+	ClientDbInfo(clientDbId int) (*ts3.ClientDbInfo, error)
+	ClientEditDescription(clientId int, description string) error
+	ClientDbEditDescription(clientDbId int, description string) error
+	ClientMove(request ts3.ClientMoveRequest) error
+	SetClientChannelGroup(channelGroupId, channelId, clientDbId int) error
+	ClientAddStringPermission(clientDbId int, permissionName string, permissionValue int, permSkip bool) error
+	ClientDeletePermission(request ts3.ClientDeletePermission) error
+	SendClientMessage(clientId int, message string) error
+}
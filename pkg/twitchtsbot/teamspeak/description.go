@@ -0,0 +1,329 @@
+package teamspeak
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/audit"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/logging"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDescriptionFormat is the description of live streamers in which %s is replaced by the stream title.
+	DefaultDescriptionFormat = "🔴 LIVE: %s"
+	// maxDescriptionLength is the maximum amount of characters of a TeamSpeak client description.
+	maxDescriptionLength = 200
+)
+
+// DescriptionTagger sets the TeamSpeak client description of live streamers and restores the original description
+// once the stream has ended. The original descriptions are persisted in the state file if it is set, so that they can
+// still be restored after a crash. Failed changes are retried with an exponential backoff between BaseDelay and
+// MaxDelay once the tagger has been started, unless a newer presence replaces them.
+type DescriptionTagger struct {
+	*sync.Mutex
+	Client    Client
+	Format    string
+	StateFile string
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Audit records the description changes and may be nil.
+	Audit *audit.Recorder
+	// client database id: original description
+	originals map[int]string
+	// client database id: failed change which is retried
+	retries map[int]*descriptionRetry
+	// stopped is set by RestoreAll so that the descriptions are not tagged again while the bot shuts down
+	stopped bool
+}
+
+// descriptionRetry is the latest failed description change of a client.
+type descriptionRetry struct {
+	// state is the state of the live stream whose description is set, or nil if the original is restored
+	state       *twitch.UserState
+	cause       audit.Cause
+	attempts    int
+	nextAttempt time.Time
+}
+
+// NewDescriptionTagger creates a tagger for the client and loads the original descriptions from the state file if it
+// is set.
+func NewDescriptionTagger(client Client, stateFile string) (*DescriptionTagger, error) {
+	tagger := &DescriptionTagger{
+		Mutex:     &sync.Mutex{},
+		Client:    client,
+		Format:    DefaultDescriptionFormat,
+		StateFile: stateFile,
+		BaseDelay: DefaultQueueBaseDelay,
+		MaxDelay:  DefaultQueueMaxDelay,
+		originals: make(map[int]string),
+		retries:   make(map[int]*descriptionRetry),
+	}
+	if err := tagger.load(); err != nil {
+		return nil, err
+	}
+	return tagger, nil
+}
+
+// Tag sets the live description of the stream to all connections of the client. The original description is recorded
+// before the first change.
+func (tagger *DescriptionTagger) Tag(clientDbId int, state *twitch.UserState) error {
+	return tagger.tag(context.Background(), clientDbId, state)
+}
+
+func (tagger *DescriptionTagger) tag(ctx context.Context, clientDbId int, state *twitch.UserState) error {
+	tagger.Lock()
+	defer tagger.Unlock()
+	if tagger.stopped {
+		return nil
+	}
+	client := NewTracingClient(ctx, tagger.Client)
+	entry := audit.Entry{ClientDbId: clientDbId, Action: audit.ActionDescriptionChanged}
+	if _, ok := tagger.originals[clientDbId]; !ok {
+		info, err := client.ClientDbInfo(clientDbId)
+		if err != nil {
+			tagger.Audit.Record(ctx, entry, err)
+			return err
+		}
+		tagger.originals[clientDbId] = info.ClientDescription
+		tagger.persist()
+	}
+	description := []rune(formatTitle(tagger.Format, state))
+	if len(description) > maxDescriptionLength {
+		description = description[:maxDescriptionLength]
+	}
+	err := tagger.editConnections(client, clientDbId, string(description))
+	tagger.Audit.Record(ctx, entry, err)
+	return err
+}
+
+func (tagger *DescriptionTagger) Name() string {
+	return "description"
+}
+
+// Perform tags the description while the stream is live, which includes changes of its title, and restores it
+// otherwise. A failed change is retried in the background.
+func (tagger *DescriptionTagger) Perform(presence *Presence) error {
+	var state *twitch.UserState
+	if presence.Live() {
+		state = presence.State
+	}
+	err := tagger.apply(presence.context(), presence.ClientDbId, state)
+	tagger.setRetry(presence.context(), presence.ClientDbId, state, err)
+	return err
+}
+
+func (tagger *DescriptionTagger) apply(ctx context.Context, clientDbId int, state *twitch.UserState) error {
+	if state != nil {
+		return tagger.tag(ctx, clientDbId, state)
+	}
+	return tagger.restore(ctx, clientDbId)
+}
+
+// setRetry records the change as the one to retry if it has failed and discards any previous one of the client.
+func (tagger *DescriptionTagger) setRetry(ctx context.Context, clientDbId int, state *twitch.UserState, err error) {
+	tagger.Lock()
+	defer tagger.Unlock()
+	if err == nil {
+		delete(tagger.retries, clientDbId)
+		return
+	}
+	retry := &descriptionRetry{state: state, cause: audit.CauseFromContext(ctx)}
+	if previous, ok := tagger.retries[clientDbId]; ok {
+		retry.attempts = previous.attempts
+	}
+	retry.attempts++
+	retry.nextAttempt = time.Now().Add(backoff(tagger.BaseDelay, tagger.MaxDelay, retry.attempts))
+	tagger.retries[clientDbId] = retry
+}
+
+// Start retries the failed description changes until the context is done.
+func (tagger *DescriptionTagger) Start(ctx context.Context) {
+	interval := tagger.retryInterval()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				tagger.retry()
+			}
+		}
+	}()
+}
+
+// Drain retries the failed description changes until all of them have been applied or the context is done. It is used
+// by one-off runs which do not start the tagger.
+func (tagger *DescriptionTagger) Drain(ctx context.Context) error {
+	for {
+		tagger.retry()
+		tagger.Lock()
+		empty := len(tagger.retries) == 0
+		tagger.Unlock()
+		if empty {
+			return nil
+		}
+		timer := time.NewTimer(tagger.retryInterval())
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+func (tagger *DescriptionTagger) retryInterval() time.Duration {
+	if tagger.BaseDelay <= 0 {
+		return DefaultQueueBaseDelay
+	}
+	return tagger.BaseDelay
+}
+
+// retry applies all failed changes which are due again.
+func (tagger *DescriptionTagger) retry() {
+	now := time.Now()
+	tagger.Lock()
+	due := make(map[int]*descriptionRetry)
+	for clientDbId, retry := range tagger.retries {
+		if !retry.nextAttempt.After(now) {
+			due[clientDbId] = retry
+		}
+	}
+	tagger.Unlock()
+	for clientDbId, retry := range due {
+		ctx := audit.WithCause(context.Background(), retry.cause)
+		err := tagger.apply(ctx, clientDbId, retry.state)
+		tagger.Lock()
+		// a newer presence replaces the retry and is retried separately
+		if tagger.retries[clientDbId] == retry {
+			if err == nil {
+				delete(tagger.retries, clientDbId)
+			} else {
+				retry.attempts++
+				retry.nextAttempt = time.Now().Add(backoff(tagger.BaseDelay, tagger.MaxDelay, retry.attempts))
+				Log.WithFields(logrus.Fields{
+					logging.FieldTeamspeakDbId: clientDbId,
+					"attempts":                 retry.attempts,
+				}).WithError(err).Warnln("could not change client description, retrying later")
+			}
+		}
+		tagger.Unlock()
+	}
+}
+
+// Restore sets the original description of the client if it has been tagged before.
+func (tagger *DescriptionTagger) Restore(clientDbId int) error {
+	return tagger.restore(context.Background(), clientDbId)
+}
+
+func (tagger *DescriptionTagger) restore(ctx context.Context, clientDbId int) error {
+	tagger.Lock()
+	defer tagger.Unlock()
+	original, ok := tagger.originals[clientDbId]
+	if !ok {
+		return nil
+	}
+	client := NewTracingClient(ctx, tagger.Client)
+	err := tagger.editConnections(client, clientDbId, original)
+	if err == nil {
+		// the description of the connections is only stored once they disconnect
+		err = client.ClientDbEditDescription(clientDbId, original)
+	}
+	tagger.Audit.Record(ctx, audit.Entry{ClientDbId: clientDbId, Action: audit.ActionDescriptionRestored}, err)
+	if err != nil {
+		return err
+	}
+	delete(tagger.originals, clientDbId)
+	tagger.persist()
+	return nil
+}
+
+// RestoreAll restores the original descriptions of all tagged clients. Afterwards, Tag does not change any description.
+func (tagger *DescriptionTagger) RestoreAll() {
+	tagger.Lock()
+	tagger.stopped = true
+	tagger.Unlock()
+	ctx := audit.WithCause(context.Background(), audit.Cause{Trigger: audit.TriggerShutdown})
+	for _, clientDbId := range tagger.Tagged() {
+		if err := tagger.restore(ctx, clientDbId); err != nil {
+			Log.WithError(err).WithField(logging.FieldTeamspeakDbId, clientDbId).
+				Warnln("could not restore client description")
+		}
+	}
+}
+
+// Tagged returns the database ids of all clients whose original description has not been restored yet.
+func (tagger *DescriptionTagger) Tagged() []int {
+	tagger.Lock()
+	defer tagger.Unlock()
+	clientDbIds := make([]int, 0, len(tagger.originals))
+	for clientDbId := range tagger.originals {
+		clientDbIds = append(clientDbIds, clientDbId)
+	}
+	return clientDbIds
+}
+
+// editConnections changes the description of all voice connections of the client. The tagger has to be locked.
+func (tagger *DescriptionTagger) editConnections(client Client, clientDbId int, description string) error {
+	connections, err := client.ClientList()
+	if err != nil {
+		return err
+	}
+	for _, connection := range *connections {
+		if connection.ClientDatabaseId != clientDbId || connection.IsBot() {
+			continue
+		}
+		if err := client.ClientEditDescription(connection.ClientId, description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// persist writes the original descriptions to the state file. The tagger has to be locked.
+func (tagger *DescriptionTagger) persist() {
+	if tagger.StateFile == "" {
+		return
+	}
+	data, err := json.Marshal(tagger.originals)
+	if err == nil {
+		tempFile := tagger.StateFile + ".tmp"
+		if err = ioutil.WriteFile(tempFile, data, 0600); err == nil {
+			err = os.Rename(tempFile, tagger.StateFile)
+		}
+	}
+	if err != nil {
+		Log.WithError(err).WithField("stateFile", tagger.StateFile).Errorln("could not persist original client descriptions")
+	}
+}
+
+func (tagger *DescriptionTagger) load() error {
+	if tagger.StateFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(tagger.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var originals map[int]string
+	if err := json.Unmarshal(data, &originals); err != nil {
+		return err
+	}
+	for clientDbId, description := range originals {
+		tagger.originals[clientDbId] = description
+	}
+	if len(originals) > 0 {
+		Log.WithField("taggedAmount", len(originals)).Infoln("loaded original client descriptions")
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package teamspeak
+
+import (
+	"context"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDescriptionTagger(t *testing.T) {
+	server, client := newTestServer(t)
+	clientDbId := server.AddClient("uid=", "streamer")
+	server.SetDescription(clientDbId, "original")
+	dir, err := ioutil.TempDir("", "teamspeak")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "description.json")
+	tagger, err := NewDescriptionTagger(client, stateFile)
+	assert.NoError(t, err)
+	liveState := &twitch.UserState{Platform: testChannel.Platform, UserLogin: testChannel.Login,
+		StreamerStatus: twitch.StreamerStatusLive, Stream: &twitch.Stream{Title: "title"}}
+
+	assert.NoError(t, tagger.Tag(clientDbId, liveState))
+	assert.Equal(t, "original", server.Description(clientDbId), "expected offline clients to not be tagged")
+	assert.True(t, server.Connect(clientDbId))
+	assert.NoError(t, tagger.Tag(clientDbId, liveState))
+	assert.Equal(t, "🔴 LIVE: title", server.Description(clientDbId))
+	liveState.Stream.Title = strings.Repeat("a", 300)
+	assert.NoError(t, tagger.Tag(clientDbId, liveState))
+	assert.Len(t, []rune(server.Description(clientDbId)), maxDescriptionLength, "expected long titles to be cut")
+
+	// the original description survives a restart of the bot
+	tagger, err = NewDescriptionTagger(client, stateFile)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{clientDbId}, tagger.Tagged())
+	assert.True(t, server.Disconnect(clientDbId))
+	tagger.RestoreAll()
+	assert.Equal(t, "original", server.Description(clientDbId), "expected the original description to be restored")
+	assert.Empty(t, tagger.Tagged())
+	assert.NoError(t, tagger.Restore(clientDbId), "expected restoring an untagged client to be a no-op")
+	assert.NoError(t, tagger.Tag(clientDbId, liveState))
+	assert.Empty(t, tagger.Tagged(), "expected no tags after all descriptions have been restored")
+}
+
+func TestDescriptionTagger_Drain(t *testing.T) {
+	server, client := newTestServer(t)
+	clientDbId := server.AddClient("uid=", "streamer")
+	server.SetDescription(clientDbId, "original")
+	assert.True(t, server.Connect(clientDbId))
+	tagger, err := NewDescriptionTagger(client, "")
+	assert.NoError(t, err)
+	tagger.BaseDelay = 10 * time.Millisecond
+	live := &Presence{Event: PresenceLive, ClientDbId: clientDbId, State: &twitch.UserState{
+		Platform: testChannel.Platform, UserLogin: testChannel.Login, StreamerStatus: twitch.StreamerStatusLive,
+		Stream: &twitch.Stream{Title: "title"}}}
+
+	server.FailNext("clientedit", 2)
+	assert.Error(t, tagger.Perform(live))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, tagger.Drain(ctx))
+	assert.Equal(t, "🔴 LIVE: title", server.Description(clientDbId), "expected the failed tag to be retried")
+
+	server.FailNext("clientedit", 1000)
+	assert.Error(t, tagger.Perform(&Presence{Event: PresenceOffline, ClientDbId: clientDbId, State: live.State}))
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, tagger.Drain(ctx), "expected the drain to stop once the context is done")
+}
+
+func TestTwitchUpdateHook_Description(t *testing.T) {
+	server, client := newTestServer(t)
+	clientDbId := server.AddClient("uid=", "streamer")
+	server.SetDescription(clientDbId, "original")
+	assert.True(t, server.Connect(clientDbId))
+	queue, err := NewQueue(client, "")
+	assert.NoError(t, err)
+	tagger, err := NewDescriptionTagger(client, "")
+	assert.NoError(t, err)
+	tagger.Format = "live: %s"
+	hook := NewHook(queue, nil, nil, nil, map[int]twitch.Channel{clientDbId: testChannel}, 0)
+	hook.Actions = []Action{tagger}
+
+	hook.Reconcile([]*twitch.UserState{{Platform: testChannel.Platform, UserLogin: testChannel.Login,
+		StreamerStatus: twitch.StreamerStatusLive, Stream: &twitch.Stream{Title: "title"}}})
+	assert.Equal(t, "live: title", server.Description(clientDbId))
+	assert.Empty(t, queue.Pending(), "expected no server group change while tagging descriptions")
+	hook.Reconcile([]*twitch.UserState{{Platform: testChannel.Platform, UserLogin: testChannel.Login,
+		StreamerStatus: twitch.StreamerStatusOffline}})
+	assert.Equal(t, "original", server.Description(clientDbId))
+}
+
+func TestDescriptionTagger_Retry(t *testing.T) {
+	server, client := newTestServer(t)
+	clientDbId := server.AddClient("uid=", "streamer")
+	server.SetDescription(clientDbId, "original")
+	assert.True(t, server.Connect(clientDbId))
+	tagger, err := NewDescriptionTagger(client, "")
+	assert.NoError(t, err)
+	tagger.BaseDelay = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tagger.Start(ctx)
+	live := &Presence{Event: PresenceLive, ClientDbId: clientDbId, State: &twitch.UserState{
+		Platform: testChannel.Platform, UserLogin: testChannel.Login, StreamerStatus: twitch.StreamerStatusLive,
+		Stream: &twitch.Stream{Title: "title"}}}
+
+	server.FailNext("clientedit", 2)
+	assert.Error(t, tagger.Perform(live))
+	assert.Eventually(t, func() bool {
+		return server.Description(clientDbId) == "🔴 LIVE: title"
+	}, 5*time.Second, 10*time.Millisecond, "expected the failed tag to be retried")
+
+	// a newer presence replaces the failed change
+	server.FailNext("clientedit", 1)
+	assert.Error(t, tagger.Perform(&Presence{Event: PresenceOffline, ClientDbId: clientDbId, State: live.State}))
+	live.State.Stream = &twitch.Stream{Title: "second"}
+	assert.NoError(t, tagger.Perform(live))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "🔴 LIVE: second", server.Description(clientDbId), "expected the outdated restore to be dropped")
+}
@@ -0,0 +1,143 @@
+package teamspeak
+
+import (
+	ts3 "github.com/jkoenig134/go-ts3"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/logging"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDryRunMutations is the amount of recorded mutations which are kept by the DryRunClient.
+const maxDryRunMutations = 1000
+
+const (
+	OperationServerGroupAddClient    = "servergroupaddclient"
+	OperationServerGroupDeleteClient = "servergroupdelclient"
+	OperationClientEdit              = "clientedit"
+	OperationClientDbEdit            = "clientdbedit"
+	OperationClientMove              = "clientmove"
+	OperationSetClientChannelGroup   = "setclientchannelgroup"
+	OperationClientAddPermission     = "clientaddperm"
+	OperationClientDeletePermission  = "clientdelperm"
+	OperationSendTextMessage         = "sendtextmessage"
+)
+
+// Mutation describes a single TeamSpeak change which has not been applied because of the dry run mode.
+type Mutation struct {
+	Time          time.Time `json:"time"`
+	Operation     string    `json:"operation"`
+	ServerGroupId int       `json:"serverGroupId"`
+	ClientDbId    int       `json:"clientDbId"`
+	// ClientId is the id of the connection whose properties would have been changed.
+	ClientId    int    `json:"clientId,omitempty"`
+	Description string `json:"description,omitempty"`
+	ChannelId   int    `json:"channelId,omitempty"`
+	// Value is the channel group id, the permission name and value or the text message of the mutation.
+	Value string `json:"value,omitempty"`
+}
+
+// DryRunClient wraps a Client and performs all reads while only logging and recording the mutations it would have
+// made.
+type DryRunClient struct {
+	Client
+	mutex     *sync.Mutex
+	mutations []Mutation
+}
+
+func NewDryRunClient(client Client) *DryRunClient {
+	return &DryRunClient{
+		Client: client,
+		mutex:  &sync.Mutex{},
+	}
+}
+
+func (client *DryRunClient) ServerGroupAddClient(serverGroupId, clientDbId int) error {
+	client.record(Mutation{Operation: OperationServerGroupAddClient, ServerGroupId: serverGroupId, ClientDbId: clientDbId})
+	return nil
+}
+
+func (client *DryRunClient) ServerGroupDeleteClient(serverGroupId, clientDbId int) error {
+	client.record(Mutation{Operation: OperationServerGroupDeleteClient, ServerGroupId: serverGroupId,
+		ClientDbId: clientDbId})
+	return nil
+}
+
+func (client *DryRunClient) ClientEditDescription(clientId int, description string) error {
+	client.record(Mutation{Operation: OperationClientEdit, ClientId: clientId, Description: description})
+	return nil
+}
+
+func (client *DryRunClient) ClientDbEditDescription(clientDbId int, description string) error {
+	client.record(Mutation{Operation: OperationClientDbEdit, ClientDbId: clientDbId, Description: description})
+	return nil
+}
+
+func (client *DryRunClient) ClientMove(request ts3.ClientMoveRequest) error {
+	for _, clientId := range request.ClientId {
+		client.record(Mutation{Operation: OperationClientMove, ClientId: clientId, ChannelId: request.ChannelId})
+	}
+	return nil
+}
+
+func (client *DryRunClient) SetClientChannelGroup(channelGroupId, channelId, clientDbId int) error {
+	client.record(Mutation{Operation: OperationSetClientChannelGroup, ClientDbId: clientDbId, ChannelId: channelId,
+		Value: strconv.Itoa(channelGroupId)})
+	return nil
+}
+
+func (client *DryRunClient) ClientAddStringPermission(clientDbId int, permissionName string, permissionValue int,
+	_ bool) error {
+	client.record(Mutation{Operation: OperationClientAddPermission, ClientDbId: clientDbId,
+		Value: permissionName + "=" + strconv.Itoa(permissionValue)})
+	return nil
+}
+
+func (client *DryRunClient) ClientDeletePermission(request ts3.ClientDeletePermission) error {
+	client.record(Mutation{Operation: OperationClientDeletePermission, ClientDbId: request.ClientDbId,
+		Value: strings.Join(request.PermsId, ",")})
+	return nil
+}
+
+func (client *DryRunClient) SendClientMessage(clientId int, message string) error {
+	client.record(Mutation{Operation: OperationSendTextMessage, ClientId: clientId, Value: message})
+	return nil
+}
+
+// Mutations returns the recorded mutations from the oldest to the newest one.
+func (client *DryRunClient) Mutations() []Mutation {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	mutations := make([]Mutation, len(client.mutations))
+	copy(mutations, client.mutations)
+	return mutations
+}
+
+func (client *DryRunClient) record(mutation Mutation) {
+	fields := logrus.Fields{"operation": mutation.Operation, logging.FieldTeamspeakDbId: mutation.ClientDbId}
+	if mutation.ServerGroupId != 0 {
+		fields[logging.FieldServerGroupId] = mutation.ServerGroupId
+	}
+	if mutation.ClientId != 0 {
+		fields["clientId"] = mutation.ClientId
+	}
+	if mutation.Operation == OperationClientEdit || mutation.Operation == OperationClientDbEdit {
+		fields["description"] = mutation.Description
+	}
+	if mutation.ChannelId != 0 {
+		fields["channelId"] = mutation.ChannelId
+	}
+	if mutation.Value != "" {
+		fields["value"] = mutation.Value
+	}
+	Log.WithFields(fields).Infoln("dry run: skipped teamspeak mutation")
+	mutation.Time = time.Now()
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.mutations = append(client.mutations, mutation)
+	if len(client.mutations) > maxDryRunMutations {
+		client.mutations = client.mutations[len(client.mutations)-maxDryRunMutations:]
+	}
+}
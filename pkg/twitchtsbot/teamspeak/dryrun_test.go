@@ -0,0 +1,107 @@
+package teamspeak
+
+import (
+	"context"
+	ts3 "github.com/jkoenig134/go-ts3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+type testClient struct {
+	mock.Mock
+}
+
+func (client *testClient) SubscribeEvent(event ts3.TeamspeakEvent, fn interface{}) error {
+	return client.Called(event, fn).Error(0)
+}
+
+func (client *testClient) ServerGroupClientList(serverGroupId int) (*[]ts3.ServerGroupClientList, error) {
+	args := client.Called(serverGroupId)
+	resp := args.Get(0)
+	err := args.Error(1)
+	if resp != nil {
+		return resp.(*[]ts3.ServerGroupClientList), err
+	}
+	return nil, err
+}
+
+func (client *testClient) ServerGroupAddClient(serverGroupId, clientDbId int) error {
+	return client.Called(serverGroupId, clientDbId).Error(0)
+}
+
+func (client *testClient) ServerGroupDeleteClient(serverGroupId, clientDbId int) error {
+	return client.Called(serverGroupId, clientDbId).Error(0)
+}
+
+func (client *testClient) ClientList() (*[]ts3.Client, error) {
+	args := client.Called()
+	resp := args.Get(0)
+	err := args.Error(1)
+	if resp != nil {
+		return resp.(*[]ts3.Client), err
+	}
+	return nil, err
+}
+
+func (client *testClient) ClientDbInfo(clientDbId int) (*ts3.ClientDbInfo, error) {
+	args := client.Called(clientDbId)
+	resp := args.Get(0)
+	err := args.Error(1)
+	if resp != nil {
+		return resp.(*ts3.ClientDbInfo), err
+	}
+	return nil, err
+}
+
+func (client *testClient) ClientEditDescription(clientId int, description string) error {
+	return client.Called(clientId, description).Error(0)
+}
+
+func (client *testClient) ClientDbEditDescription(clientDbId int, description string) error {
+	return client.Called(clientDbId, description).Error(0)
+}
+
+func (client *testClient) ClientMove(request ts3.ClientMoveRequest) error {
+	return client.Called(request).Error(0)
+}
+
+func (client *testClient) SetClientChannelGroup(channelGroupId, channelId, clientDbId int) error {
+	return client.Called(channelGroupId, channelId, clientDbId).Error(0)
+}
+
+func (client *testClient) ClientAddStringPermission(clientDbId int, permissionName string, permissionValue int,
+	permSkip bool) error {
+	return client.Called(clientDbId, permissionName, permissionValue, permSkip).Error(0)
+}
+
+func (client *testClient) ClientDeletePermission(request ts3.ClientDeletePermission) error {
+	return client.Called(request).Error(0)
+}
+
+func (client *testClient) SendClientMessage(clientId int, message string) error {
+	return client.Called(clientId, message).Error(0)
+}
+
+func TestDryRunClient(t *testing.T) {
+	mockClient := new(testClient)
+	mockClient.On("ServerGroupClientList", 42).Return(&[]ts3.ServerGroupClientList{{ClientDbId: 2}}, nil)
+	client := NewDryRunClient(mockClient)
+	members := NewMembershipCache(client)
+	members.Authoritative = true
+	assert.NoError(t, members.SetServerGroup(context.Background(), 42, 1, true))
+	assert.NoError(t, members.SetServerGroup(context.Background(), 42, 2, false))
+	mockClient.AssertNumberOfCalls(t, "ServerGroupClientList", 1)
+	mockClient.AssertNotCalled(t, "ServerGroupAddClient", 42, 1)
+	mockClient.AssertNotCalled(t, "ServerGroupDeleteClient", 42, 2)
+	mutations := client.Mutations()
+	assert.Len(t, mutations, 2, "expected dry run client to record both mutations")
+	assert.Equal(t, OperationServerGroupAddClient, mutations[0].Operation)
+	assert.Equal(t, 1, mutations[0].ClientDbId)
+	assert.Equal(t, OperationServerGroupDeleteClient, mutations[1].Operation)
+	assert.Equal(t, 2, mutations[1].ClientDbId)
+	assert.NoError(t, client.ClientEditDescription(7, "description"))
+	mockClient.AssertNotCalled(t, "ClientEditDescription", 7, "description")
+	assert.Equal(t, Mutation{Time: client.Mutations()[2].Time, Operation: OperationClientEdit, ClientId: 7,
+		Description: "description"}, client.Mutations()[2])
+}
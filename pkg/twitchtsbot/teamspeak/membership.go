@@ -0,0 +1,226 @@
+package teamspeak
+
+import (
+	"context"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/audit"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/logging"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+	"time"
+)
+
+const DefaultMembershipRefreshInterval = 5 * time.Minute
+
+// MembershipCache keeps the members of the server groups changed by the bot in memory. A server group is listed when
+// it is needed for the first time and then refreshed periodically, while the changes of the bot itself are applied to
+// the cache directly. This way, the amount of member lists requested from the TeamSpeak server does not depend on the
+// amount of account pairs. The cache is not locked while the TeamSpeak server is queried.
+//
+// Unless the cache is authoritative, only memberships which have been granted by the bot according to its ownership
+// record are removed.
+type MembershipCache struct {
+	*sync.Mutex
+	Client Client
+	// RefreshInterval is the interval in which the cached server groups are listed again. They are not refreshed if it
+	// is not positive.
+	RefreshInterval time.Duration
+	Ownership       *Ownership
+	Authoritative   bool
+	// Audit records the server group changes and may be nil.
+	Audit *audit.Recorder
+	// changes serializes the server group changes
+	changes *sync.Mutex
+	// server group id: set of client database ids
+	groups map[int]map[int]bool
+	// server group id: amount of changes of the cached members, which keeps a refresh from replacing the members with
+	// a list which has been requested before a change
+	revisions map[int]int
+}
+
+// NewMembershipCache creates a cache for the client whose ownership record is not persisted.
+func NewMembershipCache(client Client) *MembershipCache {
+	ownership, _ := NewOwnership("")
+	return &MembershipCache{
+		Mutex:           &sync.Mutex{},
+		Client:          client,
+		RefreshInterval: DefaultMembershipRefreshInterval,
+		Ownership:       ownership,
+		changes:         &sync.Mutex{},
+		groups:          make(map[int]map[int]bool),
+		revisions:       make(map[int]int),
+	}
+}
+
+// IsMember returns whether the client is a member of the server group. The members are only requested from the
+// TeamSpeak server if the server group is not cached yet.
+func (cache *MembershipCache) IsMember(serverGroupId, clientDbId int) (bool, error) {
+	if err := cache.cacheMembers(cache.Client, serverGroupId); err != nil {
+		return false, err
+	}
+	cache.Lock()
+	defer cache.Unlock()
+	return cache.groups[serverGroupId][clientDbId], nil
+}
+
+// SetServerGroup adds the client to or removes the client from the given server group if it is not already a member
+// or not a member anymore. The returned error is only set if the TeamSpeak server could not be queried or changed.
+// Changes are serialized, so the cache always reflects the result of the previous one. The TeamSpeak operations are
+// traced as part of the span in the given context and the change is audited with the cause of the context.
+func (cache *MembershipCache) SetServerGroup(ctx context.Context, serverGroupId, clientDbId int, add bool) error {
+	cache.changes.Lock()
+	defer cache.changes.Unlock()
+	client := NewTracingClient(ctx, cache.Client)
+	span := trace.SpanFromContext(ctx)
+	fields := withCorrelationId(ctx, logrus.Fields{
+		logging.FieldTeamspeakDbId: clientDbId,
+		logging.FieldServerGroupId: serverGroupId,
+	})
+	entry := audit.Entry{ClientDbId: clientDbId, Action: audit.ActionServerGroupAdded, ServerGroupId: serverGroupId}
+	if !add {
+		entry.Action = audit.ActionServerGroupRemoved
+	}
+	if err := cache.cacheMembers(client, serverGroupId); err != nil {
+		Log.WithError(err).WithFields(fields).Errorln("could not retrieve server group members")
+		cache.Audit.Record(ctx, entry, err)
+		return err
+	}
+	cache.Lock()
+	member := cache.groups[serverGroupId][clientDbId]
+	cache.Unlock()
+	if member == add {
+		span.AddEvent("membership unchanged")
+		if !add {
+			Log.WithFields(fields).Traceln("client does not have the server group which should be removed")
+			cache.Ownership.Revoke(serverGroupId, clientDbId)
+		}
+		return nil
+	}
+	if !add && !cache.Authoritative && !cache.Ownership.Owns(serverGroupId, clientDbId) {
+		Log.WithFields(fields).Debugln("server group has not been granted by the bot and is not removed")
+		span.AddEvent("server group not granted by the bot")
+		entry.Outcome, entry.Reason = audit.OutcomeSkipped, "server group has not been granted by the bot"
+		cache.Audit.Record(ctx, entry, nil)
+		return nil
+	}
+	var err error
+	if add {
+		err = client.ServerGroupAddClient(serverGroupId, clientDbId)
+		if err != nil {
+			Log.WithFields(fields).WithError(err).Warnln("could not add client to server group")
+		}
+	} else {
+		err = client.ServerGroupDeleteClient(serverGroupId, clientDbId)
+		if err != nil {
+			Log.WithFields(fields).WithError(err).Warnln("could not remove client from server group")
+		}
+	}
+	cache.Audit.Record(ctx, entry, err)
+	if err != nil {
+		// the members may have changed without the bot noticing, so they are listed again on the next attempt
+		cache.Lock()
+		delete(cache.groups, serverGroupId)
+		cache.Unlock()
+		return err
+	}
+	cache.Observe(serverGroupId, clientDbId, add)
+	if add {
+		cache.Ownership.Grant(serverGroupId, clientDbId)
+	} else {
+		cache.Ownership.Revoke(serverGroupId, clientDbId)
+	}
+	return nil
+}
+
+// Observe updates the cached membership of the client with a state which has been learned otherwise, e.g. from the
+// server groups of a connecting client. Server groups which are not cached yet are not changed.
+func (cache *MembershipCache) Observe(serverGroupId, clientDbId int, member bool) {
+	cache.Lock()
+	defer cache.Unlock()
+	members, ok := cache.groups[serverGroupId]
+	if !ok {
+		return
+	} else if member {
+		members[clientDbId] = true
+	} else {
+		delete(members, clientDbId)
+	}
+	cache.revisions[serverGroupId]++
+}
+
+// Refresh lists the members of all cached server groups again. Server groups which could not be listed are removed
+// from the cache and requested again once they are needed. Server groups whose members have changed while they were
+// listed keep their cached members until the next refresh.
+func (cache *MembershipCache) Refresh() {
+	cache.Lock()
+	revisions := make(map[int]int, len(cache.groups))
+	for serverGroupId := range cache.groups {
+		revisions[serverGroupId] = cache.revisions[serverGroupId]
+	}
+	cache.Unlock()
+	for serverGroupId, revision := range revisions {
+		members, err := cache.list(cache.Client, serverGroupId)
+		cache.Lock()
+		if err != nil {
+			Log.WithError(err).WithField(logging.FieldServerGroupId, serverGroupId).
+				Warnln("could not refresh server group members")
+			delete(cache.groups, serverGroupId)
+		} else if cache.revisions[serverGroupId] == revision {
+			cache.groups[serverGroupId] = members
+		}
+		cache.Unlock()
+	}
+}
+
+// Start refreshes the cache periodically in the background until the context is done. The cache is not refreshed if
+// the refresh interval is not positive.
+func (cache *MembershipCache) Start(ctx context.Context) {
+	if cache.RefreshInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(cache.RefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				cache.Refresh()
+			}
+		}
+	}()
+}
+
+// cacheMembers lists the members of the server group if they are not cached yet. The cache is not locked while they
+// are listed.
+func (cache *MembershipCache) cacheMembers(client Client, serverGroupId int) error {
+	cache.Lock()
+	_, ok := cache.groups[serverGroupId]
+	cache.Unlock()
+	if ok {
+		return nil
+	}
+	members, err := cache.list(client, serverGroupId)
+	if err != nil {
+		return err
+	}
+	cache.Lock()
+	defer cache.Unlock()
+	if _, ok := cache.groups[serverGroupId]; !ok {
+		cache.groups[serverGroupId] = members
+	}
+	return nil
+}
+
+func (cache *MembershipCache) list(client Client, serverGroupId int) (map[int]bool, error) {
+	list, err := client.ServerGroupClientList(serverGroupId)
+	if err != nil {
+		return nil, err
+	}
+	members := make(map[int]bool, len(*list))
+	for _, member := range *list {
+		members[member.ClientDbId] = true
+	}
+	return members, nil
+}
@@ -0,0 +1,150 @@
+package teamspeak
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMembershipCache(t *testing.T) {
+	server, client := newTestServer(t)
+	serverGroupId := server.AddServerGroup("Live")
+	clientDbIds := make([]int, 0, 10)
+	for i := 0; i < 10; i++ {
+		clientDbIds = append(clientDbIds, server.AddClient("uid=", "streamer"))
+	}
+	cache := NewMembershipCache(client)
+	for _, clientDbId := range clientDbIds {
+		assert.NoError(t, cache.SetServerGroup(context.Background(), serverGroupId, clientDbId, true))
+	}
+	assert.Equal(t, 1, server.Requests("servergroupclientlist"), "expected the members to be listed only once")
+	assert.Equal(t, 10, server.Requests("servergroupaddclient"))
+	member, err := cache.IsMember(serverGroupId, clientDbIds[0])
+	assert.NoError(t, err)
+	assert.True(t, member, "expected the own change to be applied to the cache")
+
+	// changes by others are noticed once the cache is refreshed
+	server.SetMember(serverGroupId, clientDbIds[0], false)
+	cache.Refresh()
+	assert.Equal(t, 2, server.Requests("servergroupclientlist"))
+	member, err = cache.IsMember(serverGroupId, clientDbIds[0])
+	assert.NoError(t, err)
+	assert.False(t, member)
+
+	// a failed change invalidates the cached members
+	server.FailNext("servergroupdelclient", 1)
+	assert.Error(t, cache.SetServerGroup(context.Background(), serverGroupId, clientDbIds[1], false))
+	assert.NoError(t, cache.SetServerGroup(context.Background(), serverGroupId, clientDbIds[1], false))
+	assert.Equal(t, 3, server.Requests("servergroupclientlist"))
+	assert.False(t, server.IsMember(serverGroupId, clientDbIds[1]))
+}
+
+func TestMembershipCache_Start(t *testing.T) {
+	server, client := newTestServer(t)
+	serverGroupId := server.AddServerGroup("Live")
+	clientDbId := server.AddClient("uid=", "streamer")
+	cache := NewMembershipCache(client)
+	cache.RefreshInterval = 10 * time.Millisecond
+	member, err := cache.IsMember(serverGroupId, clientDbId)
+	assert.NoError(t, err)
+	assert.False(t, member)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache.Start(ctx)
+	server.SetMember(serverGroupId, clientDbId, true)
+	assert.Eventually(t, func() bool {
+		member, err := cache.IsMember(serverGroupId, clientDbId)
+		return err == nil && member
+	}, time.Second, 10*time.Millisecond, "expected the cache to be refreshed periodically")
+}
+
+func TestMembershipCache_Ownership(t *testing.T) {
+	server, client := newTestServer(t)
+	serverGroupId := server.AddServerGroup("Live")
+	grantedDbId := server.AddClient("uid=", "streamer")
+	manualDbId := server.AddClient("uid2=", "moderator")
+	server.SetMember(serverGroupId, manualDbId, true)
+	dir, err := ioutil.TempDir("", "twitchtsbot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "ownership.json")
+
+	cache := NewMembershipCache(client)
+	cache.Ownership, err = NewOwnership(stateFile)
+	assert.NoError(t, err)
+	assert.NoError(t, cache.SetServerGroup(context.Background(), serverGroupId, grantedDbId, true))
+	assert.NoError(t, cache.SetServerGroup(context.Background(), serverGroupId, manualDbId, true))
+	assert.Equal(t, []Grant{{ServerGroupId: serverGroupId, ClientDbId: grantedDbId}}, cache.Ownership.Grants(),
+		"expected only the membership added by the bot to be owned")
+
+	// the grants are loaded again after a restart
+	cache = NewMembershipCache(client)
+	cache.Ownership, err = NewOwnership(stateFile)
+	assert.NoError(t, err)
+	assert.NoError(t, cache.SetServerGroup(context.Background(), serverGroupId, grantedDbId, false))
+	assert.NoError(t, cache.SetServerGroup(context.Background(), serverGroupId, manualDbId, false))
+	assert.False(t, server.IsMember(serverGroupId, grantedDbId), "expected the granted server group to be removed")
+	assert.True(t, server.IsMember(serverGroupId, manualDbId), "expected the manual server group to be kept")
+	assert.Empty(t, cache.Ownership.Grants())
+
+	cache.Authoritative = true
+	assert.NoError(t, cache.SetServerGroup(context.Background(), serverGroupId, manualDbId, false))
+	assert.False(t, server.IsMember(serverGroupId, manualDbId), "expected authoritative mode to remove any member")
+}
+
+func TestMembershipCache_StartWithoutInterval(t *testing.T) {
+	_, client := newTestServer(t)
+	cache := NewMembershipCache(client)
+	cache.RefreshInterval = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NotPanics(t, func() {
+		cache.Start(ctx)
+	}, "expected a cache without refresh interval to not be refreshed")
+}
+
+// blockingClient blocks the server group additions until the release channel is closed.
+type blockingClient struct {
+	Client
+	adding  chan bool
+	release chan bool
+}
+
+func (client *blockingClient) ServerGroupAddClient(serverGroupId, clientDbId int) error {
+	client.adding <- true
+	<-client.release
+	return client.Client.ServerGroupAddClient(serverGroupId, clientDbId)
+}
+
+func TestMembershipCache_NotLockedWhileChanging(t *testing.T) {
+	server, client := newTestServer(t)
+	serverGroupId := server.AddServerGroup("Live")
+	clientDbId := server.AddClient("uid=", "streamer")
+	blocking := &blockingClient{Client: client, adding: make(chan bool), release: make(chan bool)}
+	cache := NewMembershipCache(blocking)
+	done := make(chan error)
+	go func() {
+		done <- cache.SetServerGroup(context.Background(), serverGroupId, clientDbId, true)
+	}()
+	<-blocking.adding
+	looked := make(chan bool)
+	go func() {
+		_, _ = cache.IsMember(serverGroupId, clientDbId)
+		cache.Refresh()
+		close(looked)
+	}()
+	select {
+	case <-looked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the cache to not be locked while the server group is changed")
+	}
+	close(blocking.release)
+	assert.NoError(t, <-done)
+	member, err := cache.IsMember(serverGroupId, clientDbId)
+	assert.NoError(t, err)
+	assert.True(t, member, "expected the change to be applied to the cache")
+}
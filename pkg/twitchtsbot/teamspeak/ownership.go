@@ -0,0 +1,150 @@
+package teamspeak
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Grant is a server group membership which has been added by the bot.
+type Grant struct {
+	ServerGroupId int `json:"serverGroupId"`
+	ClientDbId    int `json:"clientDbId"`
+}
+
+// Ownership records the server group memberships granted by the bot, so that memberships which have been added by
+// others are not removed. If a state file is set, the grants are persisted so that they survive a restart.
+type Ownership struct {
+	*sync.Mutex
+	StateFile string
+	grants    map[Grant]bool
+	// fresh is set while the state file has not been written yet
+	fresh bool
+}
+
+// NewOwnership creates an ownership record and loads the grants from the state file if it is set.
+func NewOwnership(stateFile string) (*Ownership, error) {
+	ownership := &Ownership{
+		Mutex:     &sync.Mutex{},
+		StateFile: stateFile,
+		grants:    make(map[Grant]bool),
+	}
+	if err := ownership.load(); err != nil {
+		return nil, err
+	}
+	return ownership, nil
+}
+
+// Owns returns whether the membership of the client in the server group has been granted by the bot.
+func (ownership *Ownership) Owns(serverGroupId, clientDbId int) bool {
+	ownership.Lock()
+	defer ownership.Unlock()
+	return ownership.grants[Grant{ServerGroupId: serverGroupId, ClientDbId: clientDbId}]
+}
+
+// Grant records that the bot has added the client to the server group.
+func (ownership *Ownership) Grant(serverGroupId, clientDbId int) {
+	ownership.set(Grant{ServerGroupId: serverGroupId, ClientDbId: clientDbId}, true)
+}
+
+// Revoke records that the membership of the client in the server group does not exist anymore.
+func (ownership *Ownership) Revoke(serverGroupId, clientDbId int) {
+	ownership.set(Grant{ServerGroupId: serverGroupId, ClientDbId: clientDbId}, false)
+}
+
+// Fresh returns whether the grants are persisted but the state file has not been written yet, e.g. on the first start
+// after the ownership record has been introduced.
+func (ownership *Ownership) Fresh() bool {
+	ownership.Lock()
+	defer ownership.Unlock()
+	return ownership.fresh
+}
+
+// Adopt records the given existing memberships as granted by the bot, so that they are removed once the streams end.
+// The record is persisted even if no membership is adopted, so that Fresh returns false afterwards.
+func (ownership *Ownership) Adopt(grants []Grant) {
+	ownership.Lock()
+	defer ownership.Unlock()
+	for _, grant := range grants {
+		ownership.grants[grant] = true
+	}
+	ownership.persist()
+}
+
+// Grants returns all memberships granted by the bot ordered by server group and client.
+func (ownership *Ownership) Grants() []Grant {
+	ownership.Lock()
+	defer ownership.Unlock()
+	grants := make([]Grant, 0, len(ownership.grants))
+	for grant := range ownership.grants {
+		grants = append(grants, grant)
+	}
+	sort.Slice(grants, func(i, j int) bool {
+		if grants[i].ServerGroupId != grants[j].ServerGroupId {
+			return grants[i].ServerGroupId < grants[j].ServerGroupId
+		}
+		return grants[i].ClientDbId < grants[j].ClientDbId
+	})
+	return grants
+}
+
+func (ownership *Ownership) set(grant Grant, granted bool) {
+	ownership.Lock()
+	defer ownership.Unlock()
+	if ownership.grants[grant] == granted {
+		return
+	}
+	if granted {
+		ownership.grants[grant] = true
+	} else {
+		delete(ownership.grants, grant)
+	}
+	ownership.persist()
+}
+
+// persist writes the grants to the state file. The ownership has to be locked.
+func (ownership *Ownership) persist() {
+	if ownership.StateFile == "" {
+		return
+	}
+	grants := make([]Grant, 0, len(ownership.grants))
+	for grant := range ownership.grants {
+		grants = append(grants, grant)
+	}
+	data, err := json.Marshal(grants)
+	if err == nil {
+		tempFile := ownership.StateFile + ".tmp"
+		if err = ioutil.WriteFile(tempFile, data, 0600); err == nil {
+			err = os.Rename(tempFile, ownership.StateFile)
+		}
+	}
+	if err != nil {
+		Log.WithError(err).WithField("stateFile", ownership.StateFile).Errorln("could not persist granted server groups")
+		return
+	}
+	ownership.fresh = false
+}
+
+func (ownership *Ownership) load() error {
+	if ownership.StateFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(ownership.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		ownership.fresh = true
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var grants []Grant
+	if err := json.Unmarshal(data, &grants); err != nil {
+		return err
+	}
+	for _, grant := range grants {
+		ownership.grants[grant] = true
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package teamspeak
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOwnership_Adopt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teamspeak")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "ownership.json")
+	ownership, err := NewOwnership(stateFile)
+	assert.NoError(t, err)
+	assert.True(t, ownership.Fresh(), "expected a missing state file to be fresh")
+	ownership.Adopt([]Grant{{ServerGroupId: 1, ClientDbId: 2}})
+	assert.False(t, ownership.Fresh())
+	assert.True(t, ownership.Owns(1, 2), "expected adopted memberships to be owned")
+
+	ownership, err = NewOwnership(stateFile)
+	assert.NoError(t, err)
+	assert.False(t, ownership.Fresh(), "expected the adoption to be persisted")
+	assert.Equal(t, []Grant{{ServerGroupId: 1, ClientDbId: 2}}, ownership.Grants())
+
+	// an empty adoption is persisted as well
+	emptyFile := filepath.Join(dir, "empty.json")
+	ownership, err = NewOwnership(emptyFile)
+	assert.NoError(t, err)
+	ownership.Adopt(nil)
+	ownership, err = NewOwnership(emptyFile)
+	assert.NoError(t, err)
+	assert.False(t, ownership.Fresh())
+
+	ownership, err = NewOwnership("")
+	assert.NoError(t, err)
+	assert.False(t, ownership.Fresh(), "expected an in-memory record to never be fresh")
+}
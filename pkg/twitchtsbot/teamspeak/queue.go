@@ -0,0 +1,300 @@
+package teamspeak
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/audit"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/logging"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultQueueBaseDelay = time.Second
+	DefaultQueueMaxDelay  = 5 * time.Minute
+)
+
+type membership struct {
+	ServerGroupId int
+	ClientDbId    int
+}
+
+// PendingMutation is a desired server group membership which has not been applied to the TeamSpeak server yet.
+type PendingMutation struct {
+	ServerGroupId int       `json:"serverGroupId"`
+	ClientDbId    int       `json:"clientDbId"`
+	Add           bool      `json:"add"`
+	Attempts      int       `json:"attempts"`
+	NextAttempt   time.Time `json:"nextAttempt"`
+	// Cause is the reason of the change which is recorded in the audit log once the change is applied.
+	Cause audit.Cause `json:"cause"`
+	// version is increased every time the desired state changes so that an outdated result is not applied
+	version uint64
+	// spanContext is the span of the decision which has led to the change. It is not persisted.
+	spanContext trace.SpanContext
+}
+
+// Queue applies server group changes in the background and retries failed ones with an exponential backoff until they
+// succeed. Only the latest desired state per client and server group is kept. If a state file is set, the pending
+// changes are persisted so that they survive a restart. Whether a change is necessary is looked up in the membership
+// cache.
+type Queue struct {
+	*sync.Mutex
+	Client    Client
+	Members   *MembershipCache
+	StateFile string
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	pending   map[membership]*PendingMutation
+	version   uint64
+	wake      chan struct{}
+	// stopProcessing cancels the background processing which has been started by Start
+	stopProcessing context.CancelFunc
+	processing     sync.WaitGroup
+}
+
+// NewQueue creates a queue for the client and loads the pending changes from the state file if it is set.
+func NewQueue(client Client, stateFile string) (*Queue, error) {
+	queue := &Queue{
+		Mutex:     &sync.Mutex{},
+		Client:    client,
+		Members:   NewMembershipCache(client),
+		StateFile: stateFile,
+		BaseDelay: DefaultQueueBaseDelay,
+		MaxDelay:  DefaultQueueMaxDelay,
+		pending:   make(map[membership]*PendingMutation),
+		wake:      make(chan struct{}, 1),
+	}
+	if err := queue.load(); err != nil {
+		return nil, err
+	}
+	return queue, nil
+}
+
+// SetServerGroup enqueues the desired server group membership of the client and replaces any pending change of the
+// same membership.
+func (queue *Queue) SetServerGroup(serverGroupId, clientDbId int, add bool) {
+	queue.SetServerGroupContext(context.Background(), serverGroupId, clientDbId, add)
+}
+
+// SetServerGroupContext is like SetServerGroup, but the application of the change is traced as part of the span in the
+// given context and audited with its cause.
+func (queue *Queue) SetServerGroupContext(ctx context.Context, serverGroupId, clientDbId int, add bool) {
+	queue.Lock()
+	queue.version++
+	queue.pending[membership{ServerGroupId: serverGroupId, ClientDbId: clientDbId}] = &PendingMutation{
+		ServerGroupId: serverGroupId,
+		ClientDbId:    clientDbId,
+		Add:           add,
+		NextAttempt:   time.Now(),
+		Cause:         audit.CauseFromContext(ctx),
+		version:       queue.version,
+		spanContext:   trace.SpanContextFromContext(ctx),
+	}
+	queue.persist()
+	queue.Unlock()
+	select {
+	case queue.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Pending returns all changes which have not been applied yet ordered by their next attempt.
+func (queue *Queue) Pending() []PendingMutation {
+	queue.Lock()
+	defer queue.Unlock()
+	pending := make([]PendingMutation, 0, len(queue.pending))
+	for _, mutation := range queue.pending {
+		pending = append(pending, *mutation)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].NextAttempt.Before(pending[j].NextAttempt)
+	})
+	return pending
+}
+
+// Start processes the queue and refreshes the membership cache in the background until the context is done or the
+// queue is drained.
+func (queue *Queue) Start(ctx context.Context) {
+	queue.Members.Start(ctx)
+	processingCtx, cancel := context.WithCancel(ctx)
+	queue.Lock()
+	queue.stopProcessing = cancel
+	queue.Unlock()
+	queue.processing.Add(1)
+	go func() {
+		defer queue.processing.Done()
+		_ = queue.run(processingCtx, false)
+	}()
+}
+
+// Drain processes the queue until all changes have been applied or the context is done. The background processing
+// started by Start is stopped first, so that no change is applied twice.
+func (queue *Queue) Drain(ctx context.Context) error {
+	queue.Lock()
+	stopProcessing := queue.stopProcessing
+	queue.Unlock()
+	if stopProcessing != nil {
+		stopProcessing()
+	}
+	queue.processing.Wait()
+	return queue.run(ctx, true)
+}
+
+func (queue *Queue) run(ctx context.Context, untilEmpty bool) error {
+	for {
+		queue.process()
+		queue.Lock()
+		empty := len(queue.pending) == 0
+		wait := queue.nextDelay()
+		queue.Unlock()
+		if empty && untilEmpty {
+			return nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-queue.wake:
+			timer.Stop()
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// process applies all changes which are due.
+func (queue *Queue) process() {
+	now := time.Now()
+	queue.Lock()
+	due := make([]PendingMutation, 0)
+	for _, mutation := range queue.pending {
+		if !mutation.NextAttempt.After(now) {
+			due = append(due, *mutation)
+		}
+	}
+	queue.Unlock()
+	for _, mutation := range due {
+		ctx := audit.WithCause(context.Background(), mutation.Cause)
+		ctx, span := tracer.Start(trace.ContextWithSpanContext(ctx, mutation.spanContext),
+			"teamspeak.queue.apply", trace.WithAttributes(
+				attribute.Int(logging.FieldServerGroupId, mutation.ServerGroupId),
+				attribute.Int(logging.FieldTeamspeakDbId, mutation.ClientDbId),
+				attribute.Bool("add", mutation.Add),
+				attribute.Int("attempts", mutation.Attempts),
+			))
+		err := queue.Members.SetServerGroup(ctx, mutation.ServerGroupId, mutation.ClientDbId, mutation.Add)
+		end(span, err)
+		queue.complete(ctx, mutation, err)
+	}
+}
+
+func (queue *Queue) complete(ctx context.Context, mutation PendingMutation, err error) {
+	queue.Lock()
+	defer queue.Unlock()
+	key := membership{ServerGroupId: mutation.ServerGroupId, ClientDbId: mutation.ClientDbId}
+	current, ok := queue.pending[key]
+	if !ok || current.version != mutation.version {
+		// the desired state has changed in the meantime and is applied separately
+		return
+	}
+	if err == nil {
+		delete(queue.pending, key)
+		queue.persist()
+		return
+	}
+	current.Attempts++
+	delay := queue.backoff(current.Attempts)
+	current.NextAttempt = time.Now().Add(delay)
+	Log.WithFields(withCorrelationId(ctx, logrus.Fields{
+		logging.FieldTeamspeakDbId: current.ClientDbId,
+		logging.FieldServerGroupId: current.ServerGroupId,
+		"attempts":                 current.Attempts,
+		"retryIn":                  delay.String(),
+	})).WithError(err).Warnln("could not apply server group change, retrying later")
+	queue.persist()
+}
+
+func (queue *Queue) backoff(attempts int) time.Duration {
+	return backoff(queue.BaseDelay, queue.MaxDelay, attempts)
+}
+
+// backoff returns the delay before the next attempt of a change which has failed the given amount of times.
+func backoff(baseDelay, maxDelay time.Duration, attempts int) time.Duration {
+	delay := baseDelay
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+// nextDelay returns the duration until the next pending change is due. The queue has to be locked.
+func (queue *Queue) nextDelay() time.Duration {
+	wait := queue.MaxDelay
+	for _, mutation := range queue.pending {
+		if delay := time.Until(mutation.NextAttempt); delay < wait {
+			wait = delay
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// persist writes the pending changes to the state file. The queue has to be locked.
+func (queue *Queue) persist() {
+	if queue.StateFile == "" {
+		return
+	}
+	pending := make([]*PendingMutation, 0, len(queue.pending))
+	for _, mutation := range queue.pending {
+		pending = append(pending, mutation)
+	}
+	data, err := json.Marshal(pending)
+	if err == nil {
+		tempFile := queue.StateFile + ".tmp"
+		if err = ioutil.WriteFile(tempFile, data, 0600); err == nil {
+			err = os.Rename(tempFile, queue.StateFile)
+		}
+	}
+	if err != nil {
+		Log.WithError(err).WithField("stateFile", queue.StateFile).Errorln("could not persist pending server group changes")
+	}
+}
+
+func (queue *Queue) load() error {
+	if queue.StateFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(queue.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var pending []*PendingMutation
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return err
+	}
+	for _, mutation := range pending {
+		queue.version++
+		mutation.version = queue.version
+		mutation.NextAttempt = time.Now()
+		queue.pending[membership{ServerGroupId: mutation.ServerGroupId, ClientDbId: mutation.ClientDbId}] = mutation
+	}
+	if len(pending) > 0 {
+		Log.WithField("pendingAmount", len(pending)).Infoln("loaded pending server group changes")
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package teamspeak
+
+import (
+	"context"
+	"errors"
+	ts3 "github.com/jkoenig134/go-ts3"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQueue_Coalescing(t *testing.T) {
+	mockClient := new(testClient)
+	mockClient.On("ServerGroupClientList", 42).Return(&[]ts3.ServerGroupClientList{}, nil)
+	mockClient.On("ServerGroupAddClient", 42, 1).Return(nil)
+	queue, err := NewQueue(mockClient, "")
+	assert.NoError(t, err)
+	queue.SetServerGroup(42, 1, false)
+	queue.SetServerGroup(42, 1, true)
+	assert.Len(t, queue.Pending(), 1, "expected only the latest desired state to be queued")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, queue.Drain(ctx))
+	mockClient.AssertNumberOfCalls(t, "ServerGroupClientList", 1)
+	mockClient.AssertNumberOfCalls(t, "ServerGroupAddClient", 1)
+	assert.Empty(t, queue.Pending())
+}
+
+func TestQueue_Retry(t *testing.T) {
+	mockClient := new(testClient)
+	mockClient.On("ServerGroupClientList", 42).Return(nil, errors.New("connection refused")).Twice()
+	mockClient.On("ServerGroupClientList", 42).Return(&[]ts3.ServerGroupClientList{}, nil)
+	mockClient.On("ServerGroupAddClient", 42, 1).Return(nil)
+	queue, err := NewQueue(mockClient, "")
+	assert.NoError(t, err)
+	queue.BaseDelay = 10 * time.Millisecond
+	queue.SetServerGroup(42, 1, true)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, queue.Drain(ctx))
+	mockClient.AssertNumberOfCalls(t, "ServerGroupClientList", 3)
+	mockClient.AssertNumberOfCalls(t, "ServerGroupAddClient", 1)
+}
+
+func TestQueue_DrainStopsProcessing(t *testing.T) {
+	mockClient := new(testClient)
+	mockClient.On("ServerGroupClientList", 42).Return(&[]ts3.ServerGroupClientList{}, nil)
+	mockClient.On("ServerGroupAddClient", 42, 1).Return(nil)
+	mockClient.On("ServerGroupAddClient", 42, 2).Return(nil)
+	queue, err := NewQueue(mockClient, "")
+	assert.NoError(t, err)
+	queue.Members.RefreshInterval = 0
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	queue.Start(ctx)
+	queue.SetServerGroup(42, 1, true)
+	assert.NoError(t, queue.Drain(ctx))
+	mockClient.AssertNumberOfCalls(t, "ServerGroupAddClient", 1)
+	// the background processing has been stopped by the drain
+	queue.SetServerGroup(42, 2, true)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, queue.Pending(), 1, "expected the background processing to be stopped")
+}
+
+func TestQueue_Backoff(t *testing.T) {
+	queue := &Queue{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	assert.Equal(t, time.Second, queue.backoff(1))
+	assert.Equal(t, 2*time.Second, queue.backoff(2))
+	assert.Equal(t, 8*time.Second, queue.backoff(4))
+	assert.Equal(t, 10*time.Second, queue.backoff(5))
+	assert.Equal(t, 10*time.Second, queue.backoff(100))
+}
+
+func TestQueue_StateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twitchtsbot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "queue.json")
+	failingClient := new(testClient)
+	failingClient.On("ServerGroupClientList", 42).Return(nil, errors.New("connection refused"))
+	queue, err := NewQueue(failingClient, stateFile)
+	assert.NoError(t, err)
+	queue.SetServerGroup(42, 1, false)
+	queue.process()
+	// the changes are loaded again after a restart
+	mockClient := new(testClient)
+	mockClient.On("ServerGroupClientList", 42).Return(&[]ts3.ServerGroupClientList{{ClientDbId: 1}}, nil)
+	mockClient.On("ServerGroupDeleteClient", 42, 1).Return(nil)
+	queue, err = NewQueue(mockClient, stateFile)
+	assert.NoError(t, err)
+	queue.Members.Authoritative = true
+	pending := queue.Pending()
+	if assert.Len(t, pending, 1) {
+		assert.False(t, pending[0].Add)
+		assert.Equal(t, 1, pending[0].Attempts)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, queue.Drain(ctx))
+	mockClient.AssertCalled(t, "ServerGroupDeleteClient", 42, 1)
+	queue, err = NewQueue(mockClient, stateFile)
+	assert.NoError(t, err)
+	assert.Empty(t, queue.Pending())
+}
@@ -0,0 +1,62 @@
+package teamspeak
+
+import (
+	"context"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/audit"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+)
+
+// SubscriptionHook reflects the Twitch subscriptions of linked viewers as TeamSpeak server groups.
+type SubscriptionHook struct {
+	Queue      *Queue
+	NotifyChan chan *twitch.SubscriptionState
+	Ctx        context.Context
+	// teamspeak database identifier: streaming channel
+	UserMapping map[int]twitch.Channel
+	// subscription tier: server group id
+	TierServerGroups map[string]int
+}
+
+func NewSubscriptionHook(queue *Queue, notifyChan chan *twitch.SubscriptionState,
+	ctx context.Context, userMapping map[int]twitch.Channel, tierServerGroups map[string]int) *SubscriptionHook {
+	return &SubscriptionHook{
+		Queue:            queue,
+		NotifyChan:       notifyChan,
+		Ctx:              ctx,
+		UserMapping:      userMapping,
+		TierServerGroups: tierServerGroups,
+	}
+}
+
+func (hook *SubscriptionHook) Start() {
+	go func() {
+		for {
+			select {
+			case <-hook.Ctx.Done():
+				return
+			case state, ok := <-hook.NotifyChan:
+				if !ok {
+					return
+				}
+				for databaseId, channel := range hook.UserMapping {
+					if channel.Platform == twitch.PlatformTwitch && channel.Login == state.UserLogin {
+						hook.updateSubscriptionGroups(databaseId, state)
+					}
+				}
+			}
+		}
+	}()
+}
+
+func (hook *SubscriptionHook) updateSubscriptionGroups(clientDbId int, state *twitch.SubscriptionState) {
+	// the same server group may be used for multiple tiers so it is only removed if none of its tiers match
+	serverGroups := make(map[int]bool, len(hook.TierServerGroups))
+	for tier, serverGroupId := range hook.TierServerGroups {
+		serverGroups[serverGroupId] = serverGroups[serverGroupId] || tier == state.Tier
+	}
+	ctx := audit.WithCause(context.Background(), audit.Cause{Trigger: audit.TriggerSubscription,
+		Platform: twitch.PlatformTwitch, Login: state.UserLogin})
+	for serverGroupId, add := range serverGroups {
+		hook.Queue.SetServerGroupContext(ctx, serverGroupId, clientDbId, add)
+	}
+}
@@ -0,0 +1,150 @@
+package teamspeak
+
+import (
+	"context"
+	ts3 "github.com/jkoenig134/go-ts3"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/logging"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the presence changes, the queued server group changes and the TeamSpeak operations.
+var tracer = otel.Tracer("github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/teamspeak")
+
+const (
+	operationServerGroupClientList = "servergroupclientlist"
+	operationClientList            = "clientlist"
+	operationClientDbInfo          = "clientdbinfo"
+)
+
+// TracingClient wraps a Client and records a span for every TeamSpeak operation as a child of the span in its context.
+type TracingClient struct {
+	Client
+	ctx context.Context
+}
+
+// NewTracingClient creates a client whose operations are traced as children of the span in the given context.
+func NewTracingClient(ctx context.Context, client Client) *TracingClient {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &TracingClient{Client: client, ctx: ctx}
+}
+
+func (client *TracingClient) ServerGroupClientList(serverGroupId int) (*[]ts3.ServerGroupClientList, error) {
+	span := client.start(operationServerGroupClientList, attribute.Int(logging.FieldServerGroupId, serverGroupId))
+	list, err := client.Client.ServerGroupClientList(serverGroupId)
+	end(span, err)
+	return list, err
+}
+
+func (client *TracingClient) ServerGroupAddClient(serverGroupId, clientDbId int) error {
+	span := client.start(OperationServerGroupAddClient, attribute.Int(logging.FieldServerGroupId, serverGroupId),
+		attribute.Int(logging.FieldTeamspeakDbId, clientDbId))
+	err := client.Client.ServerGroupAddClient(serverGroupId, clientDbId)
+	end(span, err)
+	return err
+}
+
+func (client *TracingClient) ServerGroupDeleteClient(serverGroupId, clientDbId int) error {
+	span := client.start(OperationServerGroupDeleteClient, attribute.Int(logging.FieldServerGroupId, serverGroupId),
+		attribute.Int(logging.FieldTeamspeakDbId, clientDbId))
+	err := client.Client.ServerGroupDeleteClient(serverGroupId, clientDbId)
+	end(span, err)
+	return err
+}
+
+func (client *TracingClient) ClientList() (*[]ts3.Client, error) {
+	span := client.start(operationClientList)
+	clients, err := client.Client.ClientList()
+	end(span, err)
+	return clients, err
+}
+
+func (client *TracingClient) ClientDbInfo(clientDbId int) (*ts3.ClientDbInfo, error) {
+	span := client.start(operationClientDbInfo, attribute.Int(logging.FieldTeamspeakDbId, clientDbId))
+	info, err := client.Client.ClientDbInfo(clientDbId)
+	end(span, err)
+	return info, err
+}
+
+func (client *TracingClient) ClientEditDescription(clientId int, description string) error {
+	span := client.start(OperationClientEdit, attribute.Int("client_id", clientId))
+	err := client.Client.ClientEditDescription(clientId, description)
+	end(span, err)
+	return err
+}
+
+func (client *TracingClient) ClientDbEditDescription(clientDbId int, description string) error {
+	span := client.start(OperationClientDbEdit, attribute.Int(logging.FieldTeamspeakDbId, clientDbId))
+	err := client.Client.ClientDbEditDescription(clientDbId, description)
+	end(span, err)
+	return err
+}
+
+func (client *TracingClient) ClientMove(request ts3.ClientMoveRequest) error {
+	span := client.start(OperationClientMove, attribute.IntSlice("client_ids", request.ClientId),
+		attribute.Int("channel_id", request.ChannelId))
+	err := client.Client.ClientMove(request)
+	end(span, err)
+	return err
+}
+
+func (client *TracingClient) SetClientChannelGroup(channelGroupId, channelId, clientDbId int) error {
+	span := client.start(OperationSetClientChannelGroup, attribute.Int("channel_group_id", channelGroupId),
+		attribute.Int("channel_id", channelId), attribute.Int(logging.FieldTeamspeakDbId, clientDbId))
+	err := client.Client.SetClientChannelGroup(channelGroupId, channelId, clientDbId)
+	end(span, err)
+	return err
+}
+
+func (client *TracingClient) ClientAddStringPermission(clientDbId int, permissionName string, permissionValue int,
+	permSkip bool) error {
+	span := client.start(OperationClientAddPermission, attribute.Int(logging.FieldTeamspeakDbId, clientDbId),
+		attribute.String("permission", permissionName))
+	err := client.Client.ClientAddStringPermission(clientDbId, permissionName, permissionValue, permSkip)
+	end(span, err)
+	return err
+}
+
+func (client *TracingClient) ClientDeletePermission(request ts3.ClientDeletePermission) error {
+	span := client.start(OperationClientDeletePermission, attribute.Int(logging.FieldTeamspeakDbId, request.ClientDbId),
+		attribute.StringSlice("permissions", request.PermsId))
+	err := client.Client.ClientDeletePermission(request)
+	end(span, err)
+	return err
+}
+
+func (client *TracingClient) SendClientMessage(clientId int, message string) error {
+	span := client.start(OperationSendTextMessage, attribute.Int("client_id", clientId))
+	err := client.Client.SendClientMessage(clientId, message)
+	end(span, err)
+	return err
+}
+
+func (client *TracingClient) start(operation string, attributes ...attribute.KeyValue) trace.Span {
+	_, span := tracer.Start(client.ctx, "teamspeak."+operation, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes...))
+	return span
+}
+
+// end marks the span as failed if an error occurred and ends it.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// withCorrelationId adds the id of the trace in the context to the log fields so that the logs can be correlated with
+// the spans. The fields are not changed if the context is not traced.
+func withCorrelationId(ctx context.Context, fields logrus.Fields) logrus.Fields {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		fields[logging.FieldCorrelationId] = spanContext.TraceID().String()
+	}
+	return fields
+}
@@ -0,0 +1,78 @@
+package teamspeak
+
+import (
+	"context"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	testSpanRecorder     *tracetest.SpanRecorder
+	testSpanRecorderOnce sync.Once
+)
+
+// spanRecorder returns the recorder of the globally registered tracer provider, which can only be registered once.
+func spanRecorder() *tracetest.SpanRecorder {
+	testSpanRecorderOnce.Do(func() {
+		testSpanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(testSpanRecorder)))
+	})
+	return testSpanRecorder
+}
+
+func TestTwitchUpdateHook_Tracing(t *testing.T) {
+	recorder := spanRecorder()
+	server, client := newTestServer(t)
+	serverGroupId := server.AddServerGroup("Live")
+	clientDbId := server.AddClient("uid=", "streamer")
+	queue, err := NewQueue(client, "")
+	assert.NoError(t, err)
+	queue.BaseDelay = 10 * time.Millisecond
+	hook := NewHook(queue, nil, nil, context.Background(), map[int]twitch.Channel{clientDbId: testChannel}, serverGroupId)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the transition span is created by the monitor which has detected the stream change
+	_, transition := otel.Tracer("test").Start(context.Background(), "twitch.transition")
+	transition.End()
+	server.FailNext("servergroupaddclient", 1)
+	hook.Reconcile([]*twitch.UserState{{Platform: testChannel.Platform, UserLogin: testChannel.Login,
+		StreamerStatus: twitch.StreamerStatusLive, SpanContext: transition.SpanContext()}})
+	assert.NoError(t, queue.Drain(ctx))
+	assert.True(t, server.IsMember(serverGroupId, clientDbId))
+
+	counts := make(map[string]int)
+	failed := make(map[string]int)
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() != transition.SpanContext().TraceID() {
+			continue
+		}
+		counts[span.Name()]++
+		if span.Status().Code == codes.Error {
+			failed[span.Name()]++
+		}
+		if span.Name() == "teamspeak.presence" {
+			assert.Equal(t, transition.SpanContext().SpanID(), span.Parent().SpanID(),
+				"expected the presence change to continue the transition")
+		}
+	}
+	assert.Equal(t, map[string]int{
+		"twitch.transition":               1,
+		"teamspeak.presence":              1,
+		"teamspeak.action":                1,
+		"teamspeak.queue.apply":           2,
+		"teamspeak.servergroupclientlist": 2,
+		"teamspeak.servergroupaddclient":  2,
+	}, counts, "expected the changes to be traced as part of the stream change")
+	assert.Equal(t, map[string]int{
+		"teamspeak.queue.apply":          1,
+		"teamspeak.servergroupaddclient": 1,
+	}, failed, "expected the failed attempt to be recorded")
+}
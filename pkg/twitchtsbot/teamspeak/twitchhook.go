@@ -3,29 +3,44 @@ package teamspeak
 import (
 	"context"
 	ts3 "github.com/jkoenig134/go-ts3"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/audit"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/logging"
 	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
 )
 
 type TwitchUpdateHook struct {
-	TsClient   *ts3.TeamspeakHttpClient
-	Monitor    *twitch.Monitor
+	TsClient Client
+	Queue    *Queue
+	// platform identifier: monitor
+	Monitors   map[string]*twitch.Monitor
 	NotifyChan chan *twitch.UserState
-	Ctx        context.Context
-	// teamspeak database identifier: twitch login id
-	UserMapping   map[int]string
-	ServerGroupId int
+	// MetadataChan receives the states of live streams whose title or game has changed. It may be nil.
+	MetadataChan chan *twitch.UserState
+	Ctx          context.Context
+	// teamspeak database identifier: streaming channel
+	UserMapping map[int]twitch.Channel
+	// Actions are performed in order for every presence change of a mapped client.
+	Actions []Action
+	// client database id: *sync.Mutex serializing the presence changes of the client
+	clientLocks sync.Map
+	running     sync.WaitGroup
 }
 
-func NewHook(teamspeakHttpClient *ts3.TeamspeakHttpClient, monitor *twitch.Monitor, notifyChan chan *twitch.UserState,
-	ctx context.Context, userMapping map[int]string, serverGroupId int) *TwitchUpdateHook {
+// NewHook creates a hook whose only action is adding live streamers to the given server group.
+func NewHook(queue *Queue, monitors map[string]*twitch.Monitor, notifyChan chan *twitch.UserState,
+	ctx context.Context, userMapping map[int]twitch.Channel, serverGroupId int) *TwitchUpdateHook {
 	return &TwitchUpdateHook{
-		TsClient:      teamspeakHttpClient,
-		Monitor:       monitor,
-		NotifyChan:    notifyChan,
-		Ctx:           ctx,
-		UserMapping:   userMapping,
-		ServerGroupId: serverGroupId,
+		TsClient:    queue.Client,
+		Queue:       queue,
+		Monitors:    monitors,
+		NotifyChan:  notifyChan,
+		Ctx:         ctx,
+		UserMapping: userMapping,
+		Actions:     []Action{NewServerGroupAction(queue, serverGroupId)},
 	}
 }
 
@@ -34,28 +49,82 @@ func (hook *TwitchUpdateHook) Start() error {
 	if err != nil {
 		return err
 	}
+	// restored monitor states are not notified, so the actions which have been undone on shutdown are applied again
+	for _, monitor := range hook.Monitors {
+		hook.Reconcile(monitor.GetStates())
+	}
+	// closed channels are set to nil so that they are not selected anymore
+	notifyChan, metadataChan := hook.NotifyChan, hook.MetadataChan
+	hook.running.Add(1)
 	go func() {
-		for {
+		defer hook.running.Done()
+		for notifyChan != nil || metadataChan != nil {
 			select {
 			case <-hook.Ctx.Done():
 				return
-			case state := <-hook.NotifyChan:
-				go func() {
-					teamspeakDatabaseId, ok := hook.retrieveTeamspeakDatabaseId(state.UserLogin)
-					if !ok {
-						return
-					}
-					hook.updateTeamspeakRank(teamspeakDatabaseId, state)
-				}()
+			case state, ok := <-notifyChan:
+				if !ok {
+					notifyChan = nil
+					continue
+				}
+				event := PresenceOffline
+				if state.StreamerStatus == twitch.StreamerStatusLive {
+					event = PresenceLive
+				}
+				hook.handleState(event, state)
+			case state, ok := <-metadataChan:
+				if !ok {
+					metadataChan = nil
+					continue
+				}
+				hook.handleState(PresenceMetadata, state)
 			}
 		}
 	}()
 	return nil
 }
 
-func (hook *TwitchUpdateHook) retrieveTeamspeakDatabaseId(searchLoginId string) (int, bool) {
-	for databaseId, loginId := range hook.UserMapping {
-		if loginId == searchLoginId {
+// Wait blocks until the hook has stopped, which is the case once its context is done or all of its channels have
+// been closed. The presence change in progress and all states which have been received before are performed first.
+func (hook *TwitchUpdateHook) Wait() {
+	hook.running.Wait()
+}
+
+// Reconcile performs the actions of the given states for all mapped TeamSpeak clients.
+func (hook *TwitchUpdateHook) Reconcile(states []*twitch.UserState) {
+	hook.ReconcileContext(context.Background(), states)
+}
+
+// ReconcileContext is like Reconcile, but the changes are recorded with the trigger of the audit cause in the context
+// instead of the one of a reconciliation.
+func (hook *TwitchUpdateHook) ReconcileContext(ctx context.Context, states []*twitch.UserState) {
+	for _, state := range states {
+		hook.handleStateContext(ctx, PresenceSync, state)
+	}
+}
+
+func (hook *TwitchUpdateHook) handleState(event PresenceEvent, state *twitch.UserState) {
+	hook.handleStateContext(context.Background(), event, state)
+}
+
+// handleStateContext performs the presence change of the state as a continuation of the trace in which the state has
+// been detected.
+func (hook *TwitchUpdateHook) handleStateContext(ctx context.Context, event PresenceEvent, state *twitch.UserState) {
+	ctx, span := tracer.Start(trace.ContextWithSpanContext(withPresenceCause(ctx, event, state), state.SpanContext),
+		"teamspeak.presence", trace.WithAttributes(presenceAttributes(event, state)...))
+	defer span.End()
+	teamspeakDatabaseId, ok := hook.retrieveTeamspeakDatabaseId(state.Channel())
+	span.SetAttributes(attribute.Bool("mapped", ok))
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int(logging.FieldTeamspeakDbId, teamspeakDatabaseId))
+	hook.perform(&Presence{Event: event, ClientDbId: teamspeakDatabaseId, State: state, Context: ctx})
+}
+
+func (hook *TwitchUpdateHook) retrieveTeamspeakDatabaseId(searchChannel twitch.Channel) (int, bool) {
+	for databaseId, channel := range hook.UserMapping {
+		if channel == searchChannel {
 			return databaseId, true
 		}
 	}
@@ -67,53 +136,88 @@ func (hook *TwitchUpdateHook) enterClientHook(event *ts3.ClientEnterViewEvent) {
 	if event.ClientType != 0 {
 		return
 	}
-	twitchLoginId, ok := hook.UserMapping[event.ClientDatabaseId]
+	channel, ok := hook.UserMapping[event.ClientDatabaseId]
 	if !ok {
 		return
 	}
-	state, ok := hook.Monitor.GetState(twitchLoginId)
+	monitor, ok := hook.Monitors[channel.Platform]
 	if !ok {
 		return
 	}
-	hook.updateTeamspeakRank(event.ClientDatabaseId, state)
-}
-
-func (hook *TwitchUpdateHook) updateTeamspeakRank(clientDbId int, state *twitch.UserState) {
-	add := state.StreamerStatus == twitch.StreamerStatusLive
-	var hasServerGroup bool
-	members, err := hook.TsClient.ServerGroupClientList(hook.ServerGroupId)
-	if err != nil {
-		Log.WithError(err).WithField("serverGroupId", hook.ServerGroupId).Errorln("could not retrieve server group members")
+	state, ok := monitor.GetState(channel.Login)
+	if !ok {
 		return
-	} else {
-		for _, member := range *members {
-			if member.ClientDbId != clientDbId {
-				continue
-			}
-			if add {
-				return
-			} else {
-				hasServerGroup = true
-				break
-			}
-		}
 	}
-	if !add && !hasServerGroup {
-		Log.WithFields(logrus.Fields{"clientDbId": clientDbId, "serverGroupId": hook.ServerGroupId}).
-			Traceln("client does not have the server group which should be removed")
-		return
+	// the connect is not caused by the stream change, so it starts a new trace which is only linked to it
+	options := []trace.SpanStartOption{
+		trace.WithAttributes(presenceAttributes(PresenceEnterView, state)...),
+		trace.WithAttributes(attribute.Int(logging.FieldTeamspeakDbId, event.ClientDatabaseId)),
 	}
-	if add {
-		err := hook.TsClient.ServerGroupAddClient(hook.ServerGroupId, clientDbId)
+	if state.SpanContext.IsValid() {
+		options = append(options, trace.WithLinks(trace.Link{SpanContext: state.SpanContext}))
+	}
+	ctx, span := tracer.Start(withPresenceCause(context.Background(), PresenceEnterView, state), "teamspeak.presence",
+		options...)
+	defer span.End()
+	hook.perform(&Presence{
+		Event:        PresenceEnterView,
+		ClientDbId:   event.ClientDatabaseId,
+		ClientId:     event.ClientId,
+		ServerGroups: event.ClientServergroups,
+		State:        state,
+		Context:      ctx,
+	})
+}
+
+// perform runs all actions for the presence change. A failed action does not prevent the following ones. The
+// presence changes of a single client are performed one after another, as connecting clients are handled concurrently
+// to stream changes.
+func (hook *TwitchUpdateHook) perform(presence *Presence) {
+	lock, _ := hook.clientLocks.LoadOrStore(presence.ClientDbId, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+	for _, action := range hook.Actions {
+		ctx, span := tracer.Start(presence.context(), "teamspeak.action",
+			trace.WithAttributes(attribute.String("action", action.Name())))
+		actionPresence := *presence
+		actionPresence.Context = ctx
+		err := action.Perform(&actionPresence)
+		end(span, err)
 		if err != nil {
-			Log.WithFields(logrus.Fields{"clientDbId": clientDbId, "serverGroupId": hook.ServerGroupId}).
-				WithError(err).Warnln("could not add client to server group")
+			Log.WithError(err).WithFields(withCorrelationId(ctx, logrus.Fields{
+				"action":                   action.Name(),
+				"event":                    presence.Event.String(),
+				logging.FieldTeamspeakDbId: presence.ClientDbId,
+				logging.FieldTwitchLogin:   presence.State.UserLogin,
+			})).Warnln("could not perform presence action")
 		}
-	} else {
-		err := hook.TsClient.ServerGroupDeleteClient(hook.ServerGroupId, clientDbId)
-		if err != nil {
-			Log.WithFields(logrus.Fields{"clientDbId": clientDbId, "serverGroupId": hook.ServerGroupId}).
-				WithError(err).Warnln("could not remove client from server group")
+	}
+}
+
+// withPresenceCause adds the audit cause of the presence change to the context. The trigger of a cause which is
+// already set in the context is kept.
+func withPresenceCause(ctx context.Context, event PresenceEvent, state *twitch.UserState) context.Context {
+	cause := audit.CauseFromContext(ctx)
+	if cause.Trigger == "" {
+		switch event {
+		case PresenceLive, PresenceOffline:
+			cause.Trigger = audit.TriggerTransition
+		case PresenceMetadata:
+			cause.Trigger = audit.TriggerMetadata
+		case PresenceEnterView:
+			cause.Trigger = audit.TriggerEnterView
+		default:
+			cause.Trigger = audit.TriggerReconciliation
 		}
 	}
+	cause.Platform, cause.Login = state.Platform, state.UserLogin
+	return audit.WithCause(ctx, cause)
+}
+
+func presenceAttributes(event PresenceEvent, state *twitch.UserState) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("event", event.String()),
+		attribute.String("platform", state.Platform),
+		attribute.String(logging.FieldTwitchLogin, state.UserLogin),
+	}
 }
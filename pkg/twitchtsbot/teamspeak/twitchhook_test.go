@@ -0,0 +1,121 @@
+package teamspeak
+
+import (
+	"context"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/audit"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/testutil/ts3test"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testChannel = twitch.Channel{Platform: twitch.PlatformTwitch, Login: "streamer"}
+
+func newTestServer(t *testing.T) (*ts3test.Server, *WebQueryClient) {
+	server, err := ts3test.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	return server, NewWebQueryClient(server.URL, server.APIKey, ts3test.DefaultServerID)
+}
+
+func TestTwitchUpdateHook_Reconcile(t *testing.T) {
+	server, client := newTestServer(t)
+	serverGroupId := server.AddServerGroup("Live")
+	clientDbId := server.AddClient("uid=", "streamer")
+	queue, err := NewQueue(client, "")
+	assert.NoError(t, err)
+	queue.BaseDelay = 10 * time.Millisecond
+	hook := NewHook(queue, nil, nil, context.Background(), map[int]twitch.Channel{clientDbId: testChannel}, serverGroupId)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	server.FailNext("servergroupaddclient", 1)
+	hook.Reconcile([]*twitch.UserState{{Platform: testChannel.Platform, UserLogin: testChannel.Login,
+		StreamerStatus: twitch.StreamerStatusLive}})
+	assert.NoError(t, queue.Drain(ctx))
+	assert.True(t, server.IsMember(serverGroupId, clientDbId), "expected live streamer to get the server group")
+	assert.Equal(t, 2, server.Requests("servergroupaddclient"), "expected the failed change to be retried")
+
+	hook.Reconcile([]*twitch.UserState{{Platform: testChannel.Platform, UserLogin: "unknown",
+		StreamerStatus: twitch.StreamerStatusOffline}})
+	assert.Empty(t, queue.Pending(), "expected unmapped channels to be ignored")
+	hook.Reconcile([]*twitch.UserState{{Platform: testChannel.Platform, UserLogin: testChannel.Login,
+		StreamerStatus: twitch.StreamerStatusOffline}})
+	assert.NoError(t, queue.Drain(ctx))
+	assert.False(t, server.IsMember(serverGroupId, clientDbId), "expected offline streamer to lose the server group")
+}
+
+func TestTwitchUpdateHook_EnterClient(t *testing.T) {
+	server, client := newTestServer(t)
+	serverGroupId := server.AddServerGroup("Live")
+	clientDbId := server.AddClient("uid=", "streamer")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	monitor := twitch.NewMonitor(nil, []string{testChannel.Login}, time.Second, ctx, nil)
+	queue, err := NewQueue(client, "")
+	assert.NoError(t, err)
+	queue.Start(ctx)
+	hook := NewHook(queue, map[string]*twitch.Monitor{twitch.PlatformTwitch: monitor}, make(chan *twitch.UserState),
+		ctx, map[int]twitch.Channel{clientDbId: testChannel}, serverGroupId)
+	assert.NoError(t, hook.Start())
+	monitor.Restore(&twitch.Snapshot{States: map[string]*twitch.UserState{testChannel.Login: {
+		Platform: testChannel.Platform, UserLogin: testChannel.Login, StreamerStatus: twitch.StreamerStatusLive}}})
+	assert.NoError(t, client.StartEventClient(server.QueryAddress, server.QueryUser, server.QueryPassword))
+	assert.Eventually(t, func() bool {
+		return server.EventListeners() == 1
+	}, time.Second, 10*time.Millisecond, "expected the event client to register for events")
+
+	// the live streamer connects without having the server group
+	assert.True(t, server.Connect(clientDbId))
+	assert.Eventually(t, func() bool {
+		return server.IsMember(serverGroupId, clientDbId)
+	}, time.Second, 10*time.Millisecond, "expected entering live streamer to get the server group")
+}
+
+func TestTwitchUpdateHook_Audit(t *testing.T) {
+	server, client := newTestServer(t)
+	serverGroupId := server.AddServerGroup("Live")
+	clientDbId := server.AddClient("uid=", "streamer")
+	dir, err := ioutil.TempDir("", "twitchtsbot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store := audit.NewStore(filepath.Join(dir, "audit.jsonl"))
+	queue, err := NewQueue(client, "")
+	assert.NoError(t, err)
+	queue.BaseDelay = 10 * time.Millisecond
+	queue.Members.Audit = &audit.Recorder{Store: store, Target: "default"}
+	hook := NewHook(queue, nil, nil, context.Background(), map[int]twitch.Channel{clientDbId: testChannel}, serverGroupId)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	server.FailNext("servergroupaddclient", 1)
+	hook.handleState(PresenceLive, &twitch.UserState{Platform: testChannel.Platform, UserLogin: testChannel.Login,
+		StreamerStatus: twitch.StreamerStatusLive})
+	assert.NoError(t, queue.Drain(ctx))
+	adminCtx := audit.WithCause(context.Background(), audit.Cause{Trigger: audit.TriggerAdmin})
+	hook.ReconcileContext(adminCtx, []*twitch.UserState{{Platform: testChannel.Platform, UserLogin: testChannel.Login,
+		StreamerStatus: twitch.StreamerStatusOffline}})
+	assert.NoError(t, queue.Drain(ctx))
+
+	entries, err := store.Query(audit.Filter{})
+	assert.NoError(t, err)
+	summary := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		assert.Equal(t, "default", entry.Target)
+		assert.Equal(t, clientDbId, entry.ClientDbId)
+		assert.Equal(t, testChannel.Login, entry.Login)
+		assert.Equal(t, serverGroupId, entry.ServerGroupId)
+		summary = append(summary, entry.Action+" "+entry.Trigger+" "+entry.Outcome)
+	}
+	assert.Equal(t, []string{
+		"servergroup_added transition failure",
+		"servergroup_added transition success",
+		"servergroup_removed admin success",
+	}, summary, "expected every attempt to be audited with its trigger")
+}
@@ -0,0 +1,175 @@
+package teamspeak
+
+import (
+	"encoding/json"
+	"fmt"
+	ts3 "github.com/jkoenig134/go-ts3"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebQueryClient is the TeamSpeak client of the bot. It sends clientedit and clientdbedit itself, as go-ts3 cannot send
+// them without resetting unrelated client properties because its request structs always contain all of them.
+//
+// The embedded go-ts3 client only delivers the ServerQuery events, as go-ts3 cannot change the API key of a client.
+// The commands are sent by a separate go-ts3 client which is replaced by SetAPIKey. The embedded client keeps the
+// original API key, which it never sends, as its events are received over the raw ServerQuery with the query login.
+type WebQueryClient struct {
+	*ts3.TeamspeakHttpClient
+	URL         string
+	ServerID    int
+	HTTPClient  *http.Client
+	apiKeyMutex sync.RWMutex
+	apiKey      string
+	commands    *ts3.TeamspeakHttpClient
+}
+
+// NewWebQueryClient creates a client for the virtual server with the given id of the WebQuery at the given URL.
+func NewWebQueryClient(baseURL, apiKey string, serverId int) *WebQueryClient {
+	client := &WebQueryClient{
+		TeamspeakHttpClient: newTs3Client(baseURL, apiKey, serverId),
+		URL:                 baseURL,
+		ServerID:            serverId,
+		HTTPClient:          &http.Client{Timeout: 10 * time.Second},
+	}
+	client.SetAPIKey(apiKey)
+	return client
+}
+
+func newTs3Client(baseURL, apiKey string, serverId int) *ts3.TeamspeakHttpClient {
+	client := ts3.NewClient(ts3.NewConfig(baseURL, apiKey))
+	client.SetServerID(serverId)
+	return &client
+}
+
+// SetAPIKey replaces the API key of all following commands e.g. after it has been rotated.
+func (client *WebQueryClient) SetAPIKey(apiKey string) {
+	commands := newTs3Client(client.URL, apiKey, client.ServerID)
+	client.apiKeyMutex.Lock()
+	defer client.apiKeyMutex.Unlock()
+	client.apiKey = apiKey
+	client.commands = commands
+}
+
+// APIKey returns the API key the commands are sent with.
+func (client *WebQueryClient) APIKey() string {
+	client.apiKeyMutex.RLock()
+	defer client.apiKeyMutex.RUnlock()
+	return client.apiKey
+}
+
+func (client *WebQueryClient) ts3() *ts3.TeamspeakHttpClient {
+	client.apiKeyMutex.RLock()
+	defer client.apiKeyMutex.RUnlock()
+	return client.commands
+}
+
+func (client *WebQueryClient) Version() (*ts3.Version, error) {
+	return client.ts3().Version()
+}
+
+func (client *WebQueryClient) ServerGroupList() (*[]ts3.ServerGroup, error) {
+	return client.ts3().ServerGroupList()
+}
+
+func (client *WebQueryClient) ServerGroupClientList(serverGroupId int) (*[]ts3.ServerGroupClientList, error) {
+	return client.ts3().ServerGroupClientList(serverGroupId)
+}
+
+func (client *WebQueryClient) ServerGroupAddClient(serverGroupId, clientDbId int) error {
+	return client.ts3().ServerGroupAddClient(serverGroupId, clientDbId)
+}
+
+func (client *WebQueryClient) ServerGroupDeleteClient(serverGroupId, clientDbId int) error {
+	return client.ts3().ServerGroupDeleteClient(serverGroupId, clientDbId)
+}
+
+func (client *WebQueryClient) ClientList() (*[]ts3.Client, error) {
+	return client.ts3().ClientList()
+}
+
+func (client *WebQueryClient) ClientDbInfo(clientDbId int) (*ts3.ClientDbInfo, error) {
+	return client.ts3().ClientDbInfo(clientDbId)
+}
+
+func (client *WebQueryClient) ClientGetDbIdFromUid(clientUid string) (*int, error) {
+	return client.ts3().ClientGetDbIdFromUid(clientUid)
+}
+
+func (client *WebQueryClient) ClientMove(request ts3.ClientMoveRequest) error {
+	return client.ts3().ClientMove(request)
+}
+
+func (client *WebQueryClient) ClientAddStringPermission(clientDbId int, permissionName string, permissionValue int,
+	permSkip bool) error {
+	return client.ts3().ClientAddStringPermission(clientDbId, permissionName, permissionValue, permSkip)
+}
+
+func (client *WebQueryClient) ClientDeletePermission(request ts3.ClientDeletePermission) error {
+	return client.ts3().ClientDeletePermission(request)
+}
+
+func (client *WebQueryClient) StringPermissionGet(permissionId string) (*ts3.PermissionGetResponse, error) {
+	return client.ts3().StringPermissionGet(permissionId)
+}
+
+func (client *WebQueryClient) SendClientMessage(clientId int, message string) error {
+	return client.ts3().SendClientMessage(clientId, message)
+}
+
+// ClientEditDescription changes the description of the connected client with the given client id.
+func (client *WebQueryClient) ClientEditDescription(clientId int, description string) error {
+	return client.command("clientedit", url.Values{
+		"clid":               {strconv.Itoa(clientId)},
+		"client_description": {description},
+	})
+}
+
+// ClientDbEditDescription changes the stored description of the client with the given database id.
+func (client *WebQueryClient) ClientDbEditDescription(clientDbId int, description string) error {
+	return client.command("clientdbedit", url.Values{
+		"cldbid":             {strconv.Itoa(clientDbId)},
+		"client_description": {description},
+	})
+}
+
+// SetClientChannelGroup assigns the channel group in the given channel to the client with the given database id.
+func (client *WebQueryClient) SetClientChannelGroup(channelGroupId, channelId, clientDbId int) error {
+	return client.ts3().SetClientChannelGroup(channelGroupId, channelId, clientDbId)
+}
+
+// command sends a WebQuery command without a response body and returns the TeamSpeak error it has been answered with.
+func (client *WebQueryClient) command(command string, params url.Values) error {
+	requestUrl := fmt.Sprintf("%s/%d/%s?%s", strings.TrimSuffix(client.URL, "/"), client.ServerID, command,
+		params.Encode())
+	request, err := http.NewRequest(http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("x-api-key", client.APIKey())
+	response, err := client.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("teamspeak webquery returned status code %d for %s", response.StatusCode, command)
+	}
+	var body struct {
+		Status struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"status"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return err
+	}
+	if body.Status.Code != 0 {
+		return fmt.Errorf("teamspeak error %d for %s: %s", body.Status.Code, command, body.Status.Message)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package teamspeak
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWebQueryClient_SetAPIKey(t *testing.T) {
+	server, client := newTestServer(t)
+	serverGroupId := server.AddServerGroup("Live")
+	clientDbId := server.AddClient("uid=", "streamer")
+	server.SetAPIKey("rotated")
+	assert.Error(t, client.ServerGroupAddClient(serverGroupId, clientDbId), "expected the old API key to be rejected")
+	assert.Error(t, client.ClientDbEditDescription(clientDbId, "live"), "expected the old API key to be rejected")
+
+	client.SetAPIKey("rotated")
+	assert.Equal(t, "rotated", client.APIKey())
+	assert.NoError(t, client.ServerGroupAddClient(serverGroupId, clientDbId))
+	assert.True(t, server.IsMember(serverGroupId, clientDbId))
+	assert.NoError(t, client.ClientDbEditDescription(clientDbId, "live"))
+}
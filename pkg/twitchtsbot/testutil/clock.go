@@ -0,0 +1,151 @@
+package testutil
+
+import (
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/clock"
+	"sync"
+	"testing"
+	"time"
+)
+
+// FakeClock is a clock.Clock which only moves forward when it is advanced. Timers, tickers and After channels fire
+// synchronously within Advance once their deadline has been reached.
+type FakeClock struct {
+	*sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	clock    *FakeClock
+	deadline time.Time
+	// period is the interval of a ticker and zero for timers
+	period time.Duration
+	c      chan time.Time
+}
+
+// NewFakeClock creates a FakeClock which starts at the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{Mutex: &sync.Mutex{}, now: now}
+}
+
+func (fakeClock *FakeClock) Now() time.Time {
+	fakeClock.Lock()
+	defer fakeClock.Unlock()
+	return fakeClock.now
+}
+
+func (fakeClock *FakeClock) After(d time.Duration) <-chan time.Time {
+	return fakeClock.NewTimer(d).C()
+}
+
+func (fakeClock *FakeClock) NewTimer(d time.Duration) clock.Timer {
+	waiter := &fakeWaiter{clock: fakeClock, c: make(chan time.Time, 1)}
+	waiter.Reset(d)
+	return waiter
+}
+
+func (fakeClock *FakeClock) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	waiter := &fakeWaiter{clock: fakeClock, period: d, c: make(chan time.Time, 1)}
+	waiter.Reset(d)
+	return &fakeTicker{fakeWaiter: waiter}
+}
+
+// Advance moves the clock forward by the given duration and fires all timers and tickers which are due in the order
+// of their deadlines. Like time.Ticker, a ticker drops ticks which are not received in time.
+func (fakeClock *FakeClock) Advance(d time.Duration) {
+	fakeClock.Lock()
+	defer fakeClock.Unlock()
+	target := fakeClock.now.Add(d)
+	for {
+		next := -1
+		for i, waiter := range fakeClock.waiters {
+			if !waiter.deadline.After(target) && (next == -1 || waiter.deadline.Before(fakeClock.waiters[next].deadline)) {
+				next = i
+			}
+		}
+		if next == -1 {
+			break
+		}
+		waiter := fakeClock.waiters[next]
+		fakeClock.now = waiter.deadline
+		waiter.fire(fakeClock.now)
+		if waiter.period > 0 {
+			waiter.deadline = waiter.deadline.Add(waiter.period)
+		} else {
+			fakeClock.remove(waiter)
+		}
+	}
+	fakeClock.now = target
+}
+
+// Waiters returns the amount of pending timers, tickers and After channels.
+func (fakeClock *FakeClock) Waiters() int {
+	fakeClock.Lock()
+	defer fakeClock.Unlock()
+	return len(fakeClock.waiters)
+}
+
+// WaitForWaiters blocks until at least the given amount of timers, tickers and After channels are pending, which
+// e.g. shows that a goroutine is waiting for its next poll. The test fails if they are not created within 5 seconds.
+func (fakeClock *FakeClock) WaitForWaiters(t *testing.T, waiters int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for fakeClock.Waiters() < waiters {
+		if time.Now().After(deadline) {
+			t.Fatalf("exceeded wait time: wait for %d pending timers of the fake clock", waiters)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (fakeClock *FakeClock) remove(waiter *fakeWaiter) bool {
+	for i, pending := range fakeClock.waiters {
+		if pending == waiter {
+			fakeClock.waiters = append(fakeClock.waiters[:i], fakeClock.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (waiter *fakeWaiter) fire(now time.Time) {
+	select {
+	case waiter.c <- now:
+	default:
+	}
+}
+
+func (waiter *fakeWaiter) C() <-chan time.Time {
+	return waiter.c
+}
+
+func (waiter *fakeWaiter) Stop() bool {
+	waiter.clock.Lock()
+	defer waiter.clock.Unlock()
+	return waiter.clock.remove(waiter)
+}
+
+func (waiter *fakeWaiter) Reset(d time.Duration) bool {
+	waiter.clock.Lock()
+	defer waiter.clock.Unlock()
+	active := waiter.clock.remove(waiter)
+	if d <= 0 && waiter.period == 0 {
+		waiter.fire(waiter.clock.now)
+		return active
+	}
+	waiter.deadline = waiter.clock.now.Add(d)
+	waiter.clock.waiters = append(waiter.clock.waiters, waiter)
+	return active
+}
+
+// fakeTicker adapts the Stop method of a fakeWaiter to the clock.Ticker interface.
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (ticker *fakeTicker) Stop() {
+	ticker.fakeWaiter.Stop()
+}
@@ -0,0 +1,39 @@
+package testutil
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFakeClock_Timer(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := NewFakeClock(start)
+	after := fakeClock.After(time.Second)
+	timer := fakeClock.NewTimer(2 * time.Second)
+	assert.Equal(t, 2, fakeClock.Waiters())
+	fakeClock.Advance(999 * time.Millisecond)
+	assert.Len(t, after, 0, "expected After to not fire before its deadline")
+	fakeClock.Advance(time.Millisecond)
+	assert.Equal(t, start.Add(time.Second), <-after)
+	assert.True(t, timer.Reset(time.Second), "expected Reset of a pending timer to return true")
+	fakeClock.Advance(time.Second)
+	assert.Equal(t, start.Add(2*time.Second), <-timer.C())
+	assert.False(t, timer.Stop(), "expected Stop of a fired timer to return false")
+	assert.Equal(t, 0, fakeClock.Waiters())
+	assert.Equal(t, start.Add(2*time.Second), fakeClock.Now())
+}
+
+func TestFakeClock_Ticker(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := NewFakeClock(start)
+	ticker := fakeClock.NewTicker(time.Second)
+	fakeClock.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), <-ticker.C())
+	fakeClock.Advance(3 * time.Second)
+	assert.Equal(t, start.Add(2*time.Second), <-ticker.C(), "expected ticks which are not received to be dropped")
+	assert.Len(t, ticker.C(), 0)
+	ticker.Stop()
+	fakeClock.Advance(time.Second)
+	assert.Len(t, ticker.C(), 0, "expected a stopped ticker to not tick")
+}
@@ -0,0 +1,151 @@
+package helixtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/nicklaw5/helix"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultTokenLifetime is the duration issued tokens are valid for.
+const DefaultTokenLifetime = 24 * time.Hour
+
+// Token is an access token which has been issued by the fake server. App access tokens have no user.
+type Token struct {
+	AccessToken string
+	UserID      string
+	Login       string
+	Scopes      []string
+	ExpiresAt   time.Time
+}
+
+func (token *Token) HasScope(scope string) bool {
+	for _, tokenScope := range token.Scopes {
+		if tokenScope == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IssueAppToken issues a new app access token.
+func (server *Server) IssueAppToken() string {
+	server.Lock()
+	defer server.Unlock()
+	return server.issueToken(nil, nil).AccessToken
+}
+
+// IssueUserToken issues a new user access token with the given scopes. The user is added if it does not exist yet.
+func (server *Server) IssueUserToken(login string, scopes ...string) string {
+	server.Lock()
+	defer server.Unlock()
+	return server.issueToken(server.addUser(login), scopes).AccessToken
+}
+
+// ExpireToken makes the access token invalid as if its lifetime has passed.
+func (server *Server) ExpireToken(accessToken string) {
+	server.Lock()
+	defer server.Unlock()
+	if token, ok := server.tokens[accessToken]; ok {
+		token.ExpiresAt = time.Now()
+	}
+}
+
+func (server *Server) issueToken(user *helix.User, scopes []string) *Token {
+	token := &Token{
+		AccessToken: randomToken(),
+		Scopes:      append([]string(nil), scopes...),
+		ExpiresAt:   time.Now().Add(DefaultTokenLifetime),
+	}
+	if user != nil {
+		token.UserID = user.ID
+		token.Login = user.Login
+	}
+	server.tokens[token.AccessToken] = token
+	return token
+}
+
+func (server *Server) validToken(accessToken string) (*Token, bool) {
+	token, ok := server.tokens[accessToken]
+	if !ok || !time.Now().Before(token.ExpiresAt) {
+		return nil, false
+	}
+	return token, true
+}
+
+// handleToken issues app access tokens by the client credentials flow.
+func (server *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	query := r.URL.Query()
+	if query.Get("grant_type") != "client_credentials" {
+		writeError(w, http.StatusBadRequest, "unsupported grant type")
+		return
+	}
+	server.Lock()
+	defer server.Unlock()
+	if query.Get("client_id") != server.ClientID || query.Get("client_secret") != server.ClientSecret {
+		writeError(w, http.StatusForbidden, "invalid client secret")
+		return
+	}
+	var scopes []string
+	if scope := query.Get("scope"); scope != "" {
+		scopes = strings.Split(scope, " ")
+	}
+	token := server.issueToken(nil, scopes)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": token.AccessToken,
+		"expires_in":   int(DefaultTokenLifetime.Seconds()),
+		"scope":        token.Scopes,
+		"token_type":   "bearer",
+	})
+}
+
+func (server *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
+	authorization := r.Header.Get("Authorization")
+	accessToken := strings.TrimPrefix(strings.TrimPrefix(authorization, "OAuth "), "Bearer ")
+	server.Lock()
+	defer server.Unlock()
+	token, ok := server.validToken(accessToken)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"client_id":  server.ClientID,
+		"login":      token.Login,
+		"scopes":     token.Scopes,
+		"user_id":    token.UserID,
+		"expires_in": int(time.Until(token.ExpiresAt).Seconds()),
+	})
+}
+
+func (server *Server) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	query := r.URL.Query()
+	server.Lock()
+	defer server.Unlock()
+	if query.Get("client_id") != server.ClientID {
+		writeError(w, http.StatusBadRequest, "invalid client")
+		return
+	}
+	if _, ok := server.tokens[query.Get("token")]; !ok {
+		writeError(w, http.StatusBadRequest, "Invalid token")
+		return
+	}
+	delete(server.tokens, query.Get("token"))
+	w.WriteHeader(http.StatusOK)
+}
+
+func randomToken() string {
+	data := make([]byte, 15)
+	_, _ = rand.Read(data)
+	return hex.EncodeToString(data)
+}
@@ -0,0 +1,138 @@
+package helixtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// EventSubStatusEnabled is the status of all created EventSub subscriptions as the fake server does not verify the
+// callbacks.
+const EventSubStatusEnabled = "enabled"
+
+// EventSubSubscription is an EventSub subscription which has been created through the API.
+type EventSubSubscription struct {
+	ID        string            `json:"id"`
+	Status    string            `json:"status"`
+	Type      string            `json:"type"`
+	Version   string            `json:"version"`
+	Condition map[string]string `json:"condition"`
+	Transport EventSubTransport `json:"transport"`
+	CreatedAt time.Time         `json:"created_at"`
+	Cost      int               `json:"cost"`
+}
+
+type EventSubTransport struct {
+	Method   string `json:"method"`
+	Callback string `json:"callback"`
+	// Secret is only part of the creation request.
+	Secret string `json:"secret,omitempty"`
+}
+
+type manyEventSubSubscriptions struct {
+	Subscriptions []EventSubSubscription `json:"data"`
+	Total         int                    `json:"total"`
+	TotalCost     int                    `json:"total_cost"`
+	MaxTotalCost  int                    `json:"max_total_cost"`
+}
+
+// maxEventSubCost is the maximum total cost of all EventSub subscriptions of a client.
+const maxEventSubCost = 10000
+
+// EventSubSubscriptions returns all EventSub subscriptions which have been created and not deleted yet.
+func (server *Server) EventSubSubscriptions() []EventSubSubscription {
+	server.Lock()
+	defer server.Unlock()
+	subscriptions := make([]EventSubSubscription, 0, len(server.eventSubSubscriptions))
+	for _, subscription := range server.eventSubSubscriptions {
+		subscriptions = append(subscriptions, *subscription)
+	}
+	return subscriptions
+}
+
+// handleEventSubSubscriptions lists, creates and deletes EventSub subscriptions. Like the Twitch API it requires an
+// app access token.
+func (server *Server) handleEventSubSubscriptions(w http.ResponseWriter, r *http.Request, token *Token) {
+	if token.UserID != "" {
+		writeError(w, http.StatusUnauthorized, "App access token is required")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		server.listEventSubSubscriptions(w, r)
+	case http.MethodPost:
+		server.createEventSubSubscription(w, r)
+	case http.MethodDelete:
+		server.deleteEventSubSubscription(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func (server *Server) listEventSubSubscriptions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	subscriptions := make([]EventSubSubscription, 0, len(server.eventSubSubscriptions))
+	for _, subscription := range server.eventSubSubscriptions {
+		if status := query.Get("status"); status != "" && subscription.Status != status {
+			continue
+		}
+		if subscriptionType := query.Get("type"); subscriptionType != "" && subscription.Type != subscriptionType {
+			continue
+		}
+		subscriptions = append(subscriptions, *subscription)
+	}
+	writeJSON(w, http.StatusOK, server.eventSubResponse(subscriptions))
+}
+
+func (server *Server) createEventSubSubscription(w http.ResponseWriter, r *http.Request) {
+	var subscription EventSubSubscription
+	if err := json.NewDecoder(r.Body).Decode(&subscription); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if subscription.Type == "" || subscription.Version == "" || len(subscription.Condition) == 0 ||
+		subscription.Transport.Method != "webhook" || subscription.Transport.Callback == "" {
+		writeError(w, http.StatusBadRequest, "type, version, condition and webhook transport are required")
+		return
+	}
+	for _, existing := range server.eventSubSubscriptions {
+		if existing.Type == subscription.Type && existing.Version == subscription.Version &&
+			reflect.DeepEqual(existing.Condition, subscription.Condition) {
+			writeError(w, http.StatusConflict, "subscription already exists")
+			return
+		}
+	}
+	subscription.ID = server.newID()
+	subscription.Status = EventSubStatusEnabled
+	subscription.CreatedAt = time.Now().UTC()
+	subscription.Cost = 1
+	subscription.Transport.Secret = ""
+	server.eventSubSubscriptions = append(server.eventSubSubscriptions, &subscription)
+	writeJSON(w, http.StatusAccepted, server.eventSubResponse([]EventSubSubscription{subscription}))
+}
+
+func (server *Server) deleteEventSubSubscription(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	for i, subscription := range server.eventSubSubscriptions {
+		if subscription.ID == id {
+			server.eventSubSubscriptions = append(server.eventSubSubscriptions[:i], server.eventSubSubscriptions[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "subscription not found")
+}
+
+func (server *Server) eventSubResponse(subscriptions []EventSubSubscription) manyEventSubSubscriptions {
+	totalCost := 0
+	for _, subscription := range server.eventSubSubscriptions {
+		totalCost += subscription.Cost
+	}
+	return manyEventSubSubscriptions{
+		Subscriptions: subscriptions,
+		Total:         len(server.eventSubSubscriptions),
+		TotalCost:     totalCost,
+		MaxTotalCost:  maxEventSubCost,
+	}
+}
@@ -0,0 +1,520 @@
+// Package helixtest provides an in-process fake of the Twitch Helix and OAuth API which can be used by tests to drive
+// the real helix client through scenarios such as streams going live, API outages and exhausted rate limits.
+package helixtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/nicklaw5/helix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultClientID     = "helixtest-client-id"
+	DefaultClientSecret = "helixtest-client-secret"
+	// DefaultRateLimit is the amount of requests which are allowed per rate limit window.
+	DefaultRateLimit = 800
+	// DefaultRateLimitWindow is the duration after which the request budget is refilled.
+	DefaultRateLimitWindow = time.Minute
+	// defaultPageSize and maxPageSize are the default and maximum values of the "first" query parameter.
+	defaultPageSize = 20
+	maxPageSize     = 100
+	// maxQueryValues is the maximum amount of ids or logins which can be passed to a single request.
+	maxQueryValues = 100
+)
+
+// Server is a fake Twitch API. Both the Helix API and the OAuth endpoints are served on the same address, requests of
+// the client returned by HTTPClient are routed to it regardless of their host.
+type Server struct {
+	*sync.Mutex
+	// URL is the base URL of the server without a trailing slash.
+	URL          string
+	ClientID     string
+	ClientSecret string
+	server       *httptest.Server
+	users        []*helix.User
+	games        []*helix.Game
+	// twitch user id: live stream
+	streams map[string]*helix.Stream
+	// twitch user id: whether the streams of the user are flagged as mature
+	mature map[string]bool
+	// broadcaster user id: subscriptions
+	subscriptions map[string][]helix.Subscription
+	// access token: token
+	tokens                map[string]*Token
+	eventSubSubscriptions []*EventSubSubscription
+	// failures contains the status codes the next Helix API requests are answered with.
+	failures  []int
+	rateLimit rateLimit
+	// request path: amount of requests
+	requests map[string]int
+	nextID   int
+}
+
+type rateLimit struct {
+	limit     int
+	remaining int
+	window    time.Duration
+	reset     time.Time
+}
+
+// NewServer starts a fake Twitch API with the default client credentials. It has to be closed after the test.
+func NewServer() *Server {
+	server := &Server{
+		Mutex:         &sync.Mutex{},
+		ClientID:      DefaultClientID,
+		ClientSecret:  DefaultClientSecret,
+		streams:       make(map[string]*helix.Stream),
+		mature:        make(map[string]bool),
+		subscriptions: make(map[string][]helix.Subscription),
+		tokens:        make(map[string]*Token),
+		rateLimit:     rateLimit{limit: DefaultRateLimit, remaining: DefaultRateLimit, window: DefaultRateLimitWindow},
+		requests:      make(map[string]int),
+		nextID:        1000,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth2/token", server.handleToken)
+	mux.HandleFunc("/oauth2/validate", server.handleValidate)
+	mux.HandleFunc("/oauth2/revoke", server.handleRevoke)
+	mux.Handle("/helix/streams", server.helix(server.handleStreams))
+	mux.Handle("/helix/users", server.helix(server.handleUsers))
+	mux.Handle("/helix/games", server.helix(server.handleGames))
+	mux.Handle("/helix/subscriptions", server.helix(server.handleSubscriptions))
+	mux.Handle("/helix/eventsub/subscriptions", server.helix(server.handleEventSubSubscriptions))
+	server.server = httptest.NewServer(server.count(mux))
+	server.URL = server.server.URL
+	return server
+}
+
+func (server *Server) Close() {
+	server.server.Close()
+}
+
+// HTTPClient returns a HTTP client which sends all requests to the fake server. This includes the OAuth requests the
+// helix client sends to the hard coded Twitch authentication URL.
+func (server *Server) HTTPClient() *http.Client {
+	target, _ := url.Parse(server.URL)
+	return &http.Client{Transport: &rewriteTransport{target: target}}
+}
+
+// Client returns a helix client which is authenticated with the given app access token and sends all requests to the
+// fake server.
+func (server *Server) Client(appAccessToken string) (*helix.Client, error) {
+	return helix.NewClient(&helix.Options{
+		ClientID:       server.ClientID,
+		ClientSecret:   server.ClientSecret,
+		AppAccessToken: appAccessToken,
+		HTTPClient:     server.HTTPClient(),
+	})
+}
+
+// AddUser adds a user with the given login if it does not exist yet and returns it.
+func (server *Server) AddUser(login string) helix.User {
+	server.Lock()
+	defer server.Unlock()
+	return *server.addUser(login)
+}
+
+func (server *Server) addUser(login string) *helix.User {
+	if user := server.findUser(login); user != nil {
+		return user
+	}
+	user := &helix.User{ID: server.newID(), Login: strings.ToLower(login), DisplayName: login}
+	server.users = append(server.users, user)
+	return user
+}
+
+func (server *Server) findUser(login string) *helix.User {
+	for _, user := range server.users {
+		if strings.EqualFold(user.Login, login) {
+			return user
+		}
+	}
+	return nil
+}
+
+func (server *Server) findUserByID(id string) *helix.User {
+	for _, user := range server.users {
+		if user.ID == id {
+			return user
+		}
+	}
+	return nil
+}
+
+// AddGame adds a game with the given name and returns it. Its id can be used as game id of streams.
+func (server *Server) AddGame(name string) helix.Game {
+	server.Lock()
+	defer server.Unlock()
+	game := &helix.Game{ID: server.newID(), Name: name}
+	server.games = append(server.games, game)
+	return *game
+}
+
+func (server *Server) newID() string {
+	server.nextID++
+	return strconv.Itoa(server.nextID)
+}
+
+// SetLive starts or updates the stream of the user with the given login. The user is added if it does not exist yet.
+// The user and stream related fields are filled in by the server. The type defaults to "live" and can be set to e.g.
+// "rerun" to simulate a rerun.
+func (server *Server) SetLive(login string, stream helix.Stream) {
+	server.Lock()
+	defer server.Unlock()
+	user := server.addUser(login)
+	if current, ok := server.streams[user.ID]; ok {
+		stream.ID = current.ID
+		if stream.StartedAt.IsZero() {
+			stream.StartedAt = current.StartedAt
+		}
+	} else {
+		stream.ID = server.newID()
+	}
+	if stream.StartedAt.IsZero() {
+		stream.StartedAt = time.Now().UTC().Truncate(time.Second)
+	}
+	stream.UserID = user.ID
+	stream.UserName = user.DisplayName
+	if stream.Type == "" {
+		stream.Type = "live"
+	}
+	server.streams[user.ID] = &stream
+}
+
+// SetMature flags the streams of the user with the given login as intended for mature audiences. The user is added if
+// it does not exist yet.
+func (server *Server) SetMature(login string, mature bool) {
+	server.Lock()
+	defer server.Unlock()
+	server.mature[server.addUser(login).ID] = mature
+}
+
+// SetOffline ends the stream of the user with the given login.
+func (server *Server) SetOffline(login string) {
+	server.Lock()
+	defer server.Unlock()
+	if user := server.findUser(login); user != nil {
+		delete(server.streams, user.ID)
+	}
+}
+
+// SetSubscription subscribes the user to the broadcaster with the given tier ("1000", "2000" or "3000"). An empty tier
+// removes the subscription.
+func (server *Server) SetSubscription(broadcasterLogin, userLogin, tier string) {
+	server.Lock()
+	defer server.Unlock()
+	broadcaster := server.addUser(broadcasterLogin)
+	user := server.addUser(userLogin)
+	subscriptions := server.subscriptions[broadcaster.ID][:0]
+	for _, subscription := range server.subscriptions[broadcaster.ID] {
+		if subscription.UserID != user.ID {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	if tier != "" {
+		subscriptions = append(subscriptions, helix.Subscription{
+			BroadcasterID:   broadcaster.ID,
+			BroadcasterName: broadcaster.DisplayName,
+			Tier:            tier,
+			UserID:          user.ID,
+			UserName:        user.DisplayName,
+		})
+	}
+	server.subscriptions[broadcaster.ID] = subscriptions
+}
+
+// FailNext answers the next Helix API requests with the given status code. Each call adds the given amount of
+// failures to the ones which are still pending.
+func (server *Server) FailNext(statusCode, times int) {
+	server.Lock()
+	defer server.Unlock()
+	for i := 0; i < times; i++ {
+		server.failures = append(server.failures, statusCode)
+	}
+}
+
+// SetRateLimit replaces the request budget of the Helix API and starts a new rate limit window.
+func (server *Server) SetRateLimit(limit int, window time.Duration) {
+	server.Lock()
+	defer server.Unlock()
+	server.rateLimit = rateLimit{limit: limit, remaining: limit, window: window, reset: time.Now().Add(window)}
+}
+
+// Requests returns the amount of requests which have been sent to the given path such as "/helix/streams".
+func (server *Server) Requests(path string) int {
+	server.Lock()
+	defer server.Unlock()
+	return server.requests[path]
+}
+
+// count counts the requests of each path.
+func (server *Server) count(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.Lock()
+		server.requests[r.URL.Path]++
+		server.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// helix authenticates Helix API requests, applies the rate limit and answers with the pending failures before the
+// handler is called. The handler is called while the server is locked.
+func (server *Server) helix(handler func(w http.ResponseWriter, r *http.Request, token *Token)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.Lock()
+		defer server.Unlock()
+		token, ok := server.authenticate(r)
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "Invalid OAuth token")
+			return
+		}
+		if !server.consumeRateLimit(w) {
+			writeError(w, http.StatusTooManyRequests, "Too Many Requests")
+			return
+		}
+		if len(server.failures) > 0 {
+			statusCode := server.failures[0]
+			server.failures = server.failures[1:]
+			writeError(w, statusCode, http.StatusText(statusCode))
+			return
+		}
+		handler(w, r, token)
+	})
+}
+
+func (server *Server) authenticate(r *http.Request) (*Token, bool) {
+	if r.Header.Get("Client-Id") != server.ClientID {
+		return nil, false
+	}
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return nil, false
+	}
+	return server.validToken(strings.TrimPrefix(authorization, "Bearer "))
+}
+
+// consumeRateLimit sets the rate limit headers and returns false if the budget of the current window is exhausted.
+func (server *Server) consumeRateLimit(w http.ResponseWriter) bool {
+	now := time.Now()
+	if !now.Before(server.rateLimit.reset) {
+		server.rateLimit.remaining = server.rateLimit.limit
+		server.rateLimit.reset = now.Add(server.rateLimit.window)
+	}
+	allowed := server.rateLimit.remaining > 0
+	if allowed {
+		server.rateLimit.remaining--
+	}
+	w.Header().Set("Ratelimit-Limit", strconv.Itoa(server.rateLimit.limit))
+	w.Header().Set("Ratelimit-Remaining", strconv.Itoa(server.rateLimit.remaining))
+	w.Header().Set("Ratelimit-Reset", strconv.FormatInt(server.rateLimit.reset.Unix(), 10))
+	return allowed
+}
+
+func (server *Server) handleStreams(w http.ResponseWriter, r *http.Request, _ *Token) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	query := r.URL.Query()
+	logins, ids := query["user_login"], query["user_id"]
+	if len(logins) > maxQueryValues || len(ids) > maxQueryValues {
+		writeError(w, http.StatusBadRequest, "too many user_login or user_id values")
+		return
+	}
+	streamType := query.Get("type")
+	if streamType != "" && streamType != "all" && streamType != "live" {
+		writeError(w, http.StatusBadRequest, "invalid type")
+		return
+	}
+	streams := make([]matureStream, 0)
+	for _, user := range server.users {
+		stream, ok := server.streams[user.ID]
+		if !ok {
+			continue
+		}
+		if (len(logins) > 0 || len(ids) > 0) && !containsFold(logins, user.Login) && !containsFold(ids, user.ID) {
+			continue
+		}
+		if streamType == "live" && stream.Type != "live" {
+			continue
+		}
+		streams = append(streams, matureStream{Stream: *stream, IsMature: server.mature[user.ID]})
+	}
+	start, end, cursor, ok := paginate(w, query, len(streams))
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, manyStreams{
+		Streams:    streams[start:end],
+		Pagination: helix.Pagination{Cursor: cursor},
+	})
+}
+
+// matureStream adds the mature flag to helix.Stream, which does not contain it in the helix version in use.
+type matureStream struct {
+	helix.Stream
+	IsMature bool `json:"is_mature"`
+}
+
+type manyStreams struct {
+	Streams    []matureStream   `json:"data"`
+	Pagination helix.Pagination `json:"pagination"`
+}
+
+func (server *Server) handleUsers(w http.ResponseWriter, r *http.Request, token *Token) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	query := r.URL.Query()
+	logins, ids := query["login"], query["id"]
+	if len(logins)+len(ids) > maxQueryValues {
+		writeError(w, http.StatusBadRequest, "too many login or id values")
+		return
+	}
+	users := make([]helix.User, 0)
+	if len(logins) == 0 && len(ids) == 0 {
+		// without parameters the user of the access token is returned
+		if user := server.findUserByID(token.UserID); user != nil {
+			users = append(users, *user)
+		}
+	}
+	for _, user := range server.users {
+		if containsFold(logins, user.Login) || containsFold(ids, user.ID) {
+			users = append(users, *user)
+		}
+	}
+	writeJSON(w, http.StatusOK, helix.ManyUsers{Users: users})
+}
+
+func (server *Server) handleGames(w http.ResponseWriter, r *http.Request, _ *Token) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	query := r.URL.Query()
+	ids, names := query["id"], query["name"]
+	if len(ids)+len(names) > maxQueryValues {
+		writeError(w, http.StatusBadRequest, "too many id or name values")
+		return
+	}
+	games := make([]helix.Game, 0)
+	for _, game := range server.games {
+		if containsFold(ids, game.ID) || containsFold(names, game.Name) {
+			games = append(games, *game)
+		}
+	}
+	writeJSON(w, http.StatusOK, helix.ManyGames{Games: games})
+}
+
+func (server *Server) handleSubscriptions(w http.ResponseWriter, r *http.Request, token *Token) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	query := r.URL.Query()
+	broadcasterID := query.Get("broadcaster_id")
+	if token.UserID == "" || token.UserID != broadcasterID || !token.HasScope("channel:read:subscriptions") {
+		writeError(w, http.StatusUnauthorized, "Missing scope: channel:read:subscriptions")
+		return
+	}
+	userIDs := query["user_id"]
+	if len(userIDs) > maxQueryValues {
+		writeError(w, http.StatusBadRequest, "too many user_id values")
+		return
+	}
+	subscriptions := make([]helix.Subscription, 0)
+	for _, subscription := range server.subscriptions[broadcasterID] {
+		if len(userIDs) == 0 || containsFold(userIDs, subscription.UserID) {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	start, end, cursor, ok := paginate(w, query, len(subscriptions))
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, helix.ManySubscriptions{
+		Subscriptions: subscriptions[start:end],
+		Pagination:    helix.Pagination{Cursor: cursor},
+	})
+}
+
+// paginate returns the range of the requested page and the cursor of the next page, which is empty if it is the last
+// page. It answers with a bad request and returns false if the pagination parameters are invalid.
+func paginate(w http.ResponseWriter, query url.Values, total int) (start, end int, cursor string, ok bool) {
+	pageSize := defaultPageSize
+	if first := query.Get("first"); first != "" {
+		var err error
+		if pageSize, err = strconv.Atoi(first); err != nil || pageSize < 1 || pageSize > maxPageSize {
+			writeError(w, http.StatusBadRequest, "invalid first value")
+			return 0, 0, "", false
+		}
+	}
+	if after := query.Get("after"); after != "" {
+		var err error
+		if start, err = decodeCursor(after); err != nil || start > total {
+			writeError(w, http.StatusBadRequest, "invalid after cursor")
+			return 0, 0, "", false
+		}
+	}
+	end = start + pageSize
+	if end >= total {
+		return start, total, "", true
+	}
+	return start, end, encodeCursor(end), true
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(data))
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	writeJSON(w, statusCode, map[string]interface{}{
+		"error":   http.StatusText(statusCode),
+		"status":  statusCode,
+		"message": message,
+	})
+}
+
+// rewriteTransport sends all requests to the target host.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (transport *rewriteTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	request := r.Clone(r.Context())
+	request.URL.Scheme = transport.target.Scheme
+	request.URL.Host = transport.target.Host
+	request.Host = transport.target.Host
+	return http.DefaultTransport.RoundTrip(request)
+}
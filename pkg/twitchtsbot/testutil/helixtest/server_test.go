@@ -0,0 +1,229 @@
+package helixtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/nicklaw5/helix"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T) (*Server, *helix.Client) {
+	server := NewServer()
+	t.Cleanup(server.Close)
+	client, err := server.Client(server.IssueAppToken())
+	assert.NoError(t, err)
+	return server, client
+}
+
+func TestServer_Streams(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetLive("streamer", helix.Stream{Title: "title", GameID: "42", ViewerCount: 7})
+	server.AddUser("offline")
+	resp, err := client.GetStreams(&helix.StreamsParams{UserLogins: []string{"streamer", "offline"}, Type: "live"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	if assert.Len(t, resp.Data.Streams, 1) {
+		stream := resp.Data.Streams[0]
+		assert.Equal(t, "streamer", stream.UserName)
+		assert.Equal(t, "title", stream.Title)
+		assert.Equal(t, "live", stream.Type)
+		assert.False(t, stream.StartedAt.IsZero(), "expected the start of the stream to be set")
+	}
+	server.SetLive("streamer", helix.Stream{Type: "rerun"})
+	resp, err = client.GetStreams(&helix.StreamsParams{UserLogins: []string{"streamer"}, Type: "live"})
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Data.Streams, "expected reruns to not be returned for the live type")
+	resp, err = client.GetStreams(&helix.StreamsParams{UserLogins: []string{"streamer"}, Type: "all"})
+	assert.NoError(t, err)
+	if assert.Len(t, resp.Data.Streams, 1) {
+		assert.Equal(t, "rerun", resp.Data.Streams[0].Type)
+	}
+	server.SetOffline("streamer")
+	resp, err = client.GetStreams(&helix.StreamsParams{UserLogins: []string{"streamer"}})
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Data.Streams)
+	assert.Equal(t, 4, server.Requests("/helix/streams"))
+}
+
+func TestServer_StreamsMature(t *testing.T) {
+	server, _ := newTestClient(t)
+	server.SetLive("streamer", helix.Stream{})
+	server.SetMature("streamer", true)
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/helix/streams?user_login=streamer", nil)
+	assert.NoError(t, err)
+	request.Header.Set("Client-Id", server.ClientID)
+	request.Header.Set("Authorization", "Bearer "+server.IssueAppToken())
+	response, err := server.HTTPClient().Do(request)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer response.Body.Close()
+	var streams struct {
+		Data []struct {
+			IsMature bool `json:"is_mature"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&streams))
+	if assert.Len(t, streams.Data, 1) {
+		assert.True(t, streams.Data[0].IsMature, "expected the stream to be flagged as mature")
+	}
+}
+
+func TestServer_StreamsPagination(t *testing.T) {
+	server, client := newTestClient(t)
+	logins := make([]string, 0, defaultPageSize+5)
+	for i := 0; i < defaultPageSize+5; i++ {
+		login := "streamer" + strconv.Itoa(i)
+		logins = append(logins, login)
+		server.SetLive(login, helix.Stream{})
+	}
+	resp, err := client.GetStreams(&helix.StreamsParams{UserLogins: logins})
+	assert.NoError(t, err)
+	assert.Len(t, resp.Data.Streams, defaultPageSize)
+	assert.NotEmpty(t, resp.Data.Pagination.Cursor, "expected a cursor for the next page")
+	resp, err = client.GetStreams(&helix.StreamsParams{UserLogins: logins, After: resp.Data.Pagination.Cursor})
+	assert.NoError(t, err)
+	assert.Len(t, resp.Data.Streams, 5)
+	assert.Empty(t, resp.Data.Pagination.Cursor, "expected no cursor on the last page")
+	resp, err = client.GetStreams(&helix.StreamsParams{UserLogins: logins, First: maxPageSize})
+	assert.NoError(t, err)
+	assert.Len(t, resp.Data.Streams, len(logins))
+}
+
+func TestServer_Users(t *testing.T) {
+	server, client := newTestClient(t)
+	user := server.AddUser("Streamer")
+	resp, err := client.GetUsers(&helix.UsersParams{Logins: []string{"streamer", "unknown"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []helix.User{user}, resp.Data.Users)
+	assert.Equal(t, "streamer", user.Login)
+	assert.Equal(t, "Streamer", user.DisplayName)
+}
+
+func TestServer_Games(t *testing.T) {
+	server, client := newTestClient(t)
+	game := server.AddGame("Just Chatting")
+	resp, err := client.GetGames(&helix.GamesParams{IDs: []string{game.ID, "0"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []helix.Game{game}, resp.Data.Games)
+}
+
+func TestServer_Tokens(t *testing.T) {
+	server, client := newTestClient(t)
+	resp, err := client.RequestAppAccessToken([]string{"user:read:email"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	valid, validateResp, err := client.ValidateToken(resp.Data.AccessToken)
+	assert.NoError(t, err)
+	assert.True(t, valid, "expected issued token to be valid")
+	assert.Equal(t, server.ClientID, validateResp.Data.ClientID)
+	userToken := server.IssueUserToken("broadcaster", "channel:read:subscriptions")
+	_, validateResp, err = client.ValidateToken(userToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "broadcaster", validateResp.Data.Login)
+	assert.Equal(t, []string{"channel:read:subscriptions"}, validateResp.Data.Scopes)
+	server.ExpireToken(userToken)
+	valid, _, err = client.ValidateToken(userToken)
+	assert.NoError(t, err)
+	assert.False(t, valid, "expected expired token to be invalid")
+	client.SetAppAccessToken("invalid")
+	streamsResp, err := client.GetStreams(&helix.StreamsParams{})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, streamsResp.StatusCode)
+}
+
+func TestServer_Subscriptions(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.SetSubscription("broadcaster", "viewer", "2000")
+	viewer := server.AddUser("viewer")
+	broadcaster := server.AddUser("broadcaster")
+	client, err := server.Client(server.IssueAppToken())
+	assert.NoError(t, err)
+	params := &helix.SubscriptionsParams{BroadcasterID: broadcaster.ID, UserID: []string{viewer.ID}}
+	resp, err := client.GetSubscriptions(params)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "expected app access token to be rejected")
+	client.SetUserAccessToken(server.IssueUserToken("broadcaster", "channel:read:subscriptions"))
+	resp, err = client.GetSubscriptions(params)
+	assert.NoError(t, err)
+	if assert.Len(t, resp.Data.Subscriptions, 1) {
+		assert.Equal(t, "2000", resp.Data.Subscriptions[0].Tier)
+	}
+	server.SetSubscription("broadcaster", "viewer", "")
+	resp, err = client.GetSubscriptions(params)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Data.Subscriptions)
+}
+
+func TestServer_FailNext(t *testing.T) {
+	server, client := newTestClient(t)
+	server.FailNext(http.StatusInternalServerError, 2)
+	for _, statusCode := range []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK} {
+		resp, err := client.GetStreams(&helix.StreamsParams{})
+		assert.NoError(t, err)
+		assert.Equal(t, statusCode, resp.StatusCode)
+	}
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	server, client := newTestClient(t)
+	server.SetRateLimit(2, time.Hour)
+	resp, err := client.GetStreams(&helix.StreamsParams{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, resp.GetRateLimit())
+	assert.Equal(t, 1, resp.GetRateLimitRemaining())
+	assert.InDelta(t, time.Now().Add(time.Hour).Unix(), resp.GetRateLimitReset(), 5)
+	_, _ = client.GetStreams(&helix.StreamsParams{})
+	resp, err = client.GetStreams(&helix.StreamsParams{})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, 0, resp.GetRateLimitRemaining())
+	server.SetRateLimit(2, time.Hour)
+	resp, err = client.GetStreams(&helix.StreamsParams{})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "expected a new rate limit window")
+}
+
+func TestServer_EventSubSubscriptions(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	token := server.IssueAppToken()
+	send := func(method, query string, body interface{}) *http.Response {
+		data, _ := json.Marshal(body)
+		request, err := http.NewRequest(method, server.URL+"/helix/eventsub/subscriptions"+query, bytes.NewReader(data))
+		assert.NoError(t, err)
+		request.Header.Set("Client-Id", server.ClientID)
+		request.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+		return resp
+	}
+	subscription := EventSubSubscription{
+		Type:      "stream.online",
+		Version:   "1",
+		Condition: map[string]string{"broadcaster_user_id": "1"},
+		Transport: EventSubTransport{Method: "webhook", Callback: "https://example.com/callback", Secret: "secret"},
+	}
+	resp := send(http.MethodPost, "", subscription)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var created manyEventSubSubscriptions
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	_ = resp.Body.Close()
+	if assert.Len(t, created.Subscriptions, 1) {
+		assert.Equal(t, EventSubStatusEnabled, created.Subscriptions[0].Status)
+		assert.Empty(t, created.Subscriptions[0].Transport.Secret, "expected the secret to not be returned")
+	}
+	assert.Equal(t, http.StatusConflict, send(http.MethodPost, "", subscription).StatusCode)
+	assert.Len(t, server.EventSubSubscriptions(), 1)
+	resp = send(http.MethodDelete, "?id="+created.Subscriptions[0].ID, nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Empty(t, server.EventSubSubscriptions())
+	token = server.IssueUserToken("broadcaster")
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "", nil).StatusCode,
+		"expected user access token to be rejected")
+}
@@ -0,0 +1,173 @@
+package ts3test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const queryWelcome = "TS3\n\rWelcome to the TeamSpeak 3 ServerQuery interface, type \"help\" for a list of commands " +
+	"and \"help <command>\" for information on a specific command.\n\r"
+
+// queryConnection is a ServerQuery connection which receives the server events after it has logged in and registered
+// for them.
+type queryConnection struct {
+	conn       net.Conn
+	writeMutex sync.Mutex
+	loggedIn   bool
+	registered bool
+}
+
+func (connection *queryConnection) write(line string) {
+	connection.writeMutex.Lock()
+	defer connection.writeMutex.Unlock()
+	_, _ = connection.conn.Write([]byte(line + "\n\r"))
+}
+
+func (connection *queryConnection) writeError(id int) {
+	connection.write(fmt.Sprintf("error id=%d msg=%s", id, escape(errorMessages[id])))
+}
+
+func (server *Server) acceptQueryConnections() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+		connection := &queryConnection{conn: conn}
+		server.Lock()
+		server.connections[connection] = true
+		server.Unlock()
+		go server.handleQueryConnection(connection)
+	}
+}
+
+// handleQueryConnection answers the commands of a ServerQuery connection. Only the commands which are needed to receive
+// events are supported.
+func (server *Server) handleQueryConnection(connection *queryConnection) {
+	defer func() {
+		server.Lock()
+		delete(server.connections, connection)
+		server.Unlock()
+		_ = connection.conn.Close()
+	}()
+	connection.write(strings.TrimSuffix(queryWelcome, "\n\r"))
+	scanner := bufio.NewScanner(connection.conn)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			// keep alive
+			continue
+		}
+		server.Lock()
+		switch {
+		case fields[0] == "login" && len(fields) == 3:
+			connection.loggedIn = fields[1] == server.QueryUser && fields[2] == server.QueryPassword
+			if connection.loggedIn {
+				connection.writeError(ErrorOK)
+			} else {
+				connection.write("error id=520 msg=invalid\\sloginname\\sor\\spassword")
+			}
+		case !connection.loggedIn:
+			connection.write("error id=2568 msg=insufficient\\sclient\\spermissions")
+		case fields[0] == "use":
+			if len(fields) == 2 && fields[1] == strconv.Itoa(DefaultServerID) {
+				connection.writeError(ErrorOK)
+			} else {
+				connection.writeError(ErrorInvalidServerID)
+			}
+		case fields[0] == "servernotifyregister":
+			connection.registered = connection.registered || containsString(fields[1:], "event=server")
+			connection.writeError(ErrorOK)
+		default:
+			connection.writeError(ErrorCommandNotFound)
+		}
+		server.Unlock()
+	}
+}
+
+// EventListeners returns the amount of ServerQuery connections which receive the client enter and leave events.
+func (server *Server) EventListeners() int {
+	server.Lock()
+	defer server.Unlock()
+	listeners := 0
+	for connection := range server.connections {
+		if connection.registered {
+			listeners++
+		}
+	}
+	return listeners
+}
+
+// Connect brings the client online and emits a client enter event. It returns false if the client does not exist or is
+// already online.
+func (server *Server) Connect(databaseID int) bool {
+	server.Lock()
+	defer server.Unlock()
+	client := server.findClient(databaseID)
+	if client == nil || client.ClientID != 0 {
+		return false
+	}
+	server.nextClient++
+	client.ClientID = server.nextClient
+	client.ChannelID = DefaultChannelID
+	parameters := []string{
+		"cfid=0",
+		"ctid=" + strconv.Itoa(client.ChannelID),
+		"reasonid=0",
+		"clid=" + strconv.Itoa(client.ClientID),
+		"client_unique_identifier=" + escape(client.UniqueIdentifier),
+		"client_nickname=" + escape(client.Nickname),
+		"client_database_id=" + strconv.Itoa(client.DatabaseID),
+		"client_type=0",
+	}
+	if serverGroups := server.serverGroupsOf(databaseID); len(serverGroups) > 0 {
+		ids := make([]string, 0, len(serverGroups))
+		for _, id := range serverGroups {
+			ids = append(ids, strconv.Itoa(id))
+		}
+		parameters = append(parameters, "client_servergroups="+strings.Join(ids, ","))
+	}
+	server.emit("notifycliententerview " + strings.Join(parameters, " "))
+	return true
+}
+
+// Disconnect brings the client offline and emits a client leave event. It returns false if the client is not online.
+func (server *Server) Disconnect(databaseID int) bool {
+	server.Lock()
+	defer server.Unlock()
+	client := server.findClient(databaseID)
+	if client == nil || client.ClientID == 0 {
+		return false
+	}
+	server.emit(fmt.Sprintf("notifyclientleftview cfid=1 ctid=0 reasonid=8 reasonmsg=leaving clid=%d", client.ClientID))
+	client.ClientID = 0
+	return true
+}
+
+func (server *Server) emit(event string) {
+	for connection := range server.connections {
+		if connection.registered {
+			connection.write(event)
+		}
+	}
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `/`, `\/`, ` `, `\s`, `|`, `\p`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// escape escapes a parameter value as defined by the ServerQuery protocol.
+func escape(value string) string {
+	return escaper.Replace(value)
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,646 @@
+// Package ts3test provides an in-memory fake of a TeamSpeak 3 virtual server. It serves the WebQuery HTTP API used by
+// the go-ts3 client and a ServerQuery endpoint which emits the client enter and leave events.
+package ts3test
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultAPIKey        = "ts3test-api-key"
+	DefaultQueryUser     = "serveradmin"
+	DefaultQueryPassword = "ts3test-password"
+	// DefaultServerID is the id of the single virtual server.
+	DefaultServerID = 1
+	// DefaultChannelID is the id of the channel clients join when they connect.
+	DefaultChannelID = 1
+	// DefaultMemberPower is the value of the group member add and remove powers of the query user.
+	DefaultMemberPower = 100
+)
+
+// TeamSpeak error ids which are returned by the fake server.
+const (
+	ErrorOK                  = 0
+	ErrorCommandNotFound     = 256
+	ErrorInvalidClientID     = 512
+	ErrorInvalidChannelID    = 768
+	ErrorInvalidServerID     = 1024
+	ErrorDatabaseEmptyResult = 1281
+	ErrorInvalidGroupID      = 2560
+	ErrorDuplicateEntry      = 2561
+	ErrorInvalidAPIKey       = 5122
+	// ErrorFailure is returned for the failures which are injected by FailNext.
+	ErrorFailure = 1
+)
+
+var errorMessages = map[int]string{
+	ErrorOK:                  "ok",
+	ErrorCommandNotFound:     "command not found",
+	ErrorInvalidClientID:     "invalid clientID",
+	ErrorInvalidChannelID:    "invalid channelID",
+	ErrorInvalidServerID:     "invalid serverID",
+	ErrorDatabaseEmptyResult: "database empty result set",
+	ErrorInvalidGroupID:      "invalid group ID",
+	ErrorDuplicateEntry:      "duplicate entry",
+	ErrorInvalidAPIKey:       "invalid apikey",
+	ErrorFailure:             "injected failure",
+}
+
+// databaseClient is a client which is known to the database of the virtual server.
+type databaseClient struct {
+	DatabaseID       int
+	UniqueIdentifier string
+	Nickname         string
+	Description      string
+	// ClientID is the id of the current connection and zero while the client is offline.
+	ClientID int
+	// ChannelID is the channel of the current connection.
+	ChannelID int
+	// permission name: value
+	Permissions map[string]int
+}
+
+// TextMessage is a private text message which has been sent to a connected client.
+type TextMessage struct {
+	ClientID int
+	Text     string
+}
+
+type serverGroup struct {
+	ID   int
+	Name string
+}
+
+// Server is a fake TeamSpeak 3 virtual server.
+type Server struct {
+	*sync.Mutex
+	// URL is the WebQuery base URL which has to be passed to the go-ts3 client config.
+	URL string
+	// QueryAddress is the host and port of the ServerQuery endpoint which emits the events.
+	QueryAddress  string
+	APIKey        string
+	QueryUser     string
+	QueryPassword string
+	httpServer    *httptest.Server
+	listener      net.Listener
+	clients       []*databaseClient
+	serverGroups  []*serverGroup
+	// server group id: client database id: member
+	members  map[int]map[int]bool
+	channels map[int]bool
+	// channel id: client database id: channel group id
+	channelGroups map[int]map[int]int
+	messages      []TextMessage
+	// permission name: value of the query user
+	queryPermissions map[string]int
+	// command: amount of pending failures
+	failures map[string]int
+	// command: amount of requests
+	requests    map[string]int
+	connections map[*queryConnection]bool
+	nextID      int
+	nextClient  int
+}
+
+// NewServer starts a fake TeamSpeak server with the default credentials. It has to be closed after the test.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	server := &Server{
+		Mutex:         &sync.Mutex{},
+		QueryAddress:  listener.Addr().String(),
+		APIKey:        DefaultAPIKey,
+		QueryUser:     DefaultQueryUser,
+		QueryPassword: DefaultQueryPassword,
+		listener:      listener,
+		members:       make(map[int]map[int]bool),
+		channels:      map[int]bool{DefaultChannelID: true},
+		channelGroups: make(map[int]map[int]int),
+		queryPermissions: map[string]int{
+			"i_group_member_add_power":    DefaultMemberPower,
+			"i_group_member_remove_power": DefaultMemberPower,
+		},
+		failures:    make(map[string]int),
+		requests:    make(map[string]int),
+		connections: make(map[*queryConnection]bool),
+		nextID:      1,
+	}
+	server.httpServer = httptest.NewServer(http.HandlerFunc(server.handleWebQuery))
+	server.URL = server.httpServer.URL
+	go server.acceptQueryConnections()
+	return server, nil
+}
+
+func (server *Server) Close() {
+	server.httpServer.Close()
+	_ = server.listener.Close()
+	server.Lock()
+	defer server.Unlock()
+	for connection := range server.connections {
+		_ = connection.conn.Close()
+	}
+}
+
+// AddServerGroup creates a server group and returns its id.
+func (server *Server) AddServerGroup(name string) int {
+	server.Lock()
+	defer server.Unlock()
+	server.nextID++
+	server.serverGroups = append(server.serverGroups, &serverGroup{ID: server.nextID, Name: name})
+	server.members[server.nextID] = make(map[int]bool)
+	return server.nextID
+}
+
+// AddClient adds an offline client to the database and returns its database id.
+func (server *Server) AddClient(uniqueIdentifier, nickname string) int {
+	server.Lock()
+	defer server.Unlock()
+	server.nextID++
+	server.clients = append(server.clients, &databaseClient{
+		DatabaseID:       server.nextID,
+		UniqueIdentifier: uniqueIdentifier,
+		Nickname:         nickname,
+		Permissions:      make(map[string]int),
+	})
+	return server.nextID
+}
+
+// AddChannel creates a channel and returns its id.
+func (server *Server) AddChannel() int {
+	server.Lock()
+	defer server.Unlock()
+	server.nextID++
+	server.channels[server.nextID] = true
+	return server.nextID
+}
+
+// Channel returns the channel of the connection of the client, which is zero while the client is offline.
+func (server *Server) Channel(databaseID int) int {
+	server.Lock()
+	defer server.Unlock()
+	if client := server.findClient(databaseID); client != nil && client.ClientID != 0 {
+		return client.ChannelID
+	}
+	return 0
+}
+
+// SetChannel moves the connection of the client to the channel as if the client moved itself.
+func (server *Server) SetChannel(databaseID, channelID int) {
+	server.Lock()
+	defer server.Unlock()
+	if client := server.findClient(databaseID); client != nil && client.ClientID != 0 {
+		client.ChannelID = channelID
+	}
+}
+
+// ChannelGroup returns the channel group of the client in the channel, which is zero if none has been assigned.
+func (server *Server) ChannelGroup(channelID, databaseID int) int {
+	server.Lock()
+	defer server.Unlock()
+	return server.channelGroups[channelID][databaseID]
+}
+
+// Permission returns the value of the client permission and whether it has been granted.
+func (server *Server) Permission(databaseID int, name string) (int, bool) {
+	server.Lock()
+	defer server.Unlock()
+	if client := server.findClient(databaseID); client != nil {
+		value, ok := client.Permissions[name]
+		return value, ok
+	}
+	return 0, false
+}
+
+// Messages returns all private text messages which have been sent to clients.
+func (server *Server) Messages() []TextMessage {
+	server.Lock()
+	defer server.Unlock()
+	messages := make([]TextMessage, len(server.messages))
+	copy(messages, server.messages)
+	return messages
+}
+
+// Description returns the description of the client.
+func (server *Server) Description(databaseID int) string {
+	server.Lock()
+	defer server.Unlock()
+	if client := server.findClient(databaseID); client != nil {
+		return client.Description
+	}
+	return ""
+}
+
+// SetDescription changes the description of the client as if the client changed it.
+func (server *Server) SetDescription(databaseID int, description string) {
+	server.Lock()
+	defer server.Unlock()
+	if client := server.findClient(databaseID); client != nil {
+		client.Description = description
+	}
+}
+
+// Members returns the sorted database ids of all members of the server group.
+func (server *Server) Members(serverGroupID int) []int {
+	server.Lock()
+	defer server.Unlock()
+	members := make([]int, 0, len(server.members[serverGroupID]))
+	for databaseID := range server.members[serverGroupID] {
+		members = append(members, databaseID)
+	}
+	sort.Ints(members)
+	return members
+}
+
+// IsMember returns whether the client is a member of the server group.
+func (server *Server) IsMember(serverGroupID, databaseID int) bool {
+	server.Lock()
+	defer server.Unlock()
+	return server.members[serverGroupID][databaseID]
+}
+
+// SetMember changes the server group membership of the client as if an administrator changed it.
+func (server *Server) SetMember(serverGroupID, databaseID int, member bool) {
+	server.Lock()
+	defer server.Unlock()
+	if _, ok := server.members[serverGroupID]; !ok {
+		return
+	}
+	if member {
+		server.members[serverGroupID][databaseID] = true
+	} else {
+		delete(server.members[serverGroupID], databaseID)
+	}
+}
+
+// FailNext answers the next requests of the given WebQuery command such as "servergroupaddclient" with an error.
+func (server *Server) FailNext(command string, times int) {
+	server.Lock()
+	defer server.Unlock()
+	server.failures[command] += times
+}
+
+// Requests returns the amount of requests of the given WebQuery command.
+func (server *Server) Requests(command string) int {
+	server.Lock()
+	defer server.Unlock()
+	return server.requests[command]
+}
+
+func (server *Server) findClient(databaseID int) *databaseClient {
+	for _, client := range server.clients {
+		if client.DatabaseID == databaseID {
+			return client
+		}
+	}
+	return nil
+}
+
+func (server *Server) findServerGroup(serverGroupID int) *serverGroup {
+	for _, serverGroup := range server.serverGroups {
+		if serverGroup.ID == serverGroupID {
+			return serverGroup
+		}
+	}
+	return nil
+}
+
+// serverGroupsOf returns the ids of all server groups the client is a member of.
+func (server *Server) serverGroupsOf(databaseID int) []int {
+	serverGroups := make([]int, 0)
+	for _, serverGroup := range server.serverGroups {
+		if server.members[serverGroup.ID][databaseID] {
+			serverGroups = append(serverGroups, serverGroup.ID)
+		}
+	}
+	return serverGroups
+}
+
+// SetAPIKey replaces the API key which the WebQuery requests have to be sent with, e.g. to simulate a rotation.
+func (server *Server) SetAPIKey(apiKey string) {
+	server.Lock()
+	defer server.Unlock()
+	server.APIKey = apiKey
+}
+
+// handleWebQuery answers the WebQuery requests which have the form /<server id>/<command>?<parameters>.
+func (server *Server) handleWebQuery(w http.ResponseWriter, r *http.Request) {
+	server.Lock()
+	defer server.Unlock()
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	command := path[len(path)-1]
+	server.requests[command]++
+	if r.Header.Get("x-api-key") != server.APIKey {
+		writeResponse(w, ErrorInvalidAPIKey, nil)
+		return
+	}
+	if len(path) != 2 || path[0] != strconv.Itoa(DefaultServerID) {
+		writeResponse(w, ErrorInvalidServerID, nil)
+		return
+	}
+	if server.failures[command] > 0 {
+		server.failures[command]--
+		writeResponse(w, ErrorFailure, nil)
+		return
+	}
+	query := r.URL.Query()
+	switch command {
+	case "version":
+		writeResponse(w, ErrorOK, []map[string]string{{"build": "0", "platform": "Linux", "version": "3.13.0"}})
+	case "servergrouplist":
+		server.serverGroupList(w)
+	case "servergroupclientlist":
+		server.serverGroupClientList(w, query.Get("sgid"))
+	case "servergroupaddclient":
+		server.setServerGroup(w, query.Get("sgid"), query.Get("cldbid"), true)
+	case "servergroupdelclient":
+		server.setServerGroup(w, query.Get("sgid"), query.Get("cldbid"), false)
+	case "clientgetdbidfromuid":
+		server.clientGetDbIDFromUID(w, query.Get("cluid"))
+	case "clientdbinfo":
+		server.clientDbInfo(w, query.Get("cldbid"))
+	case "clientlist":
+		server.clientList(w)
+	case "clientedit":
+		server.clientEdit(w, query)
+	case "clientdbedit":
+		server.clientDbEdit(w, query)
+	case "clientmove":
+		server.clientMove(w, query)
+	case "setclientchannelgroup":
+		server.setClientChannelGroup(w, query)
+	case "clientaddperm":
+		server.clientAddPerm(w, query)
+	case "clientdelperm":
+		server.clientDelPerm(w, query)
+	case "sendtextmessage":
+		server.sendTextMessage(w, query)
+	case "permget":
+		server.permGet(w, query.Get("permsid"))
+	default:
+		writeResponse(w, ErrorCommandNotFound, nil)
+	}
+}
+
+func (server *Server) serverGroupList(w http.ResponseWriter) {
+	body := make([]map[string]string, 0, len(server.serverGroups))
+	for _, serverGroup := range server.serverGroups {
+		body = append(body, map[string]string{
+			"sgid": strconv.Itoa(serverGroup.ID),
+			"name": serverGroup.Name,
+			"type": "1",
+		})
+	}
+	writeResponse(w, ErrorOK, body)
+}
+
+func (server *Server) serverGroupClientList(w http.ResponseWriter, serverGroupID string) {
+	id, _ := strconv.Atoi(serverGroupID)
+	if server.findServerGroup(id) == nil {
+		writeResponse(w, ErrorInvalidGroupID, nil)
+		return
+	}
+	body := make([]map[string]string, 0)
+	for _, client := range server.clients {
+		if !server.members[id][client.DatabaseID] {
+			continue
+		}
+		body = append(body, map[string]string{
+			"cldbid":                   strconv.Itoa(client.DatabaseID),
+			"client_nickname":          client.Nickname,
+			"client_unique_identifier": client.UniqueIdentifier,
+		})
+	}
+	writeResponse(w, ErrorOK, body)
+}
+
+func (server *Server) setServerGroup(w http.ResponseWriter, serverGroupID, databaseID string, add bool) {
+	groupID, _ := strconv.Atoi(serverGroupID)
+	clientID, _ := strconv.Atoi(databaseID)
+	if server.findServerGroup(groupID) == nil {
+		writeResponse(w, ErrorInvalidGroupID, nil)
+		return
+	}
+	if server.findClient(clientID) == nil {
+		writeResponse(w, ErrorDatabaseEmptyResult, nil)
+		return
+	}
+	if add {
+		if server.members[groupID][clientID] {
+			writeResponse(w, ErrorDuplicateEntry, nil)
+			return
+		}
+		server.members[groupID][clientID] = true
+	} else {
+		if !server.members[groupID][clientID] {
+			writeResponse(w, ErrorDatabaseEmptyResult, nil)
+			return
+		}
+		delete(server.members[groupID], clientID)
+	}
+	writeResponse(w, ErrorOK, nil)
+}
+
+func (server *Server) clientGetDbIDFromUID(w http.ResponseWriter, uniqueIdentifier string) {
+	for _, client := range server.clients {
+		if client.UniqueIdentifier == uniqueIdentifier {
+			writeResponse(w, ErrorOK, []map[string]string{{
+				"cluid":  client.UniqueIdentifier,
+				"cldbid": strconv.Itoa(client.DatabaseID),
+			}})
+			return
+		}
+	}
+	writeResponse(w, ErrorDatabaseEmptyResult, nil)
+}
+
+func (server *Server) clientDbInfo(w http.ResponseWriter, databaseID string) {
+	id, _ := strconv.Atoi(databaseID)
+	client := server.findClient(id)
+	if client == nil {
+		writeResponse(w, ErrorDatabaseEmptyResult, nil)
+		return
+	}
+	writeResponse(w, ErrorOK, []map[string]string{{
+		"client_database_id":       strconv.Itoa(client.DatabaseID),
+		"client_nickname":          client.Nickname,
+		"client_description":       client.Description,
+		"client_unique_identifier": client.UniqueIdentifier,
+	}})
+}
+
+func (server *Server) clientList(w http.ResponseWriter) {
+	body := make([]map[string]string, 0)
+	for _, client := range server.clients {
+		if client.ClientID == 0 {
+			continue
+		}
+		body = append(body, map[string]string{
+			"cid":                strconv.Itoa(client.ChannelID),
+			"clid":               strconv.Itoa(client.ClientID),
+			"client_database_id": strconv.Itoa(client.DatabaseID),
+			"client_nickname":    client.Nickname,
+			"client_type":        "0",
+		})
+	}
+	writeResponse(w, ErrorOK, body)
+}
+
+// clientEdit changes the properties of a connected client. The fake server only keeps a single description per
+// client, so it changes the stored description as well.
+func (server *Server) clientEdit(w http.ResponseWriter, query url.Values) {
+	id, _ := strconv.Atoi(query.Get("clid"))
+	client := server.findConnection(id)
+	if client == nil {
+		writeResponse(w, ErrorInvalidClientID, nil)
+		return
+	}
+	server.editClient(client, query)
+	writeResponse(w, ErrorOK, nil)
+}
+
+func (server *Server) clientDbEdit(w http.ResponseWriter, query url.Values) {
+	id, _ := strconv.Atoi(query.Get("cldbid"))
+	client := server.findClient(id)
+	if client == nil {
+		writeResponse(w, ErrorDatabaseEmptyResult, nil)
+		return
+	}
+	server.editClient(client, query)
+	writeResponse(w, ErrorOK, nil)
+}
+
+// editClient applies the given client properties. Like the real server, parameters which are present but empty
+// reset the property.
+func (server *Server) editClient(client *databaseClient, query url.Values) {
+	if _, ok := query["client_nickname"]; ok {
+		client.Nickname = query.Get("client_nickname")
+	}
+	if _, ok := query["client_description"]; ok {
+		client.Description = query.Get("client_description")
+	}
+}
+
+func (server *Server) findConnection(clientID int) *databaseClient {
+	for _, client := range server.clients {
+		if client.ClientID != 0 && client.ClientID == clientID {
+			return client
+		}
+	}
+	return nil
+}
+
+func (server *Server) clientMove(w http.ResponseWriter, query url.Values) {
+	channelID, _ := strconv.Atoi(query.Get("cid"))
+	if !server.channels[channelID] {
+		writeResponse(w, ErrorInvalidChannelID, nil)
+		return
+	}
+	clients := make([]*databaseClient, 0)
+	for _, value := range query["clid"] {
+		id, _ := strconv.Atoi(value)
+		client := server.findConnection(id)
+		if client == nil {
+			writeResponse(w, ErrorInvalidClientID, nil)
+			return
+		}
+		clients = append(clients, client)
+	}
+	for _, client := range clients {
+		client.ChannelID = channelID
+	}
+	writeResponse(w, ErrorOK, nil)
+}
+
+func (server *Server) setClientChannelGroup(w http.ResponseWriter, query url.Values) {
+	channelGroupID, _ := strconv.Atoi(query.Get("cgid"))
+	channelID, _ := strconv.Atoi(query.Get("cid"))
+	databaseID, _ := strconv.Atoi(query.Get("cldbid"))
+	if !server.channels[channelID] {
+		writeResponse(w, ErrorInvalidChannelID, nil)
+		return
+	}
+	if server.findClient(databaseID) == nil {
+		writeResponse(w, ErrorDatabaseEmptyResult, nil)
+		return
+	}
+	if server.channelGroups[channelID] == nil {
+		server.channelGroups[channelID] = make(map[int]int)
+	}
+	server.channelGroups[channelID][databaseID] = channelGroupID
+	writeResponse(w, ErrorOK, nil)
+}
+
+func (server *Server) clientAddPerm(w http.ResponseWriter, query url.Values) {
+	databaseID, _ := strconv.Atoi(query.Get("cldbid"))
+	client := server.findClient(databaseID)
+	if client == nil {
+		writeResponse(w, ErrorDatabaseEmptyResult, nil)
+		return
+	}
+	value, _ := strconv.Atoi(query.Get("permvalue"))
+	client.Permissions[query.Get("permsid")] = value
+	writeResponse(w, ErrorOK, nil)
+}
+
+func (server *Server) clientDelPerm(w http.ResponseWriter, query url.Values) {
+	databaseID, _ := strconv.Atoi(query.Get("cldbid"))
+	client := server.findClient(databaseID)
+	if client == nil {
+		writeResponse(w, ErrorDatabaseEmptyResult, nil)
+		return
+	}
+	for _, name := range query["permsid"] {
+		delete(client.Permissions, name)
+	}
+	writeResponse(w, ErrorOK, nil)
+}
+
+// SetQueryPermission changes the value of a permission of the query user.
+func (server *Server) SetQueryPermission(name string, value int) {
+	server.Lock()
+	defer server.Unlock()
+	server.queryPermissions[name] = value
+}
+
+func (server *Server) permGet(w http.ResponseWriter, name string) {
+	value, ok := server.queryPermissions[name]
+	if !ok {
+		writeResponse(w, ErrorDatabaseEmptyResult, nil)
+		return
+	}
+	writeResponse(w, ErrorOK, []map[string]string{{"permsid": name, "permvalue": strconv.Itoa(value)}})
+}
+
+// sendTextMessage records private text messages. Messages to channels and the server are not supported.
+func (server *Server) sendTextMessage(w http.ResponseWriter, query url.Values) {
+	clientID, _ := strconv.Atoi(query.Get("target"))
+	if query.Get("targetmode") != "1" || server.findConnection(clientID) == nil {
+		writeResponse(w, ErrorInvalidClientID, nil)
+		return
+	}
+	server.messages = append(server.messages, TextMessage{ClientID: clientID, Text: query.Get("msg")})
+	writeResponse(w, ErrorOK, nil)
+}
+
+// writeResponse writes the WebQuery response. Like the real WebQuery, errors are reported by the status of the body
+// and not by the HTTP status code.
+func writeResponse(w http.ResponseWriter, code int, body interface{}) {
+	response := map[string]interface{}{
+		"status": map[string]interface{}{"code": code, "message": errorMessages[code]},
+	}
+	if body != nil {
+		response["body"] = body
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,105 @@
+package ts3test
+
+import (
+	ts3 "github.com/jkoenig134/go-ts3"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T) (*Server, *ts3.TeamspeakHttpClient) {
+	server, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	client := ts3.NewClient(ts3.NewConfig(server.URL, server.APIKey))
+	return server, &client
+}
+
+func TestServer_ServerGroups(t *testing.T) {
+	server, client := newTestClient(t)
+	serverGroupID := server.AddServerGroup("Live")
+	databaseID := server.AddClient("uid=", "streamer")
+	version, err := client.Version()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, version.Version)
+	serverGroups, err := client.ServerGroupList()
+	assert.NoError(t, err)
+	assert.Equal(t, []ts3.ServerGroup{{ServerGroupId: serverGroupID, Name: "Live", Type: 1}}, *serverGroups)
+	assert.NoError(t, client.ServerGroupAddClient(serverGroupID, databaseID))
+	assert.Error(t, client.ServerGroupAddClient(serverGroupID, databaseID), "expected duplicate membership to fail")
+	members, err := client.ServerGroupClientList(serverGroupID)
+	assert.NoError(t, err)
+	assert.Equal(t, []ts3.ServerGroupClientList{{ClientDbId: databaseID, ClientNickname: "streamer",
+		ClientUniqueIdentifier: "uid="}}, *members)
+	assert.Equal(t, []int{databaseID}, server.Members(serverGroupID))
+	assert.NoError(t, client.ServerGroupDeleteClient(serverGroupID, databaseID))
+	assert.False(t, server.IsMember(serverGroupID, databaseID))
+	assert.Error(t, client.ServerGroupAddClient(serverGroupID+100, databaseID), "expected unknown group to fail")
+}
+
+func TestServer_Clients(t *testing.T) {
+	server, client := newTestClient(t)
+	databaseID := server.AddClient("uid=", "streamer")
+	fetchedID, err := client.ClientGetDbIdFromUid("uid=")
+	assert.NoError(t, err)
+	assert.Equal(t, databaseID, *fetchedID)
+	_, err = client.ClientGetDbIdFromUid("unknown")
+	assert.Error(t, err)
+	info, err := client.ClientDbInfo(databaseID)
+	assert.NoError(t, err)
+	assert.Equal(t, "streamer", info.ClientNickname)
+}
+
+func TestServer_QueryPermissions(t *testing.T) {
+	server, client := newTestClient(t)
+	permission, err := client.StringPermissionGet("i_group_member_add_power")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultMemberPower, permission.PermissionValue)
+	server.SetQueryPermission("i_group_member_add_power", 10)
+	permission, err = client.StringPermissionGet("i_group_member_add_power")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, permission.PermissionValue)
+	_, err = client.StringPermissionGet("unknown")
+	assert.Error(t, err)
+}
+
+func TestServer_Failures(t *testing.T) {
+	server, client := newTestClient(t)
+	serverGroupID := server.AddServerGroup("Live")
+	databaseID := server.AddClient("uid=", "streamer")
+	server.FailNext("servergroupaddclient", 1)
+	assert.Error(t, client.ServerGroupAddClient(serverGroupID, databaseID))
+	assert.NoError(t, client.ServerGroupAddClient(serverGroupID, databaseID))
+	assert.Equal(t, 2, server.Requests("servergroupaddclient"))
+	invalidClient := ts3.NewClient(ts3.NewConfig(server.URL, "invalid"))
+	_, err := invalidClient.Version()
+	assert.Error(t, err, "expected invalid api key to be rejected")
+}
+
+func TestServer_Events(t *testing.T) {
+	server, client := newTestClient(t)
+	serverGroupID := server.AddServerGroup("Live")
+	databaseID := server.AddClient("uid=", "the streamer")
+	server.SetMember(serverGroupID, databaseID, true)
+	events := make(chan *ts3.ClientEnterViewEvent, 1)
+	assert.NoError(t, client.SubscribeEvent(ts3.NotifyClientEnterView, func(event *ts3.ClientEnterViewEvent) {
+		events <- event
+	}))
+	assert.NoError(t, client.StartEventClient(server.QueryAddress, server.QueryUser, server.QueryPassword))
+	assert.Eventually(t, func() bool {
+		return server.EventListeners() == 1
+	}, time.Second, 10*time.Millisecond, "expected the event client to register for events")
+	assert.True(t, server.Connect(databaseID))
+	assert.False(t, server.Connect(databaseID), "expected online client to not connect again")
+	select {
+	case event := <-events:
+		assert.Equal(t, databaseID, event.ClientDatabaseId)
+		assert.Equal(t, `the\sstreamer`, event.ClientNickname, "expected the nickname to be escaped")
+		assert.Equal(t, []int{serverGroupID}, event.ClientServergroups)
+	case <-time.After(time.Second):
+		t.Fatal("expected client enter event")
+	}
+	assert.True(t, server.Disconnect(databaseID))
+}
@@ -0,0 +1,129 @@
+package twitch
+
+import "context"
+
+// Broadcast forwards every state received from the source channel to all target channels until the context is done.
+// This allows multiple consumers to share the states of a single Monitor. Every target is served by its own goroutine
+// which queues the states the target has not received yet, so that a stalled consumer does not block the others. Once
+// the source channel is closed, all target channels are closed after their queued states so that the consumers can
+// finish the remaining states.
+func Broadcast(ctx context.Context, source <-chan *UserState, targets []chan *UserState) {
+	inputs := make([]chan *UserState, 0, len(targets))
+	for _, target := range targets {
+		input := make(chan *UserState)
+		inputs = append(inputs, input)
+		go forwardStates(ctx, input, target)
+	}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case state, ok := <-source:
+				if !ok {
+					for _, input := range inputs {
+						close(input)
+					}
+					return
+				}
+				for _, input := range inputs {
+					select {
+					case input <- state.copy():
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+// forwardStates sends the states received from the source channel to the target channel in order. The states are
+// queued while the target is not ready to receive them. The target channel is closed once the source channel has been
+// closed and all queued states have been sent.
+func forwardStates(ctx context.Context, source <-chan *UserState, target chan *UserState) {
+	queued := make([]*UserState, 0)
+	for source != nil || len(queued) > 0 {
+		// sending on a nil channel blocks, so nothing is sent while the queue is empty
+		var send chan *UserState
+		var next *UserState
+		if len(queued) > 0 {
+			send, next = target, queued[0]
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case state, ok := <-source:
+			if !ok {
+				source = nil
+				continue
+			}
+			queued = append(queued, state)
+		case send <- next:
+			queued = queued[1:]
+		}
+	}
+	close(target)
+}
+
+// BroadcastSubscriptions forwards every subscription state received from the source channel to all target channels
+// until the context is done. Like Broadcast, every target is served by its own goroutine and all target channels are
+// closed after their queued states once the source channel is closed.
+func BroadcastSubscriptions(ctx context.Context, source <-chan *SubscriptionState, targets []chan *SubscriptionState) {
+	inputs := make([]chan *SubscriptionState, 0, len(targets))
+	for _, target := range targets {
+		input := make(chan *SubscriptionState)
+		inputs = append(inputs, input)
+		go forwardSubscriptions(ctx, input, target)
+	}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case state, ok := <-source:
+				if !ok {
+					for _, input := range inputs {
+						close(input)
+					}
+					return
+				}
+				for _, input := range inputs {
+					stateCopy := *state
+					select {
+					case input <- &stateCopy:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+// forwardSubscriptions sends the subscription states received from the source channel to the target channel in order
+// and queues them while the target is not ready to receive them. The target channel is closed once the source channel
+// has been closed and all queued states have been sent.
+func forwardSubscriptions(ctx context.Context, source <-chan *SubscriptionState, target chan *SubscriptionState) {
+	queued := make([]*SubscriptionState, 0)
+	for source != nil || len(queued) > 0 {
+		var send chan *SubscriptionState
+		var next *SubscriptionState
+		if len(queued) > 0 {
+			send, next = target, queued[0]
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case state, ok := <-source:
+			if !ok {
+				source = nil
+				continue
+			}
+			queued = append(queued, state)
+		case send <- next:
+			queued = queued[1:]
+		}
+	}
+	close(target)
+}
@@ -0,0 +1,69 @@
+package twitch
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBroadcast(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	source := make(chan *UserState)
+	targets := []chan *UserState{make(chan *UserState, 1), make(chan *UserState, 1)}
+	Broadcast(ctx, source, targets)
+	state := &UserState{UserLogin: testStreamLogin1, StreamerStatus: StreamerStatusLive}
+	source <- state
+	for _, target := range targets {
+		received := <-target
+		assert.Equal(t, state, received, "expected every target to receive the state")
+		assert.True(t, state != received, "expected every target to receive a copy of the state")
+	}
+	close(source)
+	for _, target := range targets {
+		_, ok := <-target
+		assert.False(t, ok, "expected every target to be closed with the source")
+	}
+}
+
+func TestBroadcastSubscriptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	source := make(chan *SubscriptionState)
+	targets := []chan *SubscriptionState{make(chan *SubscriptionState, 1), make(chan *SubscriptionState, 1)}
+	BroadcastSubscriptions(ctx, source, targets)
+	state := &SubscriptionState{UserLogin: testStreamLogin1, Tier: "1000"}
+	source <- state
+	for _, target := range targets {
+		assert.Equal(t, state, <-target, "expected every target to receive the subscription state")
+	}
+	close(source)
+	for _, target := range targets {
+		_, ok := <-target
+		assert.False(t, ok, "expected every target to be closed with the source")
+	}
+}
+
+func TestBroadcast_StalledTarget(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	source := make(chan *UserState)
+	stalled, target := make(chan *UserState), make(chan *UserState)
+	Broadcast(ctx, source, []chan *UserState{stalled, target})
+	logins := []string{testStreamLogin1, testStreamLogin2}
+	for _, login := range logins {
+		select {
+		case source <- &UserState{UserLogin: login}:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected a stalled target to not block the source")
+		}
+		assert.Equal(t, login, (<-target).UserLogin, "expected a stalled target to not block the others")
+	}
+	close(source)
+	for _, login := range logins {
+		assert.Equal(t, login, (<-stalled).UserLogin, "expected the queued states to be received in order")
+	}
+	_, ok := <-stalled
+	assert.False(t, ok, "expected the target to be closed after the queued states")
+}
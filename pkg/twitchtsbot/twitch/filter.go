@@ -0,0 +1,90 @@
+package twitch
+
+import (
+	"context"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/logging"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+	"time"
+)
+
+// StreamTypeLive is the type of streams which are broadcast live. Twitch reports other types such as "rerun" for
+// reruns of past broadcasts.
+const StreamTypeLive = "live"
+
+// StreamFilter decides which of the streams returned by a Provider count as live. Channels whose stream does not match
+// the filter are treated as offline.
+type StreamFilter struct {
+	// IncludeReruns counts streams whose type is not "live", e.g. reruns, as live. Streams whose type is empty
+	// always count since their provider does not distinguish stream types.
+	IncludeReruns bool
+	// MinUptime is the duration a stream has to run for before it counts as live.
+	MinUptime time.Duration
+	// ExcludeCategories contains the ids or names of the games whose streams do not count as live.
+	ExcludeCategories []string
+	// ExcludeTitles contains phrases, e.g. "24/7", which exclude a stream if its title contains one of them. The
+	// phrases are matched case-insensitively.
+	ExcludeTitles []string
+	// ExcludeMature excludes streams which are flagged as intended for mature audiences.
+	ExcludeMature bool
+}
+
+// Excludes returns the reason why the stream does not count as live at the given time. It is empty if the stream
+// matches the filter.
+func (filter *StreamFilter) Excludes(stream *Stream, now time.Time) string {
+	if !filter.IncludeReruns && stream.Type != "" && stream.Type != StreamTypeLive {
+		return "stream type " + stream.Type
+	}
+	if filter.ExcludeMature && stream.IsMature {
+		return "mature stream"
+	}
+	if filter.MinUptime > 0 && !stream.StartedAt.IsZero() && now.Sub(stream.StartedAt) < filter.MinUptime {
+		return "uptime below " + filter.MinUptime.String()
+	}
+	for _, category := range filter.ExcludeCategories {
+		if category != "" && (strings.EqualFold(category, stream.GameID) || strings.EqualFold(category, stream.GameName)) {
+			return "excluded category " + category
+		}
+	}
+	title := strings.ToLower(stream.Title)
+	for _, phrase := range filter.ExcludeTitles {
+		if phrase != "" && strings.Contains(title, strings.ToLower(phrase)) {
+			return "excluded title " + phrase
+		}
+	}
+	return ""
+}
+
+// filter returns the filter of the given user login. The filter of the login takes precedence over the global one.
+func (monitor *Monitor) filter(userLogin string) *StreamFilter {
+	if filter, ok := monitor.Filters[strings.ToLower(userLogin)]; ok && filter != nil {
+		return filter
+	}
+	if monitor.Filter != nil {
+		return monitor.Filter
+	}
+	return &StreamFilter{}
+}
+
+// liveStream returns the stream of the user if it has been fetched and matches the filter of the user.
+func (monitor *Monitor) liveStream(ctx context.Context, streams []Stream, userLogin string) *Stream {
+	stream := findStream(streams, userLogin)
+	if stream == nil {
+		return nil
+	}
+	if reason := monitor.filter(userLogin).Excludes(stream, monitor.Clock.Now()); reason != "" {
+		Log.WithFields(logrus.Fields{
+			"platform":               monitor.Provider.Platform(),
+			logging.FieldTwitchLogin: userLogin,
+			"reason":                 reason,
+		}).Debugln("Stream is excluded by the stream filter.")
+		trace.SpanFromContext(ctx).AddEvent("stream excluded", trace.WithAttributes(
+			attribute.String(logging.FieldTwitchLogin, userLogin),
+			attribute.String("reason", reason),
+		))
+		return nil
+	}
+	return stream
+}
@@ -0,0 +1,68 @@
+package twitch
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestStreamFilter_Excludes(t *testing.T) {
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	stream := &Stream{UserLogin: testStreamLogin1, Title: "Chill 24/7 Radio", GameID: "26936", GameName: "Music",
+		Type: StreamTypeLive, StartedAt: now.Add(-10 * time.Minute)}
+	assert.Empty(t, (&StreamFilter{}).Excludes(stream, now), "expected live streams to match the empty filter")
+	for reason, filter := range map[string]*StreamFilter{
+		"":                          {MinUptime: 10 * time.Minute, ExcludeTitles: []string{"rerun"}},
+		"uptime below 15m0s":        {MinUptime: 15 * time.Minute},
+		"excluded category 26936":   {ExcludeCategories: []string{"26936"}},
+		"excluded category music":   {ExcludeCategories: []string{"music"}},
+		"excluded title 24/7 RADIO": {ExcludeTitles: []string{"24/7 RADIO"}},
+	} {
+		assert.Equal(t, reason, filter.Excludes(stream, now))
+	}
+
+	mature := *stream
+	mature.IsMature = true
+	assert.Equal(t, "mature stream", (&StreamFilter{ExcludeMature: true}).Excludes(&mature, now))
+	assert.Empty(t, (&StreamFilter{}).Excludes(&mature, now), "expected mature streams to count by default")
+	assert.Empty(t, (&StreamFilter{ExcludeMature: true}).Excludes(stream, now))
+
+	rerun := *stream
+	rerun.Type = "rerun"
+	assert.Equal(t, "stream type rerun", (&StreamFilter{}).Excludes(&rerun, now))
+	assert.Empty(t, (&StreamFilter{IncludeReruns: true}).Excludes(&rerun, now))
+	rerun.Type = ""
+	assert.Empty(t, (&StreamFilter{}).Excludes(&rerun, now), "expected streams without a type to count as live")
+}
+
+func TestMonitor_Filter(t *testing.T) {
+	streams := []Stream{
+		{UserLogin: testStreamLogin1, Type: "rerun"},
+		{UserLogin: testStreamLogin2, Type: "rerun"},
+	}
+	provider := &testProvider{streams: streams}
+	notifyChan := make(chan *UserState, 2)
+	monitor := NewProviderMonitor(provider, []string{testStreamLogin1, testStreamLogin2}, time.Second,
+		context.Background(), notifyChan)
+	monitor.Filters = map[string]*StreamFilter{testStreamLogin2: {IncludeReruns: true}}
+	monitor.updateStreamerStates(context.Background(), streams)
+	assertStreamerStates(t, notifyChan, map[string]StreamerStatus{
+		testStreamLogin1: StreamerStatusOffline,
+		testStreamLogin2: StreamerStatusLive,
+	})
+
+	// the live stream turns offline once it is excluded by the global filter
+	streams[1].Title = "rerun of yesterday"
+	monitor.Filter = &StreamFilter{IncludeReruns: true, ExcludeTitles: []string{"rerun of"}}
+	monitor.Filters = nil
+	for i := 0; i <= changesRequired; i++ {
+		monitor.updateStreamerStates(context.Background(), streams[1:])
+	}
+	assertStreamerStates(t, notifyChan, map[string]StreamerStatus{testStreamLogin2: StreamerStatusOffline})
+
+	states, err := monitor.FetchStates()
+	assert.Nil(t, err, "returned err for fetch states method is not nil")
+	assert.Equal(t, StreamerStatusLive, states[0].StreamerStatus, "expected fetched states to use the filter")
+	assert.Equal(t, StreamerStatusOffline, states[1].StreamerStatus, "expected fetched states to use the filter")
+}
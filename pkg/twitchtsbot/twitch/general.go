@@ -5,4 +5,5 @@ import "github.com/nicklaw5/helix"
 type ApiClient interface {
 	GetStreams(params *helix.StreamsParams) (*helix.StreamsResponse, error)
 	GetUsers(params *helix.UsersParams) (*helix.UsersResponse, error)
+	GetGames(params *helix.GamesParams) (*helix.GamesResponse, error)
 }
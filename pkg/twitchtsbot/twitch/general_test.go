@@ -28,3 +28,23 @@ func (client *testApiClient) GetUsers(params *helix.UsersParams) (*helix.UsersRe
 	}
 	return nil, err
 }
+
+func (client *testApiClient) GetGames(params *helix.GamesParams) (*helix.GamesResponse, error) {
+	args := client.Called(params)
+	resp := args.Get(0)
+	err := args.Error(1)
+	if resp != nil {
+		return resp.(*helix.GamesResponse), err
+	}
+	return nil, err
+}
+
+func (client *testApiClient) GetSubscriptions(params *helix.SubscriptionsParams) (*helix.SubscriptionsResponse, error) {
+	args := client.Called(params)
+	resp := args.Get(0)
+	err := args.Error(1)
+	if resp != nil {
+		return resp.(*helix.SubscriptionsResponse), err
+	}
+	return nil, err
+}
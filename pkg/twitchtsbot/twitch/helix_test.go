@@ -0,0 +1,49 @@
+package twitch
+
+import (
+	"context"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/testutil/helixtest"
+	"github.com/nicklaw5/helix"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestMonitor_HelixFakeServer drives the monitor with the real helix client through a stream which goes live, an API
+// outage and the end of the stream.
+func TestMonitor_HelixFakeServer(t *testing.T) {
+	server := helixtest.NewServer()
+	defer server.Close()
+	server.AddUser("streamer")
+	client, err := server.Client(server.IssueAppToken())
+	assert.NoError(t, err)
+	notifyChan := make(chan *UserState, 1)
+	monitor := NewMonitor(client, []string{"streamer"}, time.Second, context.Background(), notifyChan)
+	assert.NoError(t, monitor.updateUserStates())
+	assert.Equal(t, StreamerStatusOffline, (<-notifyChan).StreamerStatus, "expected initial offline state")
+
+	server.SetLive("streamer", helix.Stream{Title: "title", ViewerCount: 3})
+	for i := 0; i < changesRequired; i++ {
+		assert.NoError(t, monitor.updateUserStates())
+	}
+	assert.Len(t, notifyChan, 0, "expected the live transition to be debounced")
+	server.FailNext(http.StatusInternalServerError, 1)
+	err = monitor.updateUserStates()
+	assert.Equal(t, &StatusError{Platform: PlatformTwitch, StatusCode: http.StatusInternalServerError}, err)
+	assert.Len(t, notifyChan, 0, "expected a failed poll to not change the state")
+	assert.NoError(t, monitor.updateUserStates())
+	state := <-notifyChan
+	assert.Equal(t, StreamerStatusLive, state.StreamerStatus, "expected live transition")
+	assert.Equal(t, "title", state.Stream.Title)
+
+	server.SetOffline("streamer")
+	for i := 0; i <= changesRequired; i++ {
+		assert.NoError(t, monitor.updateUserStates())
+	}
+	assert.Equal(t, StreamerStatusOffline, (<-notifyChan).StreamerStatus, "expected offline transition")
+	rateLimit, ok := monitor.rateLimit()
+	assert.True(t, ok, "expected the rate limit headers to be parsed")
+	assert.Equal(t, helixtest.DefaultRateLimit, rateLimit.Limit)
+	assert.Equal(t, 2*changesRequired+4, server.Requests("/helix/streams"))
+}
@@ -0,0 +1,70 @@
+package twitch
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/nicklaw5/helix"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// MatureRecorder is a helix.HTTPClient which records the mature flag of the streams returned by the Get Streams
+// endpoint. The helix version in use does not decode the flag, so HelixProvider looks it up by the id of the stream.
+type MatureRecorder struct {
+	*sync.Mutex
+	HTTPClient helix.HTTPClient
+	// stream id: mature
+	mature map[string]bool
+}
+
+// NewMatureRecorder creates a MatureRecorder which sends the requests with the given client. The default HTTP client is
+// used if it is nil.
+func NewMatureRecorder(client helix.HTTPClient) *MatureRecorder {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &MatureRecorder{Mutex: &sync.Mutex{}, HTTPClient: client, mature: make(map[string]bool)}
+}
+
+type matureStreamsResponse struct {
+	Data []struct {
+		ID       string `json:"id"`
+		IsMature bool   `json:"is_mature"`
+	} `json:"data"`
+}
+
+func (recorder *MatureRecorder) Do(request *http.Request) (*http.Response, error) {
+	response, err := recorder.HTTPClient.Do(request)
+	if err != nil || response.StatusCode != http.StatusOK || !strings.HasSuffix(request.URL.Path, "/streams") {
+		return response, err
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	_ = response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	var streams matureStreamsResponse
+	if err := json.Unmarshal(body, &streams); err != nil {
+		// the helix client reports the invalid body
+		return response, nil
+	}
+	recorder.Lock()
+	defer recorder.Unlock()
+	for _, stream := range streams.Data {
+		recorder.mature[stream.ID] = stream.IsMature
+	}
+	return response, nil
+}
+
+// Take returns whether the stream with the given id has been reported as mature and forgets the stream, so that only
+// the streams of the current response are kept.
+func (recorder *MatureRecorder) Take(streamID string) bool {
+	recorder.Lock()
+	defer recorder.Unlock()
+	mature := recorder.mature[streamID]
+	delete(recorder.mature, streamID)
+	return mature
+}
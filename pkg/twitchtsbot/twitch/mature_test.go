@@ -0,0 +1,35 @@
+package twitch
+
+import (
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/testutil/helixtest"
+	"github.com/nicklaw5/helix"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMatureRecorder(t *testing.T) {
+	server := helixtest.NewServer()
+	defer server.Close()
+	server.SetLive("streamer", helix.Stream{Title: "title"})
+	server.SetLive("mature", helix.Stream{Title: "title"})
+	server.SetMature("mature", true)
+	recorder := NewMatureRecorder(server.HTTPClient())
+	client, err := helix.NewClient(&helix.Options{
+		ClientID:       server.ClientID,
+		AppAccessToken: server.IssueAppToken(),
+		HTTPClient:     recorder,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	provider := NewHelixProvider(client)
+	provider.Mature = recorder
+	streams, err := provider.LiveStreams([]string{"streamer", "mature"})
+	assert.NoError(t, err)
+	mature := make(map[string]bool, len(streams))
+	for _, stream := range streams {
+		mature[stream.UserLogin] = stream.IsMature
+	}
+	assert.Equal(t, map[string]bool{"streamer": false, "mature": true}, mature, "unexpected mature flags")
+	assert.Empty(t, recorder.mature, "expected the recorded flags to be taken")
+}
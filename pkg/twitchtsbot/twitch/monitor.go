@@ -4,9 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/nicklaw5/helix"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/clock"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/logging"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"math/rand"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -20,50 +25,141 @@ const (
 	changesRequired = 3
 )
 
+const (
+	// DefaultMaxBackoff is the longest interval the monitor waits between two polls after failed requests or while
+	// the rate limit budget is low.
+	DefaultMaxBackoff = 5 * time.Minute
+	// lowRateLimitBudget is the fraction of the rate limit below which the poll interval is stretched.
+	lowRateLimitBudget = 0.2
+)
+
+func (status StreamerStatus) String() string {
+	if status == StreamerStatusLive {
+		return "live"
+	}
+	return "offline"
+}
+
+func (status StreamerStatus) MarshalText() ([]byte, error) {
+	return []byte(status.String()), nil
+}
+
+func (status *StreamerStatus) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "live":
+		*status = StreamerStatusLive
+	case "offline":
+		*status = StreamerStatusOffline
+	default:
+		return fmt.Errorf("unknown streamer status: %s", text)
+	}
+	return nil
+}
+
 type UserState struct {
-	UserLogin      string
-	StreamerStatus StreamerStatus
+	Platform       string         `json:"platform"`
+	UserLogin      string         `json:"userLogin"`
+	StreamerStatus StreamerStatus `json:"status"`
+	// Since is the time at which the current status has been detected.
+	Since time.Time `json:"since"`
+	// Stream contains the metadata of the current stream and is nil while the user is offline.
+	Stream *Stream `json:"stream,omitempty"`
+	// SpanContext is the context of the span in which the state has been notified. It is not persisted.
+	SpanContext trace.SpanContext `json:"-"`
+}
+
+func (state *UserState) Channel() Channel {
+	return Channel{Platform: state.Platform, Login: state.UserLogin}
+}
+
+func (state *UserState) copy() *UserState {
+	stateCopy := *state
+	if state.Stream != nil {
+		stream := *state.Stream
+		stateCopy.Stream = &stream
+	}
+	return &stateCopy
 }
 
 type ChangeState struct {
-	Status StreamerStatus
-	Count  int
+	Status StreamerStatus `json:"status"`
+	Count  int            `json:"count"`
 }
 
 type Monitor struct {
 	*sync.Mutex
 	States       map[string]*UserState
 	ChangeActive map[string]*ChangeState
-	Client       ApiClient
+	Provider     Provider
 	UserLogins   []string
 	Interval     time.Duration
+	MaxBackoff   time.Duration
 	Context      context.Context
 	NotifyChan   chan *UserState
+	// MetadataChan receives the states of live streams whose title or game has changed. The changes are not notified
+	// if it is nil.
+	MetadataChan chan *UserState
+	// StateFile is the path the state is persisted to after every poll. The state is not persisted if it is empty.
+	StateFile string
+	// Filter decides which of the fetched streams count as live. Only streams of the type "live" count if it is nil.
+	Filter *StreamFilter
+	// Filters contains the filters of single user logins in lower case which replace Filter for these logins.
+	Filters map[string]*StreamFilter
+	// Clock provides the time of the polls and state changes and defaults to the real clock.
+	Clock clock.Clock
+	// effectiveInterval is the current poll interval which differs from Interval after failed requests or while the
+	// rate limit budget is low
+	effectiveInterval time.Duration
+	failures          int
+	running           sync.WaitGroup
 }
 
+// NewMonitor creates a Monitor which retrieves the stream states of the given user logins from the Twitch Helix API.
 func NewMonitor(client ApiClient, userLogins []string, interval time.Duration, context context.Context, notifyChan chan *UserState) *Monitor {
+	return NewProviderMonitor(NewHelixProvider(client), userLogins, interval, context, notifyChan)
+}
+
+// NewProviderMonitor creates a Monitor which retrieves the stream states of the given channels from the given Provider.
+func NewProviderMonitor(provider Provider, userLogins []string, interval time.Duration, context context.Context, notifyChan chan *UserState) *Monitor {
 	monitor := &Monitor{
 		Mutex:      &sync.Mutex{},
-		Client:     client,
+		Provider:   provider,
 		UserLogins: userLogins,
 		Interval:   interval,
+		MaxBackoff: DefaultMaxBackoff,
 		Context:    context,
 		NotifyChan: notifyChan,
+		Clock:      clock.Real,
+
+		effectiveInterval: interval,
 	}
 	return monitor
 }
 
 func (monitor *Monitor) Start() {
 	Log.WithFields(logrus.Fields{
+		"platform":        monitor.Provider.Platform(),
 		"interval":        monitor.Interval.String(),
 		"userLoginNumber": len(monitor.UserLogins),
-	}).Infoln("Starting Twitch stream monitor")
+	}).Infoln("Starting stream monitor")
+	monitor.running.Add(1)
 	go func() {
+		defer monitor.running.Done()
 		for {
 			select {
-			case <-time.After(monitor.Interval):
-				if err := monitor.updateUserStates(); err != nil {
-					Log.WithError(err).Errorln("Could not update streamer states!")
+			case <-monitor.Clock.After(monitor.EffectiveInterval()):
+				err := monitor.updateUserStates()
+				if err == nil && monitor.StateFile != "" {
+					if err := monitor.SaveState(monitor.StateFile); err != nil {
+						Log.WithError(err).WithField("stateFile", monitor.StateFile).Warnln("Could not persist monitor state.")
+					}
+				}
+				interval := monitor.adjustInterval(err)
+				if err != nil {
+					Log.WithError(err).WithFields(logrus.Fields{
+						"platform": monitor.Provider.Platform(),
+						"retryIn":  interval.String(),
+					}).Errorln("Could not update streamer states!")
 				}
 			case <-monitor.Context.Done():
 				return
@@ -72,47 +168,175 @@ func (monitor *Monitor) Start() {
 	}()
 }
 
+// Wait blocks until the monitor has stopped polling after its context is done. A poll in progress is finished first.
+func (monitor *Monitor) Wait() {
+	monitor.running.Wait()
+}
+
+// EffectiveInterval returns the interval which is currently waited between two polls.
+func (monitor *Monitor) EffectiveInterval() time.Duration {
+	monitor.Lock()
+	defer monitor.Unlock()
+	return monitor.effectiveInterval
+}
+
+// Failures returns the amount of consecutive failed polls.
+func (monitor *Monitor) Failures() int {
+	monitor.Lock()
+	defer monitor.Unlock()
+	return monitor.failures
+}
+
+// adjustInterval computes the interval until the next poll based on the result of the last one and returns it.
+func (monitor *Monitor) adjustInterval(err error) time.Duration {
+	monitor.Lock()
+	defer monitor.Unlock()
+	previous := monitor.effectiveInterval
+	if err != nil {
+		monitor.failures++
+		monitor.effectiveInterval = monitor.backoff(err)
+		return monitor.effectiveInterval
+	}
+	monitor.failures = 0
+	monitor.effectiveInterval = monitor.budgetInterval()
+	if (previous == monitor.Interval) != (monitor.effectiveInterval == monitor.Interval) {
+		Log.WithFields(logrus.Fields{
+			"platform":          monitor.Provider.Platform(),
+			"interval":          monitor.Interval.String(),
+			"effectiveInterval": monitor.effectiveInterval.String(),
+		}).Infoln("Changed stream monitor interval")
+	}
+	return monitor.effectiveInterval
+}
+
+// backoff returns the exponentially increased and jittered interval after failed polls. If the rate limit has been
+// exceeded, the interval lasts at least until the rate limit is reset.
+func (monitor *Monitor) backoff(err error) time.Duration {
+	delay := monitor.Interval
+	for i := 0; i < monitor.failures && delay < monitor.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > monitor.MaxBackoff {
+		delay = monitor.MaxBackoff
+	}
+	// equal jitter so that multiple instances which failed at the same time do not retry at the same time
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests {
+		if rateLimit, ok := monitor.rateLimit(); ok {
+			if untilReset := rateLimit.Reset.Sub(monitor.Clock.Now()); untilReset > delay {
+				delay = untilReset
+			}
+		}
+	}
+	if delay < monitor.Interval {
+		delay = monitor.Interval
+	}
+	return delay
+}
+
+// budgetInterval returns the interval which spreads the remaining rate limit budget until its reset if the budget is
+// low and the configured interval otherwise.
+func (monitor *Monitor) budgetInterval() time.Duration {
+	rateLimit, ok := monitor.rateLimit()
+	if !ok || float64(rateLimit.Remaining) > float64(rateLimit.Limit)*lowRateLimitBudget {
+		return monitor.Interval
+	}
+	untilReset := rateLimit.Reset.Sub(monitor.Clock.Now())
+	if untilReset <= 0 {
+		return monitor.Interval
+	}
+	requests := monitor.Provider.(RateLimitedProvider).Requests(len(monitor.UserLogins))
+	interval := untilReset
+	if rateLimit.Remaining >= requests {
+		interval = untilReset * time.Duration(requests) / time.Duration(rateLimit.Remaining)
+	}
+	if interval < monitor.Interval {
+		return monitor.Interval
+	} else if interval > monitor.MaxBackoff {
+		return monitor.MaxBackoff
+	}
+	return interval
+}
+
+func (monitor *Monitor) rateLimit() (RateLimit, bool) {
+	provider, ok := monitor.Provider.(RateLimitedProvider)
+	if !ok {
+		return RateLimit{}, false
+	}
+	return provider.RateLimit()
+}
+
 func (monitor *Monitor) updateUserStates() error {
-	resp, err := monitor.Client.GetStreams(&helix.StreamsParams{
-		Type:       "live",
-		UserLogins: monitor.UserLogins,
-	})
+	ctx := monitor.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := tracer.Start(ctx, "twitch.poll", trace.WithAttributes(
+		attribute.String("platform", monitor.Provider.Platform()),
+		attribute.Int("channels", len(monitor.UserLogins)),
+	))
+	defer span.End()
+	streams, err := monitor.Provider.LiveStreams(monitor.UserLogins)
 	if err != nil {
+		recordError(span, err)
 		return err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return errors.New(fmt.Sprintf("twitch api returned enexpected status code: %d", resp.StatusCode))
-	}
-	Log.WithField("streams", resp.Data.Streams).Debugln("Fetched live streams from Twitch API.")
-	monitor.updateStreamerStates(resp.Data.Streams)
+	span.SetAttributes(attribute.Int("live_streams", len(streams)))
+	monitor.updateStreamerStates(ctx, streams)
 	return nil
 }
 
-func (monitor *Monitor) updateStreamerStates(streams []helix.Stream) {
+func (monitor *Monitor) updateStreamerStates(ctx context.Context, streams []Stream) {
 	monitor.Lock()
 	defer monitor.Unlock()
 	if monitor.States == nil {
-		monitor.initializeStreamerStates(streams)
+		monitor.initializeStreamerStates(ctx, streams)
 		return
 	}
+	// add users which are not part of a restored state
+	for _, userLogin := range monitor.UserLogins {
+		if _, ok := monitor.States[userLogin]; !ok {
+			state := monitor.newUserState(ctx, userLogin, streams)
+			monitor.States[userLogin] = state
+			monitor.notify(ctx, monitor.NotifyChan, "twitch.transition", state)
+		}
+	}
 	// check for default states
 	for userLogin, state := range monitor.States {
 		fetchedStatus := StreamerStatusOffline
-		for _, stream := range streams {
-			if strings.EqualFold(stream.UserName, userLogin) {
-				fetchedStatus = StreamerStatusLive
+		fetchedStream := monitor.liveStream(ctx, streams, userLogin)
+		if fetchedStream != nil {
+			fetchedStatus = StreamerStatusLive
+		}
+		if state.StreamerStatus == StreamerStatusLive && fetchedStream != nil {
+			// keep the metadata of running streams up to date
+			changed := state.Stream != nil && (state.Stream.Title != fetchedStream.Title ||
+				state.Stream.GameID != fetchedStream.GameID)
+			state.Stream = fetchedStream
+			if changed && monitor.MetadataChan != nil {
+				monitor.notify(ctx, monitor.MetadataChan, "twitch.metadata", state)
 			}
 		}
 		if state.StreamerStatus != fetchedStatus {
 			if changeStatus, ok := monitor.ChangeActive[state.UserLogin]; !ok {
 				monitor.ChangeActive[state.UserLogin] = &ChangeState{Status: fetchedStatus, Count: 1}
+				pendingChange(ctx, state, fetchedStatus, 1)
 				continue
 			} else if changeStatus.Status == fetchedStatus {
 				if changeStatus.Count >= changesRequired {
 					state.StreamerStatus = fetchedStatus
-					monitor.NotifyChan <- state
+					state.Since = monitor.Clock.Now()
+					state.Stream = fetchedStream
+					Log.WithFields(logrus.Fields{
+						"platform":               state.Platform,
+						logging.FieldTwitchLogin: state.UserLogin,
+						"status":                 state.StreamerStatus.String(),
+					}).Infoln("Stream status changed")
+					monitor.notify(ctx, monitor.NotifyChan, "twitch.transition", state)
 				} else {
 					changeStatus.Count++
+					pendingChange(ctx, state, fetchedStatus, changeStatus.Count)
 					continue
 				}
 			}
@@ -121,27 +345,74 @@ func (monitor *Monitor) updateStreamerStates(streams []helix.Stream) {
 	}
 }
 
-func (monitor *Monitor) initializeStreamerStates(streams []helix.Stream) {
+func (monitor *Monitor) initializeStreamerStates(ctx context.Context, streams []Stream) {
 	monitor.States = make(map[string]*UserState, len(monitor.UserLogins))
 	monitor.ChangeActive = make(map[string]*ChangeState, len(monitor.UserLogins))
 	for _, userLogin := range monitor.UserLogins {
-		state := &UserState{
-			UserLogin:      userLogin,
-			StreamerStatus: StreamerStatusOffline,
-		}
-		for _, stream := range streams {
-			if strings.EqualFold(stream.UserName, userLogin) {
-				state.StreamerStatus = StreamerStatusLive
-			}
-		}
+		state := monitor.newUserState(ctx, userLogin, streams)
 		monitor.States[userLogin] = state
-		monitor.NotifyChan <- state
+		monitor.notify(ctx, monitor.NotifyChan, "twitch.transition", state)
 	}
 }
 
+func (monitor *Monitor) newUserState(ctx context.Context, userLogin string, streams []Stream) *UserState {
+	state := &UserState{
+		Platform:       monitor.Provider.Platform(),
+		UserLogin:      userLogin,
+		StreamerStatus: StreamerStatusOffline,
+		Since:          monitor.Clock.Now(),
+	}
+	if stream := monitor.liveStream(ctx, streams, userLogin); stream != nil {
+		state.StreamerStatus = StreamerStatusLive
+		state.Stream = stream
+	}
+	return state
+}
+
+// FetchStates retrieves the current states of all monitored users once. In contrast to the polling of a started
+// Monitor, the states are neither debounced nor notified, but the streams are filtered the same way.
+func (monitor *Monitor) FetchStates() ([]*UserState, error) {
+	streams, err := monitor.Provider.LiveStreams(monitor.UserLogins)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]*UserState, 0, len(monitor.UserLogins))
+	for _, userLogin := range monitor.UserLogins {
+		states = append(states, monitor.newUserState(context.Background(), userLogin, streams))
+	}
+	return states, nil
+}
+
+func findStream(streams []Stream, userLogin string) *Stream {
+	for _, stream := range streams {
+		if strings.EqualFold(stream.UserLogin, userLogin) {
+			stream := stream
+			return &stream
+		}
+	}
+	return nil
+}
+
 func (monitor *Monitor) GetState(userLogin string) (*UserState, bool) {
 	monitor.Lock()
 	defer monitor.Unlock()
 	state, ok := monitor.States[userLogin]
-	return state, ok
+	if !ok {
+		return nil, false
+	}
+	return state.copy(), true
+}
+
+// GetStates returns the states of all monitored users sorted by their login name.
+func (monitor *Monitor) GetStates() []*UserState {
+	monitor.Lock()
+	defer monitor.Unlock()
+	states := make([]*UserState, 0, len(monitor.States))
+	for _, state := range monitor.States {
+		states = append(states, state.copy())
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].UserLogin < states[j].UserLogin
+	})
+	return states
 }
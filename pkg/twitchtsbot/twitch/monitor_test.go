@@ -28,16 +28,20 @@ func TestMonitor_StartSingleStream(t *testing.T) {
 	response.Data.Streams = []helix.Stream{{UserName: testStreamLogin1}}
 	mockClient.On("GetStreams", &helix.StreamsParams{
 		UserLogins: []string{testStreamLogin1},
-		Type:       "live",
+		Type:       "all",
 	}).Return(&response, nil)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	notifyChan := make(chan *UserState)
 	defer close(notifyChan)
 	monitor := NewMonitor(mockClient, []string{testStreamLogin1}, time.Second, ctx, notifyChan)
-	go monitor.Start()
+	fakeClock := testutil.NewFakeClock(time.Now())
+	monitor.Clock = fakeClock
+	monitor.Start()
+	pollMonitor(t, fakeClock, monitor, 1)
 	assertStreamerStates(t, notifyChan, map[string]StreamerStatus{testStreamLogin1: StreamerStatusLive})
 	response.Data.Streams = nil
+	pollMonitor(t, fakeClock, monitor, changesRequired+1)
 	assertStreamerStates(t, notifyChan, map[string]StreamerStatus{testStreamLogin1: StreamerStatusOffline})
 }
 
@@ -47,7 +51,7 @@ func TestMonitor_StartMultipleStreams(t *testing.T) {
 	response.Data.Streams = []helix.Stream{{UserName: testStreamLogin1}}
 	mockClient.On("GetStreams", &helix.StreamsParams{
 		UserLogins: []string{testStreamLogin1, testStreamLogin2},
-		Type:       "live",
+		Type:       "all",
 	}).Return(&response, nil)
 	response.Data.Streams = []helix.Stream{{UserName: testStreamLogin1}}
 	ctx, cancel := context.WithCancel(context.Background())
@@ -55,12 +59,16 @@ func TestMonitor_StartMultipleStreams(t *testing.T) {
 	notifyChan := make(chan *UserState)
 	defer close(notifyChan)
 	monitor := NewMonitor(mockClient, []string{testStreamLogin1, testStreamLogin2}, time.Second, ctx, notifyChan)
-	go monitor.Start()
+	fakeClock := testutil.NewFakeClock(time.Now())
+	monitor.Clock = fakeClock
+	monitor.Start()
+	pollMonitor(t, fakeClock, monitor, 1)
 	assertStreamerStates(t, notifyChan, map[string]StreamerStatus{
 		testStreamLogin1: StreamerStatusLive,
 		testStreamLogin2: StreamerStatusOffline,
 	})
 	response.Data.Streams = []helix.Stream{{UserName: testStreamLogin2}}
+	pollMonitor(t, fakeClock, monitor, changesRequired+1)
 	assertStreamerStates(t, notifyChan, map[string]StreamerStatus{
 		testStreamLogin1: StreamerStatusOffline,
 		testStreamLogin2: StreamerStatusLive,
@@ -73,7 +81,7 @@ func TestMonitor_GetState(t *testing.T) {
 	response.Data.Streams = []helix.Stream{{UserName: testStreamLogin1}}
 	mockClient.On("GetStreams", &helix.StreamsParams{
 		UserLogins: []string{testStreamLogin1, testStreamLogin2},
-		Type:       "live",
+		Type:       "all",
 	}).Return(&response, nil)
 	response.Data.Streams = []helix.Stream{{UserName: testStreamLogin1}}
 	ctx, cancel := context.WithCancel(context.Background())
@@ -81,13 +89,16 @@ func TestMonitor_GetState(t *testing.T) {
 	notifyChan := make(chan *UserState)
 	defer close(notifyChan)
 	monitor := NewMonitor(mockClient, []string{testStreamLogin1, testStreamLogin2}, time.Second, ctx, notifyChan)
-	go monitor.Start()
+	fakeClock := testutil.NewFakeClock(time.Now())
+	monitor.Clock = fakeClock
+	monitor.Start()
 	go func() {
 		for {
 			<-notifyChan
 		}
 	}()
-	testutil.WaitForMethodCall(t, &mockClient.Mock, "GetStreams", 1, 10, time.Millisecond*400)
+	pollMonitor(t, fakeClock, monitor, 1)
+	fakeClock.WaitForWaiters(t, 1)
 	state, ok := monitor.GetState(testStreamLogin1)
 	assert.True(t, ok, "expected GetState to return state")
 	assert.Equal(t, state.UserLogin, testStreamLogin1, "expected GetState to return correct state login name")
@@ -102,14 +113,18 @@ func TestMonitor_StartInvalidCode(t *testing.T) {
 	response.StatusCode = http.StatusInternalServerError
 	mockClient.On("GetStreams", &helix.StreamsParams{
 		UserLogins: []string{testStreamLogin1, testStreamLogin2},
-		Type:       "live",
+		Type:       "all",
 	}).Return(&response, nil)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	notifyChan := make(chan *UserState)
 	monitor := NewMonitor(mockClient, []string{testStreamLogin1, testStreamLogin2}, time.Second, ctx, notifyChan)
-	go monitor.Start()
-	testutil.WaitForMethodCall(t, &mockClient.Mock, "GetStreams", 1, 10, time.Millisecond*400)
+	fakeClock := testutil.NewFakeClock(time.Now())
+	monitor.Clock = fakeClock
+	monitor.Start()
+	pollMonitor(t, fakeClock, monitor, 1)
+	fakeClock.WaitForWaiters(t, 1)
+	mockClient.AssertNumberOfCalls(t, "GetStreams", 1)
 	assert.Equal(t, logrus.ErrorLevel, hook.LastEntry().Level, "last entry level should be of type ErrorLevel")
 }
 
@@ -119,17 +134,46 @@ func TestMonitor_StartClientError(t *testing.T) {
 	mockClient := new(testApiClient)
 	mockClient.On("GetStreams", &helix.StreamsParams{
 		UserLogins: []string{testStreamLogin1, testStreamLogin2},
-		Type:       "live",
+		Type:       "all",
 	}).Return(nil, errors.New("test error"))
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	notifyChan := make(chan *UserState)
 	monitor := NewMonitor(mockClient, []string{testStreamLogin1, testStreamLogin2}, time.Second, ctx, notifyChan)
-	go monitor.Start()
-	testutil.WaitForMethodCall(t, &mockClient.Mock, "GetStreams", 1, 10, time.Millisecond*400)
+	fakeClock := testutil.NewFakeClock(time.Now())
+	monitor.Clock = fakeClock
+	monitor.Start()
+	pollMonitor(t, fakeClock, monitor, 1)
+	fakeClock.WaitForWaiters(t, 1)
+	mockClient.AssertNumberOfCalls(t, "GetStreams", 1)
 	assert.Equal(t, logrus.ErrorLevel, hook.LastEntry().Level)
 }
 
+// pollMonitor lets the started monitor poll the given amount of times by advancing the fake clock to its next poll.
+func TestMonitor_NotifyCanceled(t *testing.T) {
+	provider := &testProvider{streams: []Stream{{UserLogin: testStreamLogin1}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	monitor := NewProviderMonitor(provider, []string{testStreamLogin1}, time.Second, ctx, make(chan *UserState))
+	done := make(chan error, 1)
+	go func() {
+		done <- monitor.updateUserStates()
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a stopped monitor to not block on notifying")
+	}
+}
+
+func pollMonitor(t *testing.T, fakeClock *testutil.FakeClock, monitor *Monitor, polls int) {
+	for i := 0; i < polls; i++ {
+		fakeClock.WaitForWaiters(t, 1)
+		fakeClock.Advance(monitor.EffectiveInterval())
+	}
+}
+
 func assertStreamerStates(t *testing.T, notifyChan chan *UserState, statusMap map[string]StreamerStatus) {
 	for i := 0; i < len(statusMap); i++ {
 		state := <-notifyChan
@@ -138,3 +182,157 @@ func assertStreamerStates(t *testing.T, notifyChan chan *UserState, statusMap ma
 		assert.Equalf(t, expectedStatus, state.StreamerStatus, "unexpected streamer status from user login %s", state.UserLogin)
 	}
 }
+
+type testProvider struct {
+	streams []Stream
+	err     error
+}
+
+func (provider *testProvider) Platform() string {
+	return "test"
+}
+
+func (provider *testProvider) LiveStreams(_ []string) ([]Stream, error) {
+	return provider.streams, provider.err
+}
+
+func TestMonitor_ProviderMetadata(t *testing.T) {
+	provider := &testProvider{streams: []Stream{{UserLogin: testStreamLogin1, Title: "first title"}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notifyChan := make(chan *UserState, 1)
+	monitor := NewProviderMonitor(provider, []string{testStreamLogin1}, time.Second, ctx, notifyChan)
+	monitor.updateStreamerStates(context.Background(), provider.streams)
+	state := <-notifyChan
+	assert.Equal(t, "test", state.Platform, "expected state platform to match provider platform")
+	assert.Equal(t, Channel{Platform: "test", Login: testStreamLogin1}, state.Channel())
+	assert.Equal(t, "first title", state.Stream.Title, "expected state to contain stream metadata")
+	metadataChan := make(chan *UserState, 1)
+	monitor.MetadataChan = metadataChan
+	monitor.updateStreamerStates(context.Background(), []Stream{{UserLogin: testStreamLogin1, Title: "second title"}})
+	assert.Equal(t, "second title", (<-metadataChan).Stream.Title, "expected the title change to be notified")
+	monitor.updateStreamerStates(context.Background(),
+		[]Stream{{UserLogin: testStreamLogin1, Title: "second title", ViewerCount: 3}})
+	assert.Len(t, metadataChan, 0, "expected only title and game changes to be notified")
+	state, ok := monitor.GetState(testStreamLogin1)
+	assert.True(t, ok, "expected GetState to return state")
+	assert.Equal(t, "second title", state.Stream.Title, "expected stream metadata to be updated while live")
+	assert.Equal(t, []*UserState{state}, monitor.GetStates(), "expected GetStates to return all states")
+	for i := 0; i <= changesRequired; i++ {
+		monitor.updateStreamerStates(context.Background(), nil)
+	}
+	state = <-notifyChan
+	assert.Equal(t, StreamerStatusOffline, state.StreamerStatus, "expected streamer to be offline")
+	assert.Nil(t, state.Stream, "expected offline state to not contain stream metadata")
+}
+
+func TestMonitor_FetchStates(t *testing.T) {
+	provider := &testProvider{streams: []Stream{{UserLogin: testStreamLogin1}}}
+	monitor := NewProviderMonitor(provider, []string{testStreamLogin1, testStreamLogin2}, time.Second,
+		context.Background(), nil)
+	states, err := monitor.FetchStates()
+	assert.Nil(t, err, "returned err for fetch states method is not nil")
+	for _, state := range states {
+		assert.False(t, state.Since.IsZero(), "expected state to contain the time of detection")
+		state.Since = time.Time{}
+	}
+	assert.Equal(t, []*UserState{
+		{Platform: "test", UserLogin: testStreamLogin1, StreamerStatus: StreamerStatusLive, Stream: &provider.streams[0]},
+		{Platform: "test", UserLogin: testStreamLogin2, StreamerStatus: StreamerStatusOffline},
+	}, states, "returned states from fetch states method are invalid")
+	assert.Nil(t, monitor.States, "expected fetch states to not modify the monitor states")
+}
+
+func TestStreamerStatus_Text(t *testing.T) {
+	text, err := StreamerStatusLive.MarshalText()
+	assert.Nil(t, err)
+	var status StreamerStatus
+	assert.Nil(t, status.UnmarshalText(text))
+	assert.Equal(t, StreamerStatusLive, status, "expected streamer status to survive text round trip")
+	assert.NotNil(t, status.UnmarshalText([]byte("unknown")), "expected unknown streamer status to fail")
+}
+
+type testRateLimitedProvider struct {
+	testProvider
+	rateLimit RateLimit
+}
+
+func (provider *testRateLimitedProvider) RateLimit() (RateLimit, bool) {
+	return provider.rateLimit, provider.rateLimit.Limit > 0
+}
+
+func (provider *testRateLimitedProvider) Requests(channels int) int {
+	return channels
+}
+
+func TestMonitor_Backoff(t *testing.T) {
+	provider := &testRateLimitedProvider{}
+	monitor := NewProviderMonitor(provider, []string{testStreamLogin1}, time.Second, context.Background(), nil)
+	fakeClock := testutil.NewFakeClock(time.Now())
+	monitor.Clock = fakeClock
+	monitor.MaxBackoff = 10 * time.Second
+	testErr := errors.New("test error")
+	for failures, maxDelay := range []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second,
+		10 * time.Second, 10 * time.Second} {
+		interval := monitor.adjustInterval(testErr)
+		assert.Equal(t, failures+1, monitor.Failures())
+		assert.True(t, interval >= time.Second && interval >= maxDelay/2 && interval <= maxDelay,
+			"unexpected backoff %s after %d failures", interval, failures+1)
+	}
+	provider.rateLimit = RateLimit{Limit: 800, Remaining: 0, Reset: fakeClock.Now().Add(time.Minute)}
+	interval := monitor.adjustInterval(&StatusError{StatusCode: http.StatusTooManyRequests})
+	assert.Equal(t, time.Minute, interval, "expected backoff to last until the rate limit reset")
+	provider.rateLimit = RateLimit{}
+	assert.Equal(t, time.Second, monitor.adjustInterval(nil), "expected interval to be reset after a success")
+	assert.Equal(t, 0, monitor.Failures())
+}
+
+func TestMonitor_BudgetInterval(t *testing.T) {
+	fakeClock := testutil.NewFakeClock(time.Now())
+	provider := &testRateLimitedProvider{rateLimit: RateLimit{Limit: 800, Remaining: 700,
+		Reset: fakeClock.Now().Add(time.Minute)}}
+	monitor := NewProviderMonitor(provider, []string{testStreamLogin1, testStreamLogin2}, time.Second,
+		context.Background(), nil)
+	monitor.Clock = fakeClock
+	assert.Equal(t, time.Second, monitor.adjustInterval(nil), "expected configured interval with enough budget")
+	provider.rateLimit.Remaining = 20
+	interval := monitor.adjustInterval(nil)
+	// two requests per poll with 20 remaining requests within a minute
+	assert.Equal(t, 6*time.Second, interval, "unexpected stretched interval")
+	assert.Equal(t, interval, monitor.EffectiveInterval())
+	provider.rateLimit.Remaining = 0
+	interval = monitor.adjustInterval(nil)
+	assert.Equal(t, time.Minute, interval, "expected to wait until the rate limit reset")
+	fakeClock.Advance(40 * time.Second)
+	assert.Equal(t, 20*time.Second, monitor.adjustInterval(nil), "expected the interval to shrink towards the reset")
+}
+
+func TestMonitor_StartBackoff(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	Log = logger
+	provider := &testProvider{err: errors.New("test error")}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notifyChan := make(chan *UserState, 1)
+	monitor := NewProviderMonitor(provider, []string{testStreamLogin1}, time.Second, ctx, notifyChan)
+	fakeClock := testutil.NewFakeClock(time.Now())
+	monitor.Clock = fakeClock
+	monitor.Start()
+	pollMonitor(t, fakeClock, monitor, 3)
+	fakeClock.WaitForWaiters(t, 1)
+	assert.Equal(t, 3, monitor.Failures(), "expected every poll to fail")
+	backoff := monitor.EffectiveInterval()
+	assert.True(t, backoff >= 4*time.Second && backoff <= 8*time.Second, "unexpected backoff %s after 3 failures", backoff)
+
+	provider.err = nil
+	provider.streams = []Stream{{UserLogin: testStreamLogin1}}
+	fakeClock.Advance(backoff - time.Millisecond)
+	assert.Equal(t, 1, fakeClock.Waiters(), "expected no poll before the backoff elapsed")
+	startedAt := fakeClock.Now().Add(time.Millisecond)
+	fakeClock.Advance(time.Millisecond)
+	state := <-notifyChan
+	assert.Equal(t, StreamerStatusLive, state.StreamerStatus)
+	assert.Equal(t, startedAt, state.Since, "expected the state to be detected at the time of the poll")
+	fakeClock.WaitForWaiters(t, 1)
+	assert.Equal(t, time.Second, monitor.EffectiveInterval(), "expected the interval to be reset after a success")
+}
@@ -0,0 +1,229 @@
+package twitch
+
+import (
+	"fmt"
+	"github.com/nicklaw5/helix"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PlatformTwitch is the platform identifier of the Twitch Helix provider.
+const PlatformTwitch = "twitch"
+
+// helixMaxLogins is the maximum amount of user logins which can be passed to a single GetStreams request.
+const helixMaxLogins = 100
+
+// Channel identifies a single channel on a specific streaming platform.
+type Channel struct {
+	Platform string
+	Login    string
+}
+
+func (channel Channel) String() string {
+	return channel.Platform + "/" + channel.Login
+}
+
+// Stream contains the platform independent metadata of a live stream.
+type Stream struct {
+	UserLogin    string    `json:"userLogin"`
+	Title        string    `json:"title"`
+	GameID       string    `json:"gameId"`
+	GameName     string    `json:"gameName,omitempty"`
+	Type         string    `json:"type"`
+	ViewerCount  int       `json:"viewerCount"`
+	StartedAt    time.Time `json:"startedAt"`
+	ThumbnailURL string    `json:"thumbnailUrl"`
+	// IsMature is set if the broadcaster flagged the stream as intended for mature audiences.
+	IsMature bool `json:"isMature,omitempty"`
+}
+
+// RateLimit contains the request budget which has been reported by the rate limit headers of the last API response.
+type RateLimit struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// StatusError is returned by a Provider if the API responded with an unexpected status code.
+type StatusError struct {
+	Platform   string
+	StatusCode int
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("%s api returned unexpected status code: %d", err.Platform, err.StatusCode)
+}
+
+// Provider retrieves the live status of a set of channels from a streaming platform.
+type Provider interface {
+	// Platform returns the platform identifier such as "twitch" or "youtube".
+	Platform() string
+	// LiveStreams returns the streams of all given channels which are currently live including reruns. Channels which
+	// are offline are not part of the result.
+	LiveStreams(channels []string) ([]Stream, error)
+}
+
+// RateLimitedProvider is implemented by providers whose API reports the remaining request budget.
+type RateLimitedProvider interface {
+	Provider
+	// RateLimit returns the rate limit of the last API response and false if it is not known yet.
+	RateLimit() (RateLimit, bool)
+	// Requests returns the amount of API requests which are needed to retrieve the streams of the given amount of
+	// channels.
+	Requests(channels int) int
+}
+
+// HelixProvider implements Provider by using the Twitch Helix API. The streams of the Helix API only contain the
+// display name of the user and the id of the game, so the login names and game names are requested separately and
+// cached.
+type HelixProvider struct {
+	Client ApiClient
+	// Mature provides the mature flag of the fetched streams if the Client sends its requests through it. The streams
+	// are not flagged if it is nil.
+	Mature         *MatureRecorder
+	rateLimit      RateLimit
+	rateLimitMutex sync.RWMutex
+	// user id: login name
+	logins map[string]string
+	// game id: game name
+	games      map[string]string
+	namesMutex sync.Mutex
+}
+
+func NewHelixProvider(client ApiClient) *HelixProvider {
+	return &HelixProvider{Client: client, logins: make(map[string]string), games: make(map[string]string)}
+}
+
+func (provider *HelixProvider) Platform() string {
+	return PlatformTwitch
+}
+
+func (provider *HelixProvider) LiveStreams(channels []string) ([]Stream, error) {
+	streams := make([]Stream, 0)
+	for start := 0; start < len(channels); start += helixMaxLogins {
+		end := start + helixMaxLogins
+		if end > len(channels) {
+			end = len(channels)
+		}
+		resp, err := provider.Client.GetStreams(&helix.StreamsParams{
+			// reruns are part of the result so that the stream filter of the monitor can decide whether they count
+			Type:       "all",
+			UserLogins: channels[start:end],
+		})
+		if err != nil {
+			return nil, err
+		}
+		provider.updateRateLimit(&resp.ResponseCommon)
+		if resp.StatusCode != http.StatusOK {
+			return nil, &StatusError{Platform: PlatformTwitch, StatusCode: resp.StatusCode}
+		}
+		Log.WithField("streams", resp.Data.Streams).Debugln("Fetched live streams from Twitch API.")
+		if err := provider.resolveNames(resp.Data.Streams); err != nil {
+			return nil, err
+		}
+		for _, stream := range resp.Data.Streams {
+			streams = append(streams, Stream{
+				UserLogin:    provider.login(stream),
+				Title:        stream.Title,
+				GameID:       stream.GameID,
+				GameName:     provider.gameName(stream.GameID),
+				Type:         stream.Type,
+				ViewerCount:  stream.ViewerCount,
+				StartedAt:    stream.StartedAt,
+				ThumbnailURL: stream.ThumbnailURL,
+				IsMature:     provider.Mature != nil && provider.Mature.Take(stream.ID),
+			})
+		}
+	}
+	return streams, nil
+}
+
+// resolveNames requests the login names of the users and the names of the games of the streams which are not cached
+// yet. The streams cannot be matched to the channels without the login names, while missing game names are only
+// logged.
+func (provider *HelixProvider) resolveNames(streams []helix.Stream) error {
+	provider.namesMutex.Lock()
+	defer provider.namesMutex.Unlock()
+	userIDs, gameIDs := make([]string, 0), make([]string, 0)
+	for _, stream := range streams {
+		if _, ok := provider.logins[stream.UserID]; stream.UserID != "" && !ok {
+			userIDs = append(userIDs, stream.UserID)
+		}
+		if _, ok := provider.games[stream.GameID]; stream.GameID != "" && !ok {
+			gameIDs = append(gameIDs, stream.GameID)
+		}
+	}
+	if len(userIDs) > 0 {
+		resp, err := provider.Client.GetUsers(&helix.UsersParams{IDs: userIDs})
+		if err != nil {
+			return err
+		}
+		provider.updateRateLimit(&resp.ResponseCommon)
+		if resp.StatusCode != http.StatusOK {
+			return &StatusError{Platform: PlatformTwitch, StatusCode: resp.StatusCode}
+		}
+		for _, user := range resp.Data.Users {
+			provider.logins[user.ID] = user.Login
+		}
+	}
+	if len(gameIDs) > 0 {
+		resp, err := provider.Client.GetGames(&helix.GamesParams{IDs: gameIDs})
+		if err == nil {
+			provider.updateRateLimit(&resp.ResponseCommon)
+			if resp.StatusCode != http.StatusOK {
+				err = &StatusError{Platform: PlatformTwitch, StatusCode: resp.StatusCode}
+			}
+		}
+		if err != nil {
+			Log.WithError(err).WithField("gameIds", gameIDs).Warnln("Could not fetch Twitch game names.")
+			return nil
+		}
+		for _, game := range resp.Data.Games {
+			provider.games[game.ID] = game.Name
+		}
+	}
+	return nil
+}
+
+// login returns the login name of the user of the stream. If it is not known, the display name is used, which only
+// differs from the login name by its case unless the user has chosen a localized display name.
+func (provider *HelixProvider) login(stream helix.Stream) string {
+	provider.namesMutex.Lock()
+	defer provider.namesMutex.Unlock()
+	if login, ok := provider.logins[stream.UserID]; ok {
+		return login
+	}
+	return strings.ToLower(stream.UserName)
+}
+
+func (provider *HelixProvider) gameName(gameID string) string {
+	provider.namesMutex.Lock()
+	defer provider.namesMutex.Unlock()
+	return provider.games[gameID]
+}
+
+func (provider *HelixProvider) Requests(channels int) int {
+	return (channels + helixMaxLogins - 1) / helixMaxLogins
+}
+
+func (provider *HelixProvider) RateLimit() (RateLimit, bool) {
+	provider.rateLimitMutex.RLock()
+	defer provider.rateLimitMutex.RUnlock()
+	return provider.rateLimit, provider.rateLimit.Limit > 0
+}
+
+func (provider *HelixProvider) updateRateLimit(resp *helix.ResponseCommon) {
+	limit := resp.GetRateLimit()
+	if limit <= 0 {
+		return
+	}
+	provider.rateLimitMutex.Lock()
+	defer provider.rateLimitMutex.Unlock()
+	provider.rateLimit = RateLimit{
+		Limit:     limit,
+		Remaining: resp.GetRateLimitRemaining(),
+		Reset:     time.Unix(int64(resp.GetRateLimitReset()), 0),
+	}
+}
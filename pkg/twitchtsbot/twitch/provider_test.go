@@ -0,0 +1,119 @@
+package twitch
+
+import (
+	"errors"
+	"github.com/nicklaw5/helix"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHelixProvider_LiveStreams(t *testing.T) {
+	mockClient := new(testApiClient)
+	response := defaultOkStreamsResponse
+	response.Data.Streams = []helix.Stream{{UserID: "1000", UserName: "ストリーマー", Title: "title", GameID: "42",
+		ViewerCount: 7}}
+	mockClient.On("GetStreams", &helix.StreamsParams{
+		UserLogins: []string{testStreamLogin1, testStreamLogin2},
+		Type:       "all",
+	}).Return(&response, nil)
+	users := &helix.UsersResponse{ResponseCommon: helix.ResponseCommon{StatusCode: http.StatusOK}}
+	users.Data.Users = []helix.User{{ID: "1000", Login: testStreamLogin1, DisplayName: "ストリーマー"}}
+	mockClient.On("GetUsers", &helix.UsersParams{IDs: []string{"1000"}}).Return(users, nil)
+	games := &helix.GamesResponse{ResponseCommon: helix.ResponseCommon{StatusCode: http.StatusOK}}
+	games.Data.Games = []helix.Game{{ID: "42", Name: "Just Chatting"}}
+	mockClient.On("GetGames", &helix.GamesParams{IDs: []string{"42"}}).Return(games, nil)
+	provider := NewHelixProvider(mockClient)
+	for i := 0; i < 2; i++ {
+		streams, err := provider.LiveStreams([]string{testStreamLogin1, testStreamLogin2})
+		assert.Nil(t, err, "returned err for live streams method is not nil")
+		assert.Equal(t, []Stream{{UserLogin: testStreamLogin1, Title: "title", GameID: "42", GameName: "Just Chatting",
+			ViewerCount: 7}}, streams, "expected the login and game names to be resolved")
+	}
+	mockClient.AssertNumberOfCalls(t, "GetUsers", 1)
+	mockClient.AssertNumberOfCalls(t, "GetGames", 1)
+}
+
+func TestHelixProvider_LiveStreamsGameNameError(t *testing.T) {
+	mockClient := new(testApiClient)
+	response := defaultOkStreamsResponse
+	response.Data.Streams = []helix.Stream{{UserName: "Streamer", GameID: "42"}}
+	mockClient.On("GetStreams", &helix.StreamsParams{UserLogins: []string{"streamer"}, Type: "all"}).
+		Return(&response, nil)
+	mockClient.On("GetGames", &helix.GamesParams{IDs: []string{"42"}}).Return(nil, errors.New("test error"))
+	streams, err := NewHelixProvider(mockClient).LiveStreams([]string{"streamer"})
+	assert.Nil(t, err, "expected missing game names to not fail the request")
+	assert.Equal(t, []Stream{{UserLogin: "streamer", GameID: "42"}}, streams,
+		"expected the display name to be used if the user id is unknown")
+}
+
+func TestHelixProvider_LiveStreamsChunked(t *testing.T) {
+	mockClient := new(testApiClient)
+	logins := make([]string, helixMaxLogins+1)
+	for i := range logins {
+		logins[i] = strconv.Itoa(i)
+	}
+	response := defaultOkStreamsResponse
+	mockClient.On("GetStreams", &helix.StreamsParams{UserLogins: logins[:helixMaxLogins], Type: "all"}).
+		Return(&response, nil)
+	mockClient.On("GetStreams", &helix.StreamsParams{UserLogins: logins[helixMaxLogins:], Type: "all"}).
+		Return(&response, nil)
+	_, err := NewHelixProvider(mockClient).LiveStreams(logins)
+	assert.Nil(t, err, "returned err for live streams method is not nil")
+	mockClient.AssertNumberOfCalls(t, "GetStreams", 2)
+}
+
+func TestHelixProvider_LiveStreamsNoChannels(t *testing.T) {
+	mockClient := new(testApiClient)
+	streams, err := NewHelixProvider(mockClient).LiveStreams(nil)
+	assert.Nil(t, err, "returned err for live streams method is not nil")
+	assert.Empty(t, streams, "expected no streams without any channels")
+	mockClient.AssertNumberOfCalls(t, "GetStreams", 0)
+}
+
+func TestHelixProvider_LiveStreamsInvalidStatusCode(t *testing.T) {
+	mockClient := new(testApiClient)
+	response := defaultOkStreamsResponse
+	response.StatusCode = http.StatusInternalServerError
+	mockClient.On("GetStreams", &helix.StreamsParams{UserLogins: []string{testStreamLogin1}, Type: "all"}).
+		Return(&response, nil)
+	streams, err := NewHelixProvider(mockClient).LiveStreams([]string{testStreamLogin1})
+	assert.Nil(t, streams, "returned streams for live streams method should be nil")
+	assert.NotNil(t, err, "returned error for live streams should not be nil")
+}
+
+func TestHelixProvider_LiveStreamsError(t *testing.T) {
+	mockClient := new(testApiClient)
+	testErr := errors.New("test error")
+	mockClient.On("GetStreams", &helix.StreamsParams{UserLogins: []string{testStreamLogin1}, Type: "all"}).
+		Return(nil, testErr)
+	streams, err := NewHelixProvider(mockClient).LiveStreams([]string{testStreamLogin1})
+	assert.Nil(t, streams, "returned streams for live streams method should be nil")
+	assert.Equal(t, testErr, err, "returned error for live streams should be equal to test error")
+}
+
+func TestHelixProvider_RateLimit(t *testing.T) {
+	mockClient := new(testApiClient)
+	response := defaultOkStreamsResponse
+	response.StatusCode = http.StatusTooManyRequests
+	response.Header = http.Header{}
+	response.Header.Set("Ratelimit-Limit", "800")
+	response.Header.Set("Ratelimit-Remaining", "0")
+	response.Header.Set("Ratelimit-Reset", "1600000000")
+	mockClient.On("GetStreams", &helix.StreamsParams{UserLogins: []string{testStreamLogin1}, Type: "all"}).
+		Return(&response, nil)
+	provider := NewHelixProvider(mockClient)
+	_, ok := provider.RateLimit()
+	assert.False(t, ok, "expected rate limit to be unknown before the first request")
+	_, err := provider.LiveStreams([]string{testStreamLogin1})
+	var statusErr *StatusError
+	if assert.True(t, errors.As(err, &statusErr), "expected a status error") {
+		assert.Equal(t, http.StatusTooManyRequests, statusErr.StatusCode)
+	}
+	rateLimit, ok := provider.RateLimit()
+	assert.True(t, ok, "expected rate limit to be known after the first request")
+	assert.Equal(t, RateLimit{Limit: 800, Remaining: 0, Reset: time.Unix(1600000000, 0)}, rateLimit)
+	assert.Equal(t, 2, provider.Requests(101))
+}
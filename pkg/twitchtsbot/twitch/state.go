@@ -0,0 +1,101 @@
+package twitch
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// Snapshot contains the persisted state of a Monitor.
+type Snapshot struct {
+	Time     time.Time             `json:"time"`
+	Platform string                `json:"platform"`
+	States   map[string]*UserState `json:"states"`
+	// user login: pending status change
+	ChangeActive map[string]*ChangeState `json:"changeActive"`
+}
+
+// Snapshot returns a copy of the current state of the monitor.
+func (monitor *Monitor) Snapshot() *Snapshot {
+	monitor.Lock()
+	defer monitor.Unlock()
+	snapshot := &Snapshot{
+		Time:         monitor.Clock.Now(),
+		Platform:     monitor.Provider.Platform(),
+		States:       make(map[string]*UserState, len(monitor.States)),
+		ChangeActive: make(map[string]*ChangeState, len(monitor.ChangeActive)),
+	}
+	for userLogin, state := range monitor.States {
+		snapshot.States[userLogin] = state.copy()
+	}
+	for userLogin, changeState := range monitor.ChangeActive {
+		changeStateCopy := *changeState
+		snapshot.ChangeActive[userLogin] = &changeStateCopy
+	}
+	return snapshot
+}
+
+// Restore replaces the state of the monitor with the one of the snapshot. States of users which are not monitored
+// anymore are dropped. In contrast to the first poll, the restored states are not notified.
+func (monitor *Monitor) Restore(snapshot *Snapshot) {
+	monitor.Lock()
+	defer monitor.Unlock()
+	monitor.States = make(map[string]*UserState, len(monitor.UserLogins))
+	monitor.ChangeActive = make(map[string]*ChangeState, len(monitor.UserLogins))
+	for _, userLogin := range monitor.UserLogins {
+		if state, ok := snapshot.States[userLogin]; ok {
+			monitor.States[userLogin] = state.copy()
+		}
+		if changeState, ok := snapshot.ChangeActive[userLogin]; ok {
+			changeStateCopy := *changeState
+			monitor.ChangeActive[userLogin] = &changeStateCopy
+		}
+	}
+}
+
+// SaveState writes a snapshot of the monitor to the given file.
+func (monitor *Monitor) SaveState(path string) error {
+	data, err := json.Marshal(monitor.Snapshot())
+	if err != nil {
+		return err
+	}
+	tempFile := path + ".tmp"
+	if err := ioutil.WriteFile(tempFile, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, path)
+}
+
+// LoadState restores the snapshot from the given file if it exists, belongs to the platform of the monitor and is not
+// older than maxAge. It returns whether the snapshot has been restored.
+func (monitor *Monitor) LoadState(path string, maxAge time.Duration) (bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return false, err
+	}
+	snapshotAge := monitor.Clock.Now().Sub(snapshot.Time)
+	logger := Log.WithFields(logrus.Fields{
+		"platform":    monitor.Provider.Platform(),
+		"snapshotAge": snapshotAge.Round(time.Second).String(),
+	})
+	if snapshot.Platform != monitor.Provider.Platform() {
+		logger.WithField("snapshotPlatform", snapshot.Platform).Warnln("Ignoring monitor state of another platform.")
+		return false, nil
+	}
+	if snapshotAge > maxAge {
+		logger.Infoln("Ignoring outdated monitor state.")
+		return false, nil
+	}
+	monitor.Restore(&snapshot)
+	logger.WithField("stateAmount", len(snapshot.States)).Infoln("Restored monitor state.")
+	return true, nil
+}
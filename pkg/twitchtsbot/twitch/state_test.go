@@ -0,0 +1,71 @@
+package twitch
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMonitor_SaveLoadState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twitchtsbot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "monitor.json")
+	provider := &testProvider{streams: []Stream{{UserLogin: testStreamLogin1, Title: "title"}}}
+	notifyChan := make(chan *UserState, 2)
+	monitor := NewProviderMonitor(provider, []string{testStreamLogin1, testStreamLogin2}, time.Second,
+		context.Background(), notifyChan)
+	monitor.updateStreamerStates(context.Background(), provider.streams)
+	<-notifyChan
+	<-notifyChan
+	// the stream is offline for a single poll which should not be forgotten by a restart
+	monitor.updateStreamerStates(context.Background(), nil)
+	assert.NoError(t, monitor.SaveState(stateFile))
+
+	restarted := NewProviderMonitor(provider, []string{testStreamLogin1, testStreamLogin2}, time.Second,
+		context.Background(), notifyChan)
+	restored, err := restarted.LoadState(stateFile, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, restored, "expected recent state to be restored")
+	state, ok := restarted.GetState(testStreamLogin1)
+	assert.True(t, ok, "expected restored state to be available")
+	assert.Equal(t, StreamerStatusLive, state.StreamerStatus)
+	assert.Equal(t, "title", state.Stream.Title, "expected restored state to contain the stream metadata")
+	assert.Equal(t, &ChangeState{Status: StreamerStatusOffline, Count: 1}, restarted.ChangeActive[testStreamLogin1])
+	restarted.updateStreamerStates(context.Background(), nil)
+	assert.Len(t, notifyChan, 0, "expected restored states to not be notified")
+	assert.Equal(t, 2, restarted.ChangeActive[testStreamLogin1].Count, "expected pending change count to continue")
+}
+
+func TestMonitor_LoadStateOutdated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twitchtsbot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "monitor.json")
+	provider := &testProvider{}
+	monitor := NewProviderMonitor(provider, []string{testStreamLogin1}, time.Second, context.Background(),
+		make(chan *UserState, 1))
+	restored, err := monitor.LoadState(stateFile, time.Minute)
+	assert.NoError(t, err, "expected a missing state file to be ignored")
+	assert.False(t, restored)
+	monitor.updateStreamerStates(context.Background(), nil)
+	assert.NoError(t, monitor.SaveState(stateFile))
+	restarted := NewProviderMonitor(provider, []string{testStreamLogin1, testStreamLogin2}, time.Second,
+		context.Background(), make(chan *UserState, 2))
+	restored, err = restarted.LoadState(stateFile, 0)
+	assert.NoError(t, err)
+	assert.False(t, restored, "expected outdated state to be ignored")
+	assert.Nil(t, restarted.States)
+	restored, err = restarted.LoadState(stateFile, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, restored)
+	// users which are not part of the restored state are notified after the first poll
+	restarted.updateStreamerStates(context.Background(), nil)
+	state := <-restarted.NotifyChan
+	assert.Equal(t, testStreamLogin2, state.UserLogin)
+	assert.Len(t, restarted.NotifyChan, 0)
+}
@@ -0,0 +1,176 @@
+package twitch
+
+import (
+	"context"
+	"fmt"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/clock"
+	"github.com/nicklaw5/helix"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultUserIDsMaxAge is the default duration after which the user ids of the monitored viewers are fetched again, so
+// that renamed and new accounts are picked up.
+const DefaultUserIDsMaxAge = time.Hour
+
+// helixMaxUserIDs is the maximum amount of user ids or logins which can be passed to a single GetSubscriptions or
+// GetUsers request.
+const helixMaxUserIDs = 100
+
+// SubscriptionApiClient is implemented by Helix clients which are authenticated with a broadcaster user access token
+// with the channel:read:subscriptions scope.
+type SubscriptionApiClient interface {
+	ApiClient
+	GetSubscriptions(params *helix.SubscriptionsParams) (*helix.SubscriptionsResponse, error)
+}
+
+// SubscriptionState contains the subscription of a single viewer to the monitored broadcaster.
+type SubscriptionState struct {
+	UserLogin string
+	// Tier is the subscription tier ("1000", "2000" or "3000") and empty if the viewer is not subscribed.
+	Tier string
+}
+
+// SubscriptionMonitor periodically fetches the broadcaster subscriptions of a set of viewers and notifies about
+// subscription changes. Follows of the viewers are not monitored.
+type SubscriptionMonitor struct {
+	*sync.Mutex
+	States        map[string]*SubscriptionState
+	Client        SubscriptionApiClient
+	BroadcasterID string
+	UserLogins    []string
+	// lower case user login: twitch user id
+	UserIDs map[string]string
+	// UserIDsMaxAge is the duration after which UserIDs are fetched again.
+	UserIDsMaxAge time.Duration
+	Interval      time.Duration
+	Context       context.Context
+	NotifyChan    chan *SubscriptionState
+	// Clock provides the time of the polls and defaults to the real clock.
+	Clock          clock.Clock
+	userIDsFetched time.Time
+}
+
+func NewSubscriptionMonitor(client SubscriptionApiClient, broadcasterID string, userLogins []string,
+	interval time.Duration, context context.Context, notifyChan chan *SubscriptionState) *SubscriptionMonitor {
+	return &SubscriptionMonitor{
+		Mutex:         &sync.Mutex{},
+		Client:        client,
+		BroadcasterID: broadcasterID,
+		UserLogins:    userLogins,
+		UserIDsMaxAge: DefaultUserIDsMaxAge,
+		Interval:      interval,
+		Context:       context,
+		NotifyChan:    notifyChan,
+		Clock:         clock.Real,
+	}
+}
+
+func (monitor *SubscriptionMonitor) Start() {
+	Log.WithFields(logrus.Fields{
+		"interval":        monitor.Interval.String(),
+		"userLoginNumber": len(monitor.UserLogins),
+		"broadcasterId":   monitor.BroadcasterID,
+	}).Infoln("Starting Twitch subscription monitor")
+	go func() {
+		for {
+			if err := monitor.updateSubscriptions(); err != nil {
+				Log.WithError(err).Errorln("Could not update subscription states!")
+			}
+			select {
+			case <-monitor.Clock.After(monitor.Interval):
+			case <-monitor.Context.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (monitor *SubscriptionMonitor) updateSubscriptions() error {
+	if monitor.UserIDs == nil || monitor.Clock.Now().Sub(monitor.userIDsFetched) >= monitor.UserIDsMaxAge {
+		userIDs, err := RetrieveIDs(monitor.Client, monitor.UserLogins)
+		if err != nil {
+			return err
+		}
+		monitor.UserIDs = userIDs
+		monitor.userIDsFetched = monitor.Clock.Now()
+	}
+	ids := make([]string, 0, len(monitor.UserIDs))
+	for _, id := range monitor.UserIDs {
+		ids = append(ids, id)
+	}
+	// twitch user id: subscription tier
+	tiers := make(map[string]string)
+	for start := 0; start < len(ids); start += helixMaxUserIDs {
+		end := start + helixMaxUserIDs
+		if end > len(ids) {
+			end = len(ids)
+		}
+		resp, err := monitor.Client.GetSubscriptions(&helix.SubscriptionsParams{
+			BroadcasterID: monitor.BroadcasterID,
+			UserID:        ids[start:end],
+		})
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("twitch api returned unexpected status code: %d", resp.StatusCode)
+		}
+		for _, subscription := range resp.Data.Subscriptions {
+			tiers[subscription.UserID] = subscription.Tier
+		}
+	}
+	Log.WithField("subscriptionCount", len(tiers)).Debugln("Fetched subscriptions from Twitch API.")
+	monitor.updateSubscriptionStates(tiers)
+	return nil
+}
+
+// updateSubscriptionStates applies the fetched subscription tiers and notifies about the changed states. The changes
+// are sent after the lock has been released, so that a stalled consumer does not block GetState.
+func (monitor *SubscriptionMonitor) updateSubscriptionStates(tiers map[string]string) {
+	for _, state := range monitor.changeSubscriptionStates(tiers) {
+		select {
+		case monitor.NotifyChan <- state:
+		case <-monitor.Context.Done():
+			return
+		}
+	}
+}
+
+// changeSubscriptionStates applies the fetched subscription tiers and returns copies of the changed states.
+func (monitor *SubscriptionMonitor) changeSubscriptionStates(tiers map[string]string) []*SubscriptionState {
+	monitor.Lock()
+	defer monitor.Unlock()
+	changed := make([]*SubscriptionState, 0)
+	if monitor.States == nil {
+		monitor.States = make(map[string]*SubscriptionState, len(monitor.UserLogins))
+	}
+	for _, userLogin := range monitor.UserLogins {
+		tier := tiers[monitor.UserIDs[strings.ToLower(userLogin)]]
+		state, ok := monitor.States[userLogin]
+		if !ok {
+			state = &SubscriptionState{UserLogin: userLogin}
+			monitor.States[userLogin] = state
+		} else if state.Tier == tier {
+			continue
+		}
+		state.Tier = tier
+		stateCopy := *state
+		changed = append(changed, &stateCopy)
+	}
+	return changed
+}
+
+func (monitor *SubscriptionMonitor) GetState(userLogin string) (*SubscriptionState, bool) {
+	monitor.Lock()
+	defer monitor.Unlock()
+	state, ok := monitor.States[userLogin]
+	if !ok {
+		return nil, false
+	}
+	stateCopy := *state
+	return &stateCopy, true
+}
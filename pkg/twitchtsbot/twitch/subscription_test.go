@@ -0,0 +1,123 @@
+package twitch
+
+import (
+	"context"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/testutil"
+	"github.com/nicklaw5/helix"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const testBroadcasterID = "99"
+
+var (
+	defaultOkSubscriptionsResponse = helix.SubscriptionsResponse{ResponseCommon: helix.ResponseCommon{StatusCode: http.StatusOK}}
+)
+
+func newTestSubscriptionMonitor(mockClient *testApiClient, notifyChan chan *SubscriptionState) *SubscriptionMonitor {
+	usersResponse := defaultOkUsersResponse
+	usersResponse.Data = helix.ManyUsers{Users: []helix.User{{ID: "1", Login: testStreamLogin1}}}
+	mockClient.On("GetUsers", &helix.UsersParams{Logins: []string{testStreamLogin1}}).Return(&usersResponse, nil)
+	return NewSubscriptionMonitor(mockClient, testBroadcasterID, []string{testStreamLogin1}, time.Second,
+		context.Background(), notifyChan)
+}
+
+func TestSubscriptionMonitor_UpdateSubscriptions(t *testing.T) {
+	mockClient := new(testApiClient)
+	response := defaultOkSubscriptionsResponse
+	response.Data.Subscriptions = []helix.Subscription{{UserID: "1", Tier: "2000"}}
+	mockClient.On("GetSubscriptions", &helix.SubscriptionsParams{
+		BroadcasterID: testBroadcasterID,
+		UserID:        []string{"1"},
+	}).Return(&response, nil)
+	notifyChan := make(chan *SubscriptionState, 1)
+	monitor := newTestSubscriptionMonitor(mockClient, notifyChan)
+	assert.Nil(t, monitor.updateSubscriptions(), "returned err for update subscriptions is not nil")
+	state := <-notifyChan
+	assert.Equal(t, &SubscriptionState{UserLogin: testStreamLogin1, Tier: "2000"}, state)
+	// unchanged subscriptions should not be notified again
+	assert.Nil(t, monitor.updateSubscriptions(), "returned err for update subscriptions is not nil")
+	assert.Len(t, notifyChan, 0, "expected unchanged subscription to not be notified")
+	response.Data.Subscriptions = nil
+	assert.Nil(t, monitor.updateSubscriptions(), "returned err for update subscriptions is not nil")
+	state = <-notifyChan
+	assert.Equal(t, &SubscriptionState{UserLogin: testStreamLogin1}, state, "expected lapsed subscription")
+	mockClient.AssertNumberOfCalls(t, "GetUsers", 1)
+	state, ok := monitor.GetState(testStreamLogin1)
+	assert.True(t, ok, "expected GetState to return state")
+	assert.Equal(t, "", state.Tier, "expected GetState to return lapsed subscription")
+}
+
+func TestSubscriptionMonitor_UpdateSubscriptionsInvalidStatusCode(t *testing.T) {
+	mockClient := new(testApiClient)
+	response := defaultOkSubscriptionsResponse
+	response.StatusCode = http.StatusUnauthorized
+	mockClient.On("GetSubscriptions", &helix.SubscriptionsParams{
+		BroadcasterID: testBroadcasterID,
+		UserID:        []string{"1"},
+	}).Return(&response, nil)
+	monitor := newTestSubscriptionMonitor(mockClient, make(chan *SubscriptionState))
+	assert.NotNil(t, monitor.updateSubscriptions(), "returned error for update subscriptions should not be nil")
+}
+
+func TestSubscriptionMonitor_Start(t *testing.T) {
+	mockClient := new(testApiClient)
+	response := defaultOkSubscriptionsResponse
+	response.Data.Subscriptions = []helix.Subscription{{UserID: "1", Tier: "1000"}}
+	mockClient.On("GetSubscriptions", &helix.SubscriptionsParams{
+		BroadcasterID: testBroadcasterID,
+		UserID:        []string{"1"},
+	}).Return(&response, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notifyChan := make(chan *SubscriptionState)
+	monitor := newTestSubscriptionMonitor(mockClient, notifyChan)
+	monitor.Context = ctx
+	fakeClock := testutil.NewFakeClock(time.Now())
+	monitor.Clock = fakeClock
+	monitor.Start()
+	assert.Equal(t, &SubscriptionState{UserLogin: testStreamLogin1, Tier: "1000"}, <-notifyChan)
+	fakeClock.WaitForWaiters(t, 1)
+	response.Data.Subscriptions = []helix.Subscription{{UserID: "1", Tier: "3000"}}
+	fakeClock.Advance(monitor.Interval)
+	assert.Equal(t, &SubscriptionState{UserLogin: testStreamLogin1, Tier: "3000"}, <-notifyChan,
+		"expected the subscriptions to be polled again after the interval")
+}
+
+func TestSubscriptionMonitor_UserIDsMaxAge(t *testing.T) {
+	mockClient := new(testApiClient)
+	response := defaultOkSubscriptionsResponse
+	mockClient.On("GetSubscriptions", &helix.SubscriptionsParams{
+		BroadcasterID: testBroadcasterID,
+		UserID:        []string{"1"},
+	}).Return(&response, nil)
+	monitor := newTestSubscriptionMonitor(mockClient, make(chan *SubscriptionState, 1))
+	fakeClock := testutil.NewFakeClock(time.Now())
+	monitor.Clock = fakeClock
+	assert.Nil(t, monitor.updateSubscriptions(), "returned err for update subscriptions is not nil")
+	assert.Nil(t, monitor.updateSubscriptions(), "returned err for update subscriptions is not nil")
+	mockClient.AssertNumberOfCalls(t, "GetUsers", 1)
+	fakeClock.Advance(monitor.UserIDsMaxAge)
+	assert.Nil(t, monitor.updateSubscriptions(), "returned err for update subscriptions is not nil")
+	mockClient.AssertNumberOfCalls(t, "GetUsers", 2)
+}
+
+func TestSubscriptionMonitor_NotifyCanceled(t *testing.T) {
+	mockClient := new(testApiClient)
+	response := defaultOkSubscriptionsResponse
+	response.Data.Subscriptions = []helix.Subscription{{UserID: "1", Tier: "1000"}}
+	mockClient.On("GetSubscriptions", &helix.SubscriptionsParams{
+		BroadcasterID: testBroadcasterID,
+		UserID:        []string{"1"},
+	}).Return(&response, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	monitor := newTestSubscriptionMonitor(mockClient, make(chan *SubscriptionState))
+	monitor.Context = ctx
+	assert.Nil(t, monitor.updateSubscriptions(), "expected a canceled monitor to not block on notifying")
+	state, ok := monitor.GetState(testStreamLogin1)
+	assert.True(t, ok, "expected GetState to return state")
+	assert.Equal(t, "1000", state.Tier, "expected GetState to return the subscription")
+}
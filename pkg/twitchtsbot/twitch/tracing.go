@@ -0,0 +1,64 @@
+package twitch
+
+import (
+	"context"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the polls and the state transitions. The spans are exported by the tracer provider which
+// is registered globally and are dropped if none is registered.
+var tracer = otel.Tracer("github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch")
+
+// CorrelationId returns the id which correlates the state with the poll it has been detected in and the changes which
+// are caused by it. It is empty if the state has not been traced.
+func (state *UserState) CorrelationId() string {
+	if !state.SpanContext.HasTraceID() {
+		return ""
+	}
+	return state.SpanContext.TraceID().String()
+}
+
+// notify sends a copy of the state to the channel. The copy carries the context of a span which is created for the
+// notification so that the consumers can continue the trace of the poll. The state is dropped once the context is
+// done, so that stopping the monitor is not blocked by a consumer which does not receive anymore.
+func (monitor *Monitor) notify(ctx context.Context, target chan *UserState, name string, state *UserState) {
+	_, span := tracer.Start(ctx, name, trace.WithAttributes(stateAttributes(state)...))
+	defer span.End()
+	stateCopy := state.copy()
+	stateCopy.SpanContext = span.SpanContext()
+	select {
+	case target <- stateCopy:
+	case <-ctx.Done():
+		span.AddEvent("not notified as the monitor has been stopped")
+	}
+}
+
+func stateAttributes(state *UserState) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("platform", state.Platform),
+		attribute.String(logging.FieldTwitchLogin, state.UserLogin),
+		attribute.String("status", state.StreamerStatus.String()),
+	}
+}
+
+// recordError marks the span as failed if an error occurred.
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// pendingChange records a status change which is not notified yet because it has not been detected often enough.
+func pendingChange(ctx context.Context, state *UserState, status StreamerStatus, count int) {
+	trace.SpanFromContext(ctx).AddEvent("status change pending", trace.WithAttributes(
+		attribute.String(logging.FieldTwitchLogin, state.UserLogin),
+		attribute.String("status", status.String()),
+		attribute.Int("count", count),
+		attribute.Int("required", changesRequired),
+	))
+}
@@ -0,0 +1,71 @@
+package twitch
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	testSpanRecorder     *tracetest.SpanRecorder
+	testSpanRecorderOnce sync.Once
+)
+
+// spanRecorder returns the recorder of the globally registered tracer provider, which can only be registered once.
+func spanRecorder() *tracetest.SpanRecorder {
+	testSpanRecorderOnce.Do(func() {
+		testSpanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(testSpanRecorder)))
+	})
+	return testSpanRecorder
+}
+
+func endedSpans(recorder *tracetest.SpanRecorder, traceId trace.TraceID) map[string]sdktrace.ReadOnlySpan {
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() == traceId {
+			spans[span.Name()] = span
+		}
+	}
+	return spans
+}
+
+func TestMonitor_Tracing(t *testing.T) {
+	recorder := spanRecorder()
+	provider := &testProvider{streams: []Stream{{UserLogin: testStreamLogin1}}}
+	notifyChan := make(chan *UserState, 1)
+	monitor := NewProviderMonitor(provider, []string{testStreamLogin1}, time.Second, context.Background(), notifyChan)
+	assert.NoError(t, monitor.updateUserStates())
+	state := <-notifyChan
+	assert.True(t, state.SpanContext.IsValid(), "expected the notified state to carry the span context")
+	assert.Equal(t, state.SpanContext.TraceID().String(), state.CorrelationId())
+	spans := endedSpans(recorder, state.SpanContext.TraceID())
+	assert.Contains(t, spans, "twitch.poll")
+	assert.Contains(t, spans, "twitch.transition")
+	assert.Equal(t, spans["twitch.poll"].SpanContext().SpanID(), spans["twitch.transition"].Parent().SpanID(),
+		"expected the transition to be a child of the poll")
+
+	// the pending status changes are recorded as events of the poll
+	provider.streams = nil
+	assert.NoError(t, monitor.updateUserStates())
+	polls := recorder.Ended()
+	poll := polls[len(polls)-1]
+	assert.Equal(t, "twitch.poll", poll.Name())
+	assert.Len(t, poll.Events(), 1)
+	assert.Equal(t, "status change pending", poll.Events()[0].Name)
+
+	provider.err = errors.New("request failed")
+	assert.Error(t, monitor.updateUserStates())
+	polls = recorder.Ended()
+	assert.Equal(t, codes.Error, polls[len(polls)-1].Status().Code, "expected the failed poll to be recorded")
+
+	assert.Empty(t, (&UserState{}).CorrelationId(), "expected untraced states to not have a correlation id")
+}
@@ -4,22 +4,36 @@ import (
 	"fmt"
 	"github.com/nicklaw5/helix"
 	"net/http"
+	"strings"
 )
 
-func RetrieveIDs(client ApiClient, names []string) ([]string, error) {
-	Log.WithField("nameCount", len(names)).Debugln("Fetching Twitch User IDs...")
-	resp, err := client.GetUsers(&helix.UsersParams{
-		Logins: names,
-	})
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("received unexpected status code from twitch api: %d", resp.StatusCode)
+// RetrieveIDs fetches the Twitch user ids of the given login names and returns them mapped by their lower case login
+// name. Logins which do not exist are missing in the returned map. The logins are requested in chunks of 100, the
+// maximum of a single request. No request is sent if no logins are given since Twitch would return the owner of a user
+// access token instead.
+func RetrieveIDs(client ApiClient, names []string) (map[string]string, error) {
+	ids := make(map[string]string, len(names))
+	if len(names) == 0 {
+		return ids, nil
 	}
-	ids := make([]string, 0)
-	for _, user := range resp.Data.Users {
-		ids = append(ids, user.ID)
+	Log.WithField("nameCount", len(names)).Debugln("Fetching Twitch User IDs...")
+	for start := 0; start < len(names); start += helixMaxUserIDs {
+		end := start + helixMaxUserIDs
+		if end > len(names) {
+			end = len(names)
+		}
+		resp, err := client.GetUsers(&helix.UsersParams{
+			Logins: names[start:end],
+		})
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("received unexpected status code from twitch api: %d", resp.StatusCode)
+		}
+		for _, user := range resp.Data.Users {
+			ids[strings.ToLower(user.Login)] = user.ID
+		}
 	}
 	Log.WithField("idCount", len(ids)).Debugln("Fetched Twitch User IDs!")
 	return ids, nil
@@ -2,8 +2,10 @@ package twitch
 
 import (
 	"errors"
+	"fmt"
 	"github.com/nicklaw5/helix"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"net/http"
 	"testing"
 )
@@ -16,12 +18,12 @@ var (
 func TestRetrieveIDs(t *testing.T) {
 	client := new(testApiClient)
 	response := defaultOkUsersResponse
-	response.Data = helix.ManyUsers{Users: []helix.User{{ID: "0"}}}
+	response.Data = helix.ManyUsers{Users: []helix.User{{ID: "0", Login: "TestUser"}}}
 	client.On("GetUsers", &defaultUserParams).Return(&response, nil)
 	ids, err := RetrieveIDs(client, []string{"testuser"})
 	client.AssertNumberOfCalls(t, "GetUsers", 1)
 	assert.Nil(t, err, "returned err for retrieve ids method is not nil")
-	assert.Equal(t, []string{"0"}, ids, "returned ids from retrieve ids method is invalid")
+	assert.Equal(t, map[string]string{"testuser": "0"}, ids, "returned ids from retrieve ids method is invalid")
 }
 
 func TestRetrieveIDs_Error(t *testing.T) {
@@ -44,3 +46,38 @@ func TestRetrieveIDs_InvalidStatusCode(t *testing.T) {
 	assert.Nil(t, ids, "returned ids for retrieve ids method should be nil")
 	assert.NotNil(t, err, "returned error for retrieve ids should not be nil")
 }
+
+func TestRetrieveIDs_NoLogins(t *testing.T) {
+	client := new(testApiClient)
+	ids, err := RetrieveIDs(client, nil)
+	client.AssertNotCalled(t, "GetUsers", mock.Anything)
+	assert.Nil(t, err, "returned err for retrieve ids method is not nil")
+	assert.Empty(t, ids, "returned ids for retrieve ids method should be empty")
+}
+
+func TestRetrieveIDs_Chunks(t *testing.T) {
+	client := new(testApiClient)
+	logins := make([]string, 0, 250)
+	for i := 0; i < 250; i++ {
+		logins = append(logins, fmt.Sprintf("user%d", i))
+	}
+	expected := make(map[string]string, len(logins))
+	for start := 0; start < len(logins); start += 100 {
+		end := start + 100
+		if end > len(logins) {
+			end = len(logins)
+		}
+		response := defaultOkUsersResponse
+		users := make([]helix.User, 0, end-start)
+		for _, login := range logins[start:end] {
+			users = append(users, helix.User{ID: "id-" + login, Login: login})
+			expected[login] = "id-" + login
+		}
+		response.Data = helix.ManyUsers{Users: users}
+		client.On("GetUsers", &helix.UsersParams{Logins: logins[start:end]}).Return(&response, nil)
+	}
+	ids, err := RetrieveIDs(client, logins)
+	client.AssertNumberOfCalls(t, "GetUsers", 3)
+	assert.Nil(t, err, "returned err for retrieve ids method is not nil")
+	assert.Equal(t, expected, ids, "returned ids from retrieve ids method are invalid")
+}
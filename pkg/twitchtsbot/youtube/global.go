@@ -0,0 +1,5 @@
+package youtube
+
+import "github.com/sirupsen/logrus"
+
+var Log = logrus.StandardLogger()
@@ -0,0 +1,218 @@
+package youtube
+
+import (
+	"encoding/json"
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Platform is the platform identifier of the YouTube provider.
+	Platform = "youtube"
+	// DefaultBaseURL is the base URL of the YouTube Data API v3.
+	DefaultBaseURL = "https://www.googleapis.com/youtube/v3"
+	// DefaultInterval is the poll interval which keeps the quota usage of a few channels within the daily quota.
+	DefaultInterval = 2 * time.Minute
+	// DefaultTimeout is the timeout of the requests to the YouTube Data API, so that a hanging request does not stall
+	// the polling.
+	DefaultTimeout = 10 * time.Second
+	// DailyQuota is the amount of quota units an API key may use per day unless a higher quota has been granted.
+	DailyQuota = 10000
+	// maxIDs is the maximum amount of channel or video ids which can be passed to a single channels or videos request.
+	maxIDs = 50
+	// recentUploads is the amount of the most recent uploads of a channel which are checked for live streams.
+	recentUploads = 5
+)
+
+// Provider implements twitch.Provider by using the YouTube Data API. Channels are identified by their channel id.
+//
+// Live streams are looked up among the most recent uploads of the channels instead of searching for them, as a search
+// costs 100 units of the daily quota of 10000 units while listing the uploads of a channel costs a single unit.
+type Provider struct {
+	APIKey      string
+	BaseURL     string
+	HTTPClient  *http.Client
+	apiKeyMutex sync.RWMutex
+	// channel id: id of the playlist of the uploads of the channel, which does not change
+	uploads      map[string]string
+	uploadsMutex sync.Mutex
+}
+
+func NewProvider(apiKey string) *Provider {
+	return &Provider{
+		APIKey:     apiKey,
+		BaseURL:    DefaultBaseURL,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		uploads:    make(map[string]string),
+	}
+}
+
+type channelsResponse struct {
+	Items []struct {
+		ID             string `json:"id"`
+		ContentDetails struct {
+			RelatedPlaylists struct {
+				Uploads string `json:"uploads"`
+			} `json:"relatedPlaylists"`
+		} `json:"contentDetails"`
+	} `json:"items"`
+}
+
+type playlistItemsResponse struct {
+	Items []struct {
+		ContentDetails struct {
+			VideoID string `json:"videoId"`
+		} `json:"contentDetails"`
+	} `json:"items"`
+}
+
+type videosResponse struct {
+	Items []struct {
+		ID      string `json:"id"`
+		Snippet struct {
+			ChannelID            string `json:"channelId"`
+			Title                string `json:"title"`
+			CategoryID           string `json:"categoryId"`
+			LiveBroadcastContent string `json:"liveBroadcastContent"`
+			Thumbnails           map[string]struct {
+				URL string `json:"url"`
+			} `json:"thumbnails"`
+		} `json:"snippet"`
+		LiveStreamingDetails struct {
+			ActualStartTime   time.Time `json:"actualStartTime"`
+			ConcurrentViewers string    `json:"concurrentViewers"`
+		} `json:"liveStreamingDetails"`
+	} `json:"items"`
+}
+
+// SetAPIKey replaces the API key e.g. after it has been rotated.
+func (provider *Provider) SetAPIKey(apiKey string) {
+	provider.apiKeyMutex.Lock()
+	defer provider.apiKeyMutex.Unlock()
+	provider.APIKey = apiKey
+}
+
+func (provider *Provider) Platform() string {
+	return Platform
+}
+
+func (provider *Provider) LiveStreams(channels []string) ([]twitch.Stream, error) {
+	uploads, err := provider.uploadPlaylists(channels)
+	if err != nil {
+		return nil, err
+	}
+	videoIDs := make([]string, 0)
+	for _, channel := range channels {
+		playlistID, ok := uploads[channel]
+		if !ok {
+			continue
+		}
+		var resp playlistItemsResponse
+		err := provider.get("playlistItems", url.Values{
+			"part":       {"contentDetails"},
+			"playlistId": {playlistID},
+			"maxResults": {strconv.Itoa(recentUploads)},
+		}, &resp)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range resp.Items {
+			videoIDs = append(videoIDs, item.ContentDetails.VideoID)
+		}
+	}
+	Log.WithField("videoCount", len(videoIDs)).Debugln("Fetched recent videos from YouTube API.")
+	streams := make([]twitch.Stream, 0)
+	for start := 0; start < len(videoIDs); start += maxIDs {
+		end := start + maxIDs
+		if end > len(videoIDs) {
+			end = len(videoIDs)
+		}
+		var resp videosResponse
+		err := provider.get("videos", url.Values{
+			"part": {"snippet,liveStreamingDetails"},
+			"id":   {strings.Join(videoIDs[start:end], ",")},
+		}, &resp)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range resp.Items {
+			if item.Snippet.LiveBroadcastContent != "live" {
+				continue
+			}
+			viewers, _ := strconv.Atoi(item.LiveStreamingDetails.ConcurrentViewers)
+			streams = append(streams, twitch.Stream{
+				UserLogin:    item.Snippet.ChannelID,
+				Title:        item.Snippet.Title,
+				GameID:       item.Snippet.CategoryID,
+				Type:         "live",
+				ViewerCount:  viewers,
+				StartedAt:    item.LiveStreamingDetails.ActualStartTime,
+				ThumbnailURL: item.Snippet.Thumbnails["high"].URL,
+			})
+		}
+	}
+	return streams, nil
+}
+
+// QuotaUnits returns the amount of quota units which are used to retrieve the live streams of the given amount of
+// channels once their upload playlists are known.
+func QuotaUnits(channels int) int {
+	return channels + (channels*recentUploads+maxIDs-1)/maxIDs
+}
+
+// uploadPlaylists returns the ids of the upload playlists of the channels. They are only requested for the channels
+// which have not been requested before. Channels which do not exist are not part of the result.
+func (provider *Provider) uploadPlaylists(channels []string) (map[string]string, error) {
+	provider.uploadsMutex.Lock()
+	defer provider.uploadsMutex.Unlock()
+	missing := make([]string, 0)
+	for _, channel := range channels {
+		if _, ok := provider.uploads[channel]; !ok {
+			missing = append(missing, channel)
+		}
+	}
+	for start := 0; start < len(missing); start += maxIDs {
+		end := start + maxIDs
+		if end > len(missing) {
+			end = len(missing)
+		}
+		var resp channelsResponse
+		err := provider.get("channels", url.Values{
+			"part": {"contentDetails"},
+			"id":   {strings.Join(missing[start:end], ",")},
+		}, &resp)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range resp.Items {
+			provider.uploads[item.ID] = item.ContentDetails.RelatedPlaylists.Uploads
+		}
+	}
+	uploads := make(map[string]string, len(channels))
+	for _, channel := range channels {
+		if playlistID, ok := provider.uploads[channel]; ok {
+			uploads[channel] = playlistID
+		}
+	}
+	return uploads, nil
+}
+
+func (provider *Provider) get(path string, query url.Values, v interface{}) error {
+	provider.apiKeyMutex.RLock()
+	query.Set("key", provider.APIKey)
+	provider.apiKeyMutex.RUnlock()
+	resp, err := provider.HTTPClient.Get(provider.BaseURL + "/" + path + "?" + query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &twitch.StatusError{Platform: Platform, StatusCode: resp.StatusCode}
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
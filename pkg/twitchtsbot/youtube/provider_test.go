@@ -0,0 +1,104 @@
+package youtube
+
+import (
+	"github.com/mmichaelb/twitchtsbot/pkg/twitchtsbot/twitch"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAPIKey     = "testkey"
+	testChannelID  = "UCtestchannel"
+	testPlaylistID = "UUtestchannel"
+	testVideoID    = "testvideo"
+	testOldVideoID = "oldvideo"
+)
+
+// newTestServer fakes the YouTube Data API and counts the requests of every endpoint.
+func newTestServer(t *testing.T, live bool, requests map[string]int) *httptest.Server {
+	var mutex sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, testAPIKey, r.URL.Query().Get("key"), "expected api key to be passed")
+		mutex.Lock()
+		requests[r.URL.Path]++
+		mutex.Unlock()
+		switch r.URL.Path {
+		case "/channels":
+			assert.Equal(t, testChannelID+",UCunknown", r.URL.Query().Get("id"))
+			_, _ = w.Write([]byte(`{"items":[{"id":"` + testChannelID + `","contentDetails":{"relatedPlaylists":` +
+				`{"uploads":"` + testPlaylistID + `"}}}]}`))
+		case "/playlistItems":
+			assert.Equal(t, testPlaylistID, r.URL.Query().Get("playlistId"))
+			_, _ = w.Write([]byte(`{"items":[{"contentDetails":{"videoId":"` + testVideoID + `"}},` +
+				`{"contentDetails":{"videoId":"` + testOldVideoID + `"}}]}`))
+		case "/videos":
+			assert.Equal(t, testVideoID+","+testOldVideoID, r.URL.Query().Get("id"))
+			liveBroadcastContent := "none"
+			if live {
+				liveBroadcastContent = "live"
+			}
+			_, _ = w.Write([]byte(`{"items":[{"id":"` + testVideoID + `","snippet":{"channelId":"` + testChannelID +
+				`","title":"test stream","categoryId":"20","liveBroadcastContent":"` + liveBroadcastContent + `",` +
+				`"thumbnails":{"high":{"url":"http://thumbnail"}}},"liveStreamingDetails":` +
+				`{"actualStartTime":"2021-01-01T12:00:00Z","concurrentViewers":"42"}},{"id":"` + testOldVideoID +
+				`","snippet":{"channelId":"` + testChannelID + `","liveBroadcastContent":"none"}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestProvider_LiveStreams(t *testing.T) {
+	requests := make(map[string]int)
+	server := newTestServer(t, true, requests)
+	defer server.Close()
+	provider := NewProvider(testAPIKey)
+	provider.BaseURL = server.URL
+	streams, err := provider.LiveStreams([]string{testChannelID, "UCunknown"})
+	assert.Nil(t, err, "returned err for live streams method is not nil")
+	assert.Equal(t, []twitch.Stream{{
+		UserLogin:    testChannelID,
+		Title:        "test stream",
+		GameID:       "20",
+		Type:         "live",
+		ViewerCount:  42,
+		StartedAt:    time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC),
+		ThumbnailURL: "http://thumbnail",
+	}}, streams, "returned streams from live streams method are invalid")
+
+	_, err = provider.LiveStreams([]string{testChannelID})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"/channels": 1, "/playlistItems": 2, "/videos": 2}, requests,
+		"expected the upload playlists to be requested once and no search to be used")
+}
+
+func TestProvider_LiveStreamsOffline(t *testing.T) {
+	server := newTestServer(t, false, make(map[string]int))
+	defer server.Close()
+	provider := NewProvider(testAPIKey)
+	provider.BaseURL = server.URL
+	streams, err := provider.LiveStreams([]string{testChannelID, "UCunknown"})
+	assert.Nil(t, err, "returned err for live streams method is not nil")
+	assert.Empty(t, streams, "expected no live streams")
+}
+
+func TestProvider_LiveStreamsInvalidStatusCode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+	provider := NewProvider(testAPIKey)
+	provider.BaseURL = server.URL
+	streams, err := provider.LiveStreams([]string{testChannelID})
+	assert.Nil(t, streams, "returned streams for live streams method should be nil")
+	assert.NotNil(t, err, "returned error for live streams should not be nil")
+}
+
+func TestNewProvider_Timeout(t *testing.T) {
+	provider := NewProvider(testAPIKey)
+	assert.Equal(t, DefaultTimeout, provider.HTTPClient.Timeout, "expected the requests to time out")
+}